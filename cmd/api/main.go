@@ -29,16 +29,55 @@ func main() {
 	container.Provide(repository.NewUserRepository)
 	container.Provide(repository.NewScriptRepository)
 	container.Provide(repository.NewScriptShareRepository)
+	container.Provide(repository.NewScriptRevisionRepository)
+	container.Provide(repository.NewGroupRepository)
+	container.Provide(repository.NewFolderRepository)
+	container.Provide(repository.NewNotificationRepository)
+	container.Provide(repository.NewProcessRepository)
+	container.Provide(repository.NewTransactor)
+	container.Provide(repository.NewSigningKeyRepository)
+	container.Provide(repository.NewSessionRepository)
+	container.Provide(repository.NewRevokedTokenRepository)
+	container.Provide(repository.NewAPITokenRepository)
+	container.Provide(repository.NewInvitationRepository)
+	container.Provide(repository.NewAuditRepository)
+	container.Provide(repository.NewLoginAttemptRepository)
+	container.Provide(repository.NewMFAChallengeRepository)
+	container.Provide(repository.NewSettingsRepository)
+	container.Provide(repository.NewOIDCStateRepository)
 
 	// Register services (order matters)
 	container.Provide(services.NewAuthService)
-	container.Provide(services.NewUserService)
+	container.Provide(services.NewAPITokenService)
+	container.Provide(services.NewInvitationService)
+	container.Provide(services.NewAuditService)
+	container.Provide(services.NewLoginThrottle)
+	container.Provide(services.NewAuthProviders)
+	container.Provide(services.NewTwoFactorService)
+	container.Provide(services.NewOIDCService)
 	container.Provide(services.NewScriptService)
+	container.Provide(services.NewProcessService)
+	container.Provide(services.NewUserService)
+	container.Provide(services.NewGroupService)
+	container.Provide(services.NewFolderService)
+	container.Provide(services.NewNotificationService)
+	container.Provide(services.NewShareExpiryService)
+	container.Provide(services.NewTrashPurgeService)
+	container.Provide(services.NewRunRetentionService)
+	container.Provide(services.NewKeyringService)
 
 	// Register handlers
 	container.Provide(handlers.NewAuthHandler)
 	container.Provide(handlers.NewUserHandler)
 	container.Provide(handlers.NewScriptHandler)
+	container.Provide(handlers.NewProcessHandler)
+	container.Provide(handlers.NewGroupHandler)
+	container.Provide(handlers.NewFolderHandler)
+	container.Provide(handlers.NewNotificationHandler)
+	container.Provide(handlers.NewAPITokenHandler)
+	container.Provide(handlers.NewInvitationHandler)
+	container.Provide(handlers.NewAuditHandler)
+	container.Provide(handlers.NewTwoFactorHandler)
 
 	// Register app
 	container.Provide(core.NewApp)
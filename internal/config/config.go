@@ -1,22 +1,116 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
-	AppPort        string
-	MongoURI       string
-	MongoDBName    string
-	RootUsername   string
-	RootPassword   string
+	AppPort            string
+	MongoURI           string
+	MongoDBName        string
+	RootUsername       string
+	RootPassword       string
+	ShareSweepInterval time.Duration
+	TrashRetentionDays int
+	TrashPurgeInterval time.Duration
+	RunRetentionCount  int
+	RunRetentionDays   int
+	RunPruneInterval   time.Duration
+	JWTKeyFile         string
+	JWTKeySecret       string
+	JWTKeyRotation     time.Duration
+	JWTKeyRefresh      time.Duration
+	RefreshTokenTTL    time.Duration
+	SignupMode         string
+
+	PasswordMinLength     int
+	PasswordMinClasses    int
+	PasswordDenylistFile  string
+	LoginMaxAttempts      int
+	LoginMaxAttemptsPerIP int
+	LoginLockoutBase      time.Duration
+	LoginLockoutMax       time.Duration
+	LoginFailureReset     time.Duration
+	TOTPIssuer            string
+
+	AuthProviders      string
+	LDAPURL            string
+	LDAPStartTLS       bool
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string
+	LDAPGroupAttribute string
+	LDAPEmailAttribute string
+	LDAPNameAttribute  string
+	LDAPAdminGroup     string
+	LDAPMemberGroup    string
+
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       string
+	OIDCRoleClaim    string
+	OIDCAdminValue   string
+	OIDCMemberValue  string
+	OIDCLinkByEmail  bool
 }
 
 func NewConfig() *Config {
 	return &Config{
-		AppPort:        getEnv("APP_PORT", "3000"),
-		MongoURI:       getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		MongoDBName:    getEnv("MONGO_DB_NAME", "scripts_management"),
-		RootUsername:   getEnv("ROOT_USERNAME", "root"),
-		RootPassword:   getEnv("ROOT_PASSWORD", "root123"),
+		AppPort:            getEnv("APP_PORT", "3000"),
+		MongoURI:           getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDBName:        getEnv("MONGO_DB_NAME", "scripts_management"),
+		RootUsername:       getEnv("ROOT_USERNAME", "root"),
+		RootPassword:       getEnv("ROOT_PASSWORD", ""),
+		ShareSweepInterval: getIntervalEnv("SHARE_SWEEP_INTERVAL", time.Minute),
+		TrashRetentionDays: getIntEnv("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeInterval: getIntervalEnv("TRASH_PURGE_INTERVAL", time.Hour),
+		RunRetentionCount:  getIntEnv("RUN_RETENTION_COUNT", 0),
+		RunRetentionDays:   getIntEnv("RUN_RETENTION_DAYS", 0),
+		RunPruneInterval:   getIntervalEnv("RUN_PRUNE_INTERVAL", time.Hour),
+		JWTKeyFile:         getEnv("JWT_KEY_FILE", ""),
+		JWTKeySecret:       getEnv("JWT_KEY_SECRET", ""),
+		JWTKeyRotation:     getDurationEnv("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTKeyRefresh:      getIntervalEnv("JWT_KEY_REFRESH_INTERVAL", time.Minute),
+		RefreshTokenTTL:    getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		SignupMode:         getEnv("SIGNUP_MODE", "invite"),
+
+		PasswordMinLength:     getIntEnv("PASSWORD_MIN_LENGTH", 10),
+		PasswordMinClasses:    getIntEnv("PASSWORD_MIN_CLASSES", 3),
+		PasswordDenylistFile:  getEnv("PASSWORD_DENYLIST_FILE", ""),
+		LoginMaxAttempts:      getIntEnv("LOGIN_MAX_ATTEMPTS", 5),
+		LoginMaxAttemptsPerIP: getIntEnv("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+		LoginLockoutBase:      getDurationEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		LoginLockoutMax:       getDurationEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+		LoginFailureReset:     getDurationEnv("LOGIN_FAILURE_RESET", 24*time.Hour),
+		TOTPIssuer:            getEnv("TOTP_ISSUER", "Scripts Management"),
+
+		AuthProviders:      getEnv("AUTH_PROVIDERS", "local"),
+		LDAPURL:            getEnv("LDAP_URL", ""),
+		LDAPStartTLS:       getBoolEnv("LDAP_START_TLS", false),
+		LDAPBindDN:         getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:         getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(uid=%s)"),
+		LDAPGroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPEmailAttribute: getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPNameAttribute:  getEnv("LDAP_NAME_ATTRIBUTE", "displayName"),
+		LDAPAdminGroup:     getEnv("LDAP_ADMIN_GROUP", ""),
+		LDAPMemberGroup:    getEnv("LDAP_MEMBER_GROUP", ""),
+
+		OIDCIssuer:       getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:       getEnv("OIDC_SCOPES", "openid profile email"),
+		OIDCRoleClaim:    getEnv("OIDC_ROLE_CLAIM", "groups"),
+		OIDCAdminValue:   getEnv("OIDC_ADMIN_VALUE", ""),
+		OIDCMemberValue:  getEnv("OIDC_MEMBER_VALUE", ""),
+		OIDCLinkByEmail:  getBoolEnv("OIDC_LINK_BY_EMAIL", false),
 	}
 }
 
@@ -26,3 +120,33 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getIntervalEnv parses the period of a background job. Zero or negative
+// values fall back to the default, since they would make the ticker panic.
+func getIntervalEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := getDurationEnv(key, defaultValue); value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
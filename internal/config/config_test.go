@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetDurationEnv(t *testing.T) {
+	t.Setenv("TEST_INTERVAL", "30s")
+	if got := getDurationEnv("TEST_INTERVAL", time.Minute); got != 30*time.Second {
+		t.Errorf("getDurationEnv = %v, want 30s", got)
+	}
+
+	t.Setenv("TEST_INTERVAL", "often")
+	if got := getDurationEnv("TEST_INTERVAL", time.Minute); got != time.Minute {
+		t.Errorf("getDurationEnv with an invalid value = %v, want the default", got)
+	}
+
+	if got := getDurationEnv("TEST_UNSET_INTERVAL", time.Minute); got != time.Minute {
+		t.Errorf("getDurationEnv without a value = %v, want the default", got)
+	}
+}
+
+func TestGetIntervalEnv(t *testing.T) {
+	for _, value := range []string{"0s", "-5m"} {
+		t.Setenv("TEST_INTERVAL", value)
+		if got := getIntervalEnv("TEST_INTERVAL", time.Minute); got != time.Minute {
+			t.Errorf("getIntervalEnv(%q) = %v, want the default", value, got)
+		}
+	}
+
+	t.Setenv("TEST_INTERVAL", "5m")
+	if got := getIntervalEnv("TEST_INTERVAL", time.Minute); got != 5*time.Minute {
+		t.Errorf("getIntervalEnv = %v, want 5m", got)
+	}
+}
+
+func TestGetIntEnv(t *testing.T) {
+	t.Setenv("TEST_DAYS", "14")
+	if got := getIntEnv("TEST_DAYS", 30); got != 14 {
+		t.Errorf("getIntEnv = %d, want 14", got)
+	}
+
+	t.Setenv("TEST_DAYS", "two weeks")
+	if got := getIntEnv("TEST_DAYS", 30); got != 30 {
+		t.Errorf("getIntEnv with an invalid value = %d, want the default", got)
+	}
+}
@@ -3,8 +3,10 @@ package core
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 
@@ -18,15 +20,28 @@ import (
 )
 
 type App struct {
-	config         *config.Config
-	logger         *zap.Logger
-	fiber          *fiber.App
-	authHandler    *handlers.AuthHandler
-	userHandler    *handlers.UserHandler
-	userService    *services.UserService
-	scriptHandler  *handlers.ScriptHandler
-	processHandler *handlers.ProcessHandler
-	jwtManager     *utils.JWTManager
+	config              *config.Config
+	logger              *zap.Logger
+	fiber               *fiber.App
+	authHandler         *handlers.AuthHandler
+	userHandler         *handlers.UserHandler
+	userService         *services.UserService
+	authService         *services.AuthService
+	apiTokenService     *services.APITokenService
+	scriptHandler       *handlers.ScriptHandler
+	processHandler      *handlers.ProcessHandler
+	groupHandler        *handlers.GroupHandler
+	folderHandler       *handlers.FolderHandler
+	notificationHandler *handlers.NotificationHandler
+	apiTokenHandler     *handlers.APITokenHandler
+	invitationHandler   *handlers.InvitationHandler
+	auditHandler        *handlers.AuditHandler
+	twoFactorHandler    *handlers.TwoFactorHandler
+	shareExpiryService  *services.ShareExpiryService
+	trashPurgeService   *services.TrashPurgeService
+	runRetentionService *services.RunRetentionService
+	keyringService      *services.KeyringService
+	jwtManager          *utils.JWTManager
 }
 
 func NewApp(config *config.Config, logger *zap.Logger, db *mongo.Database) *App {
@@ -34,39 +49,163 @@ func NewApp(config *config.Config, logger *zap.Logger, db *mongo.Database) *App
 	userRepo := repository.NewUserRepository(db)
 	scriptRepo := repository.NewScriptRepository(db)
 	scriptShareRepo := repository.NewScriptShareRepository(db)
+	scriptRevisionRepo := repository.NewScriptRevisionRepository(db)
 	processRepo := repository.NewProcessRepository(db)
+	groupRepo := repository.NewGroupRepository(db)
+	folderRepo := repository.NewFolderRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	transactor := repository.NewTransactor(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	revokedTokenRepo := repository.NewRevokedTokenRepository(db)
+	apiTokenRepo := repository.NewAPITokenRepository(db)
+	invitationRepo := repository.NewInvitationRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	mfaChallengeRepo := repository.NewMFAChallengeRepository(db)
+	settingsRepo := repository.NewSettingsRepository(db)
+	oidcStateRepo := repository.NewOIDCStateRepository(db)
 
-	// Initialize JWT manager
-	jwtManager, err := utils.NewJWTManager()
-	if err != nil {
-		logger.Fatal("Failed to initialize JWT manager", zap.Error(err))
+	// Create indexes
+	indexCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := userRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create user indexes", zap.Error(err))
+	}
+	if err := scriptRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create script indexes", zap.Error(err))
+	}
+	if err := scriptRevisionRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create script revision indexes", zap.Error(err))
+	}
+	if err := processRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create process indexes", zap.Error(err))
+	}
+	if err := sessionRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create session indexes", zap.Error(err))
+	}
+	if err := revokedTokenRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create revoked token indexes", zap.Error(err))
+	}
+	if err := apiTokenRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create API token indexes", zap.Error(err))
+	}
+	if err := invitationRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create invitation indexes", zap.Error(err))
+	}
+	if err := auditRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create audit log indexes", zap.Error(err))
+	}
+	if err := loginAttemptRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create login attempt indexes", zap.Error(err))
+	}
+	if err := mfaChallengeRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create 2FA challenge indexes", zap.Error(err))
+	}
+	if err := oidcStateRepo.EnsureIndexes(indexCtx); err != nil {
+		logger.Error("Failed to create OIDC state indexes", zap.Error(err))
+	}
+
+	// Initialize JWT manager, from a static key file or the shared keyring
+	var jwtManager *utils.JWTManager
+	var keyringService *services.KeyringService
+	var err error
+	if config.JWTKeyFile != "" {
+		jwtManager, err = utils.NewJWTManagerFromPEM(config.JWTKeyFile)
+		if err != nil {
+			logger.Fatal("Failed to initialize JWT manager", zap.Error(err))
+		}
+	} else {
+		jwtManager, err = utils.NewJWTManager()
+		if err != nil {
+			logger.Fatal("Failed to initialize JWT manager", zap.Error(err))
+		}
+		keyringService, err = services.NewKeyringService(signingKeyRepo, jwtManager, config.JWTKeySecret, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize signing keyring", zap.Error(err))
+		}
+		if err := signingKeyRepo.EnsureIndexes(indexCtx); err != nil {
+			logger.Error("Failed to create signing key indexes", zap.Error(err))
+		}
+		if err := keyringService.Sync(indexCtx, config.JWTKeyRotation, config.JWTKeyRefresh); err != nil {
+			logger.Fatal("Failed to load signing keys", zap.Error(err))
+		}
 	}
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, jwtManager)
-	userService, err := services.NewUserService(userRepo, config, authService)
+	passwordPolicy, err := services.NewPasswordPolicy(config.PasswordMinLength, config.PasswordMinClasses, config.PasswordDenylistFile)
+	if err != nil {
+		logger.Fatal("Failed to initialize password policy", zap.Error(err))
+	}
+	loginThrottle := services.NewLoginThrottle(loginAttemptRepo, auditRepo, config)
+	authProviders, err := services.NewAuthProviders(userRepo, config)
+	if err != nil {
+		logger.Fatal("Failed to initialize auth providers", zap.Error(err))
+	}
+	twoFactorService := services.NewTwoFactorService(userRepo, mfaChallengeRepo, settingsRepo, auditRepo, authProviders, loginThrottle, config, logger)
+	oidcService, err := services.NewOIDCService(userRepo, oidcStateRepo, config)
+	if err != nil {
+		logger.Fatal("Failed to initialize single sign-on", zap.Error(err))
+	}
+	authService := services.NewAuthService(userRepo, sessionRepo, revokedTokenRepo, invitationRepo, transactor, jwtManager, authProviders, passwordPolicy, loginThrottle, twoFactorService, oidcService, config, logger)
+	apiTokenService := services.NewAPITokenService(apiTokenRepo, userRepo)
+	invitationService := services.NewInvitationService(invitationRepo)
+	auditService := services.NewAuditService(auditRepo)
+	// ScriptService stops running processes before deleting a script, and
+	// ProcessService authorizes runs through ScriptService
+	var processService *services.ProcessService
+	stopScriptProcesses := func(ctx context.Context, scriptID primitive.ObjectID) error {
+		return processService.StopScriptProcesses(ctx, scriptID)
+	}
+	scriptService := services.NewScriptService(scriptRepo, scriptShareRepo, scriptRevisionRepo, userRepo, groupRepo, folderRepo, processRepo, notificationRepo, transactor, stopScriptProcesses)
+	processService = services.NewProcessService(processRepo, scriptRepo, scriptService, logger)
+	userService, err := services.NewUserService(userRepo, config, authService, apiTokenService, scriptService, processService, loginThrottle, transactor)
 	if err != nil {
 		logger.Fatal("Failed to initialize user service", zap.Error(err))
 	}
-	scriptService := services.NewScriptService(scriptRepo, scriptShareRepo, userRepo)
-	processService := services.NewProcessService(processRepo, scriptRepo, scriptService, logger)
+	groupService := services.NewGroupService(groupRepo, userRepo, scriptShareRepo)
+	folderService := services.NewFolderService(folderRepo, scriptRepo, transactor)
+	notificationService := services.NewNotificationService(notificationRepo)
+	shareExpiryService := services.NewShareExpiryService(scriptShareRepo, scriptRepo, userRepo, groupRepo, notificationService, logger)
+	trashPurgeService := services.NewTrashPurgeService(scriptService, logger)
+	runRetentionService := services.NewRunRetentionService(processRepo, scriptRepo, logger)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
 	scriptHandler := handlers.NewScriptHandler(scriptService)
 	processHandler := handlers.NewProcessHandler(processService)
+	groupHandler := handlers.NewGroupHandler(groupService)
+	folderHandler := handlers.NewFolderHandler(folderService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokenService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
 
 	app := &App{
-		config:         config,
-		logger:         logger,
-		fiber:          fiber.New(),
-		authHandler:    authHandler,
-		userHandler:    userHandler,
-		userService:    userService,
-		scriptHandler:  scriptHandler,
-		processHandler: processHandler,
-		jwtManager:     jwtManager,
+		config:              config,
+		logger:              logger,
+		fiber:               fiber.New(),
+		authHandler:         authHandler,
+		userHandler:         userHandler,
+		userService:         userService,
+		authService:         authService,
+		apiTokenService:     apiTokenService,
+		scriptHandler:       scriptHandler,
+		processHandler:      processHandler,
+		groupHandler:        groupHandler,
+		folderHandler:       folderHandler,
+		notificationHandler: notificationHandler,
+		apiTokenHandler:     apiTokenHandler,
+		invitationHandler:   invitationHandler,
+		auditHandler:        auditHandler,
+		twoFactorHandler:    twoFactorHandler,
+		shareExpiryService:  shareExpiryService,
+		trashPurgeService:   trashPurgeService,
+		runRetentionService: runRetentionService,
+		keyringService:      keyringService,
+		jwtManager:          jwtManager,
 	}
 
 	// setup logger for apps
@@ -90,10 +229,18 @@ func (a *App) setupRoutes() {
 		return c.SendString("OK")
 	})
 
+	// Public keys for token verification
+	a.fiber.Get("/.well-known/jwks.json", a.authHandler.JWKS)
+
 	// Auth routes
 	auth := a.fiber.Group("/auth")
 	auth.Post("/login", a.authHandler.Login)
+	auth.Post("/login/2fa", a.authHandler.LoginMFA)
 	auth.Post("/signup", a.authHandler.Signup)
+	auth.Post("/refresh", a.authHandler.Refresh)
+	auth.Post("/logout", a.authHandler.Logout)
+	auth.Get("/oidc/login", a.authHandler.StartOIDCLogin)
+	auth.Post("/oidc/callback", a.authHandler.LoginOIDC)
 
 	// Initialize root account
 	if err := a.userService.InitRootAccount(context.Background()); err != nil {
@@ -101,32 +248,140 @@ func (a *App) setupRoutes() {
 	}
 
 	// User management routes
-	api := a.fiber.Group("/api", middleware.AuthMiddleware(a.jwtManager))
+	api := a.fiber.Group("/api", middleware.AuthMiddleware(a.jwtManager, a.authService, a.apiTokenService))
+
+	// Personal access tokens only reach routes guarded by one of their scopes
+	scriptsRead := middleware.ScopeAuth(models.ScopeScriptsRead)
+	scriptsWrite := middleware.ScopeAuth(models.ScopeScriptsWrite)
+	scriptsRun := middleware.ScopeAuth(models.ScopeScriptsRun)
+	processesRead := middleware.ScopeAuth(models.ScopeProcessesRead)
+	processesWrite := middleware.ScopeAuth(models.ScopeProcessesWrite)
+
+	// Profile of the current user
+	me := api.Group("/me", middleware.SessionAuth())
+	me.Get("/", a.userHandler.GetMe)
+	me.Patch("/", a.userHandler.UpdateMe)
+	me.Put("/password", a.userHandler.ChangeMyPassword)
+	me.Post("/2fa/setup", a.twoFactorHandler.Setup)
+	me.Post("/2fa/enable", a.twoFactorHandler.Enable)
+	me.Post("/2fa/disable", a.twoFactorHandler.Disable)
+	me.Post("/2fa/recovery-codes", a.twoFactorHandler.RegenerateRecoveryCodes)
 
 	// User management (Root and Admin only)
-	users := api.Group("/users")
+	users := api.Group("/users", middleware.SessionAuth())
+	users.Get("/", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.ListUsers)
 	users.Post("/", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.CreateUser)
+	users.Post("/service-accounts", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.CreateServiceAccount)
+	users.Get("/:id", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.GetUser)
+	users.Patch("/:id", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.UpdateUser)
 	users.Delete("/:id", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.DeleteUser)
 	users.Put("/:id/password", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.ChangePassword)
+	users.Post("/:id/reassign-scripts", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.userHandler.ReassignScripts)
+	users.Delete("/:id/2fa", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.twoFactorHandler.Reset)
+	users.Get("/:id/tokens", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.apiTokenHandler.ListUserTokens)
+	users.Post("/:id/tokens", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.apiTokenHandler.CreateUserToken)
+	users.Delete("/:id/tokens/:tokenId", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.apiTokenHandler.RevokeUserToken)
+
+	// Signup invitation routes (Root and Admin only)
+	invitations := api.Group("/invitations", middleware.SessionAuth(), middleware.RoleAuth(models.RoleRoot, models.RoleAdmin))
+	invitations.Get("/", a.invitationHandler.ListInvitations)
+	invitations.Post("/", a.invitationHandler.CreateInvitation)
+	invitations.Delete("/:id", a.invitationHandler.RevokeInvitation)
+
+	// Audit log (Root and Admin only)
+	api.Get("/audit", middleware.SessionAuth(), middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.auditHandler.ListEntries)
+
+	// Security settings (Root only)
+	settings := api.Group("/settings", middleware.SessionAuth(), middleware.RoleAuth(models.RoleRoot))
+	settings.Get("/security", a.twoFactorHandler.GetSecuritySettings)
+	settings.Put("/security", a.twoFactorHandler.UpdateSecuritySettings)
+
+	// Personal access token routes
+	tokens := api.Group("/tokens", middleware.SessionAuth())
+	tokens.Get("/", a.apiTokenHandler.ListTokens)
+	tokens.Post("/", a.apiTokenHandler.CreateToken)
+	tokens.Delete("/:tokenId", a.apiTokenHandler.RevokeToken)
+
+	// Group management routes
+	groups := api.Group("/groups", middleware.SessionAuth())
+	groups.Post("/", middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.groupHandler.CreateGroup)
+	groups.Get("/", a.groupHandler.ListGroups)
+	groups.Get("/:id", a.groupHandler.GetGroup)
+	groups.Put("/:id", a.groupHandler.UpdateGroup)
+	groups.Delete("/:id", a.groupHandler.DeleteGroup)
+	groups.Post("/:id/members", a.groupHandler.AddMember)
+	groups.Delete("/:id/members/:userId", a.groupHandler.RemoveMember)
+
+	// Folder routes
+	folders := api.Group("/folders")
+	folders.Get("/", scriptsRead, a.folderHandler.ListFolders)
+	folders.Post("/", scriptsWrite, a.folderHandler.CreateFolder)
+	folders.Put("/:id", scriptsWrite, a.folderHandler.UpdateFolder)
+	folders.Delete("/:id", scriptsWrite, a.folderHandler.DeleteFolder)
 
 	// Script management routes
 	scripts := api.Group("/scripts")
-	scripts.Post("/", a.scriptHandler.CreateScript)
-	scripts.Get("/", a.scriptHandler.GetUserScripts)
-	scripts.Get("/:id", a.scriptHandler.GetScript)
-	scripts.Put("/:id", a.scriptHandler.UpdateScript)
-	scripts.Delete("/:id", a.scriptHandler.DeleteScript)
-	scripts.Post("/:id/share", a.scriptHandler.ShareScript)
-	scripts.Delete("/:id/share/:userId", a.scriptHandler.RevokeShare)
+	scripts.Post("/", scriptsWrite, a.scriptHandler.CreateScript)
+	scripts.Get("/", scriptsRead, a.scriptHandler.GetUserScripts)
+	scripts.Get("/trash", scriptsRead, a.scriptHandler.ListTrash)
+	scripts.Get("/:id", scriptsRead, a.scriptHandler.GetScript)
+	scripts.Put("/:id", scriptsWrite, a.scriptHandler.UpdateScript)
+	scripts.Delete("/:id", scriptsWrite, a.scriptHandler.DeleteScript)
+	scripts.Post("/:id/transfer", scriptsWrite, a.scriptHandler.TransferScript)
+	scripts.Put("/:id/folder", scriptsWrite, a.scriptHandler.MoveScript)
+	scripts.Post("/:id/restore", scriptsWrite, a.scriptHandler.RestoreScript)
+	scripts.Delete("/:id/purge", scriptsWrite, middleware.RoleAuth(models.RoleRoot, models.RoleAdmin), a.scriptHandler.PurgeScript)
+	scripts.Post("/:id/share", scriptsWrite, a.scriptHandler.ShareScript)
+	scripts.Delete("/:id/share/:userId", scriptsWrite, a.scriptHandler.RevokeShare)
+	scripts.Delete("/:id/share/groups/:groupId", scriptsWrite, a.scriptHandler.RevokeGroupShare)
+	scripts.Get("/:id/shares", scriptsRead, a.scriptHandler.ListShares)
+	scripts.Post("/:id/shares/bulk", scriptsWrite, a.scriptHandler.BulkShare)
+	scripts.Post("/:id/check", scriptsWrite, a.scriptHandler.CheckScript)
+
+	// Script revision routes
+	scripts.Get("/:id/revisions", scriptsRead, a.scriptHandler.ListRevisions)
+	scripts.Get("/:id/revisions/diff", scriptsRead, a.scriptHandler.DiffRevisions)
+	scripts.Get("/:id/revisions/:revision", scriptsRead, a.scriptHandler.GetRevision)
+	scripts.Post("/:id/revisions/:revision/restore", scriptsWrite, a.scriptHandler.RestoreRevision)
+
+	// Script file routes
+	scripts.Get("/:id/files", scriptsRead, a.scriptHandler.ListFiles)
+	scripts.Get("/:id/files/*", scriptsRead, a.scriptHandler.GetFile)
+	scripts.Put("/:id/files/*", scriptsWrite, a.scriptHandler.WriteFile)
+	scripts.Delete("/:id/files/*", scriptsWrite, a.scriptHandler.DeleteFile)
 
 	// Process management routes
-	scripts.Post("/:id/run", a.processHandler.RunScript)
+	scripts.Post("/:id/run", scriptsRun, a.processHandler.RunScript)
+	scripts.Get("/:id/processes", processesRead, a.processHandler.GetScriptProcesses)
+	scripts.Put("/:id/retention", scriptsWrite, a.scriptHandler.SetRunRetention)
+	scripts.Delete("/:id/retention", scriptsWrite, a.scriptHandler.ClearRunRetention)
+
+	// Notification routes
+	notifications := api.Group("/notifications", middleware.SessionAuth())
+	notifications.Get("/", a.notificationHandler.GetNotifications)
+	notifications.Post("/:id/read", a.notificationHandler.MarkRead)
 
 	processes := api.Group("/processes")
-	processes.Get("/", a.processHandler.GetProcesses)
-	processes.Post("/:id/stop", a.processHandler.StopProcess)
+	processes.Get("/", processesRead, a.processHandler.GetProcesses)
+	processes.Post("/:id/stop", processesWrite, a.processHandler.StopProcess)
+	processes.Post("/:id/pin", processesWrite, a.processHandler.PinProcess)
+	processes.Delete("/:id/pin", processesWrite, a.processHandler.UnpinProcess)
 }
 
 func (a *App) Start() error {
+	// Background jobs
+	go a.shareExpiryService.Run(context.Background(), a.config.ShareSweepInterval)
+	if a.config.TrashRetentionDays > 0 {
+		retention := time.Duration(a.config.TrashRetentionDays) * 24 * time.Hour
+		go a.trashPurgeService.Run(context.Background(), a.config.TrashPurgeInterval, retention)
+	}
+	go a.runRetentionService.Run(context.Background(), a.config.RunPruneInterval, models.RunRetention{
+		MaxRuns:    a.config.RunRetentionCount,
+		MaxAgeDays: a.config.RunRetentionDays,
+	})
+	if a.keyringService != nil {
+		go a.keyringService.Run(context.Background(), a.config.JWTKeyRotation, a.config.JWTKeyRefresh)
+	}
+
 	return a.fiber.Listen(fmt.Sprintf(":%s", a.config.AppPort))
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"scripts-management/internal/models"
+	"scripts-management/internal/services"
+	"scripts-management/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APITokenHandler struct {
+	apiTokenService *services.APITokenService
+}
+
+func NewAPITokenHandler(apiTokenService *services.APITokenService) *APITokenHandler {
+	return &APITokenHandler{
+		apiTokenService: apiTokenService,
+	}
+}
+
+// ListTokens lists the tokens of the current user.
+func (h *APITokenHandler) ListTokens(c *fiber.Ctx) error {
+	user := c.Locals("user").(*utils.JWTClaims)
+	return h.listTokens(c, user.UserID)
+}
+
+// ListUserTokens lists the tokens of a service account.
+func (h *APITokenHandler) ListUserTokens(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	return h.listTokens(c, userID)
+}
+
+func (h *APITokenHandler) CreateToken(c *fiber.Ctx) error {
+	user := c.Locals("user").(*utils.JWTClaims)
+	return h.createToken(c, user.UserID)
+}
+
+func (h *APITokenHandler) CreateUserToken(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	return h.createToken(c, userID)
+}
+
+func (h *APITokenHandler) RevokeToken(c *fiber.Ctx) error {
+	user := c.Locals("user").(*utils.JWTClaims)
+	return h.revokeToken(c, user.UserID)
+}
+
+func (h *APITokenHandler) RevokeUserToken(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+	return h.revokeToken(c, userID)
+}
+
+func (h *APITokenHandler) listTokens(c *fiber.Ctx, userID primitive.ObjectID) error {
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	tokens, err := h.apiTokenService.ListTokens(c.Context(), currentUser, userID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+func (h *APITokenHandler) createToken(c *fiber.Ctx, userID primitive.ObjectID) error {
+	var req models.CreateAPITokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	token, err := h.apiTokenService.CreateToken(c.Context(), currentUser, userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(token)
+}
+
+func (h *APITokenHandler) revokeToken(c *fiber.Ctx, userID primitive.ObjectID) error {
+	tokenID, err := primitive.ObjectIDFromHex(c.Params("tokenId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid token ID",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.apiTokenService.RevokeToken(c.Context(), currentUser, userID, tokenID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Token revoked successfully",
+	})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+func (h *AuditHandler) ListEntries(c *fiber.Ctx) error {
+	filter := &models.AuditFilter{Action: models.AuditAction(c.Query("action"))}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user ID",
+			})
+		}
+		filter.UserID = &userID
+	}
+
+	page, err := parsePageRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	entries, err := h.auditService.ListEntries(c.Context(), filter, page)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(entries)
+}
@@ -1,12 +1,21 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"time"
+
 	"scripts-management/internal/models"
 	"scripts-management/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// oidcStateCookie binds a single sign-on login to the browser that started
+// it.
+const oidcStateCookie = "oidc_state"
+
 type AuthHandler struct {
 	authService *services.AuthService
 }
@@ -25,15 +34,138 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := h.authService.Login(c.Context(), &req)
+	tokens, err := h.authService.Login(c.Context(), &req, c.IP())
 	if err != nil {
+		var locked *services.LoginLockedError
+		if errors.As(err, &locked) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	return c.JSON(tokens)
+}
+
+func (h *AuthHandler) LoginMFA(c *fiber.Ctx) error {
+	var req models.MFALoginRequest
+	if err := c.BodyParser(&req); err != nil || req.MFAToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	tokens, err := h.authService.LoginMFA(c.Context(), &req, c.IP())
+	if err != nil {
+		var locked *services.LoginLockedError
+		if errors.As(err, &locked) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidMFAChallenge) || errors.Is(err, services.ErrInvalidTOTPCode) {
+			status = fiber.StatusUnauthorized
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+func (h *AuthHandler) StartOIDCLogin(c *fiber.Ctx) error {
+	response, err := h.authService.StartOIDCLogin(c.Context())
+	if err != nil {
+		status := fiber.StatusBadGateway
+		if errors.Is(err, services.ErrOIDCDisabled) {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setOIDCStateCookie(c, response.State, time.Time{})
+	return c.JSON(response)
+}
+
+func (h *AuthHandler) LoginOIDC(c *fiber.Ctx) error {
+	var req models.OIDCCallbackRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" || req.State == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+	// A state started in another browser must not log this one in
+	if subtle.ConstantTimeCompare([]byte(c.Cookies(oidcStateCookie)), []byte(req.State)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": services.ErrInvalidOIDCState.Error(),
+		})
+	}
+	setOIDCStateCookie(c, "", time.Now().Add(-time.Hour))
+
+	tokens, err := h.authService.LoginOIDC(c.Context(), &req)
+	if err != nil {
+		status := fiber.StatusUnauthorized
+		switch {
+		case errors.Is(err, services.ErrOIDCDisabled):
+			status = fiber.StatusNotFound
+		case errors.Is(err, services.ErrNoOIDCRole):
+			status = fiber.StatusForbidden
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	tokens, err := h.authService.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			status = fiber.StatusUnauthorized
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(tokens)
+}
+
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	if err := h.authService.Logout(c.Context(), req.RefreshToken); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"token": token,
+		"message": "Logged out successfully",
 	})
 }
 
@@ -45,8 +177,12 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.authService.CreateUser(c.Context(), &req); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	if err := h.authService.Signup(c.Context(), &req); err != nil {
+		status := fiber.StatusBadRequest
+		if errors.Is(err, services.ErrSignupDisabled) || errors.Is(err, services.ErrInvitationRequired) {
+			status = fiber.StatusForbidden
+		}
+		return c.Status(status).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -55,3 +191,21 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		"message": "User created successfully",
 	})
 }
+
+func (h *AuthHandler) JWKS(c *fiber.Ctx) error {
+	return c.JSON(h.authService.JWKS())
+}
+
+// setOIDCStateCookie stores the state of a single sign-on login in the
+// browser, or deletes it when expires is in the past.
+func setOIDCStateCookie(c *fiber.Ctx, state string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc",
+		Expires:  expires,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"scripts-management/internal/models"
+	"scripts-management/internal/services"
+	"scripts-management/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FolderHandler struct {
+	folderService *services.FolderService
+}
+
+func NewFolderHandler(folderService *services.FolderService) *FolderHandler {
+	return &FolderHandler{
+		folderService: folderService,
+	}
+}
+
+func (h *FolderHandler) ListFolders(c *fiber.Ctx) error {
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	folders, err := h.folderService.ListFolders(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(folders)
+}
+
+func (h *FolderHandler) CreateFolder(c *fiber.Ctx) error {
+	var req models.CreateFolderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	folder, err := h.folderService.CreateFolder(c.Context(), userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(folder)
+}
+
+func (h *FolderHandler) UpdateFolder(c *fiber.Ctx) error {
+	folderID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid folder ID",
+		})
+	}
+
+	var req models.UpdateFolderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	folder, err := h.folderService.UpdateFolder(c.Context(), userID, folderID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(folder)
+}
+
+func (h *FolderHandler) DeleteFolder(c *fiber.Ctx) error {
+	folderID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid folder ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if err := h.folderService.DeleteFolder(c.Context(), userID, folderID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Folder deleted successfully",
+	})
+}
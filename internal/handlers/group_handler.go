@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"scripts-management/internal/models"
+	"scripts-management/internal/services"
+	"scripts-management/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GroupHandler struct {
+	groupService *services.GroupService
+}
+
+func NewGroupHandler(groupService *services.GroupService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+	}
+}
+
+func (h *GroupHandler) CreateGroup(c *fiber.Ctx) error {
+	var req models.CreateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	group, err := h.groupService.CreateGroup(c.Context(), currentUser, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(group)
+}
+
+func (h *GroupHandler) ListGroups(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	groups, err := h.groupService.ListGroups(c.Context(), currentUser)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(groups)
+}
+
+func (h *GroupHandler) GetGroup(c *fiber.Ctx) error {
+	groupID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	group, err := h.groupService.GetGroup(c.Context(), currentUser, groupID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(group)
+}
+
+func (h *GroupHandler) UpdateGroup(c *fiber.Ctx) error {
+	groupID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	var req models.UpdateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	group, err := h.groupService.UpdateGroup(c.Context(), currentUser, groupID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(group)
+}
+
+func (h *GroupHandler) DeleteGroup(c *fiber.Ctx) error {
+	groupID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.groupService.DeleteGroup(c.Context(), currentUser, groupID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Group deleted successfully",
+	})
+}
+
+func (h *GroupHandler) AddMember(c *fiber.Ctx) error {
+	groupID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	var req models.GroupMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.groupService.AddMember(c.Context(), currentUser, groupID, req.UserID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member added successfully",
+	})
+}
+
+func (h *GroupHandler) RemoveMember(c *fiber.Ctx) error {
+	groupID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.groupService.RemoveMember(c.Context(), currentUser, groupID, c.Params("userId")); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member removed successfully",
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"scripts-management/internal/models"
+	"scripts-management/internal/services"
+	"scripts-management/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+}
+
+func NewInvitationHandler(invitationService *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+	}
+}
+
+func (h *InvitationHandler) ListInvitations(c *fiber.Ctx) error {
+	invitations, err := h.invitationService.ListInvitations(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(invitations)
+}
+
+func (h *InvitationHandler) CreateInvitation(c *fiber.Ctx) error {
+	var req models.CreateInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	invitation, err := h.invitationService.CreateInvitation(c.Context(), currentUser, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	invitationID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid invitation ID",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.invitationService.RevokeInvitation(c.Context(), currentUser, invitationID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Invitation revoked successfully",
+	})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"scripts-management/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parsePageRequest reads ?cursor= and ?limit= of a cursor-paginated listing.
+func parsePageRequest(c *fiber.Ctx) (models.PageRequest, error) {
+	page := models.PageRequest{Cursor: c.Query("cursor")}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return page, errors.New("invalid limit")
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+// parseSortOrder reads ?order=, falling back to defaultOrder when it is absent.
+func parseSortOrder(c *fiber.Ctx, defaultOrder models.SortOrder) (models.SortOrder, error) {
+	switch order := models.SortOrder(c.Query("order")); order {
+	case "":
+		return defaultOrder, nil
+	case models.SortAsc, models.SortDesc:
+		return order, nil
+	default:
+		return "", errors.New("invalid order, expected asc or desc")
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// withQuery runs fn in the context of a request with the given query string.
+func withQuery(t *testing.T, query string, fn func(c *fiber.Ctx)) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		fn(c)
+		return nil
+	})
+	if _, err := app.Test(httptest.NewRequest("GET", "/?"+query, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	withQuery(t, "cursor=abc&limit=20", func(c *fiber.Ctx) {
+		page, err := parsePageRequest(c)
+		if err != nil || page.Cursor != "abc" || page.Limit != 20 {
+			t.Errorf("parsePageRequest = %+v, %v", page, err)
+		}
+	})
+	withQuery(t, "", func(c *fiber.Ctx) {
+		page, err := parsePageRequest(c)
+		if err != nil || page != (models.PageRequest{}) {
+			t.Errorf("parsePageRequest without query = %+v, %v", page, err)
+		}
+	})
+	for _, query := range []string{"limit=0", "limit=-1", "limit=ten"} {
+		withQuery(t, query, func(c *fiber.Ctx) {
+			if _, err := parsePageRequest(c); err == nil {
+				t.Errorf("parsePageRequest accepted %s", query)
+			}
+		})
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    models.SortOrder
+		wantErr bool
+	}{
+		{query: "", want: models.SortDesc},
+		{query: "order=asc", want: models.SortAsc},
+		{query: "order=desc", want: models.SortDesc},
+		{query: "order=random", wantErr: true},
+	}
+	for _, tt := range tests {
+		withQuery(t, tt.query, func(c *fiber.Ctx) {
+			got, err := parseSortOrder(c, models.SortDesc)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseSortOrder(%q) = %q, %v", tt.query, got, err)
+			}
+		})
+	}
+}
+
+func TestParseTimeQuery(t *testing.T) {
+	withQuery(t, "from=2024-05-06T07:08:09Z", func(c *fiber.Ctx) {
+		got, err := parseTimeQuery(c, "from")
+		want := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+		if err != nil || got == nil || !got.Equal(want) {
+			t.Errorf("parseTimeQuery = %v, %v, want %v", got, err, want)
+		}
+	})
+	withQuery(t, "", func(c *fiber.Ctx) {
+		if got, err := parseTimeQuery(c, "from"); got != nil || err != nil {
+			t.Errorf("parseTimeQuery without value = %v, %v", got, err)
+		}
+	})
+	withQuery(t, "from=yesterday", func(c *fiber.Ctx) {
+		if _, err := parseTimeQuery(c, "from"); err == nil {
+			t.Error("parseTimeQuery accepted yesterday")
+		}
+	})
+}
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
 	"scripts-management/internal/services"
 	"scripts-management/pkg/utils"
 
@@ -41,7 +46,19 @@ func (h *ProcessHandler) RunScript(c *fiber.Ctx) error {
 		})
 	}
 
-	return h.processService.RunScript(c, userID, scriptID, req.Args)
+	trigger := models.ProcessTriggerManual
+	if user.IsAPIToken() {
+		trigger = models.ProcessTriggerAPI
+	}
+
+	process, err := h.processService.RunScript(c.Context(), userID, scriptID, req.Args, trigger)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.processService.StreamProcessOutput(c, process.ID)
 }
 
 func (h *ProcessHandler) StopProcess(c *fiber.Ctx) error {
@@ -71,6 +88,40 @@ func (h *ProcessHandler) StopProcess(c *fiber.Ctx) error {
 	})
 }
 
+func (h *ProcessHandler) PinProcess(c *fiber.Ctx) error {
+	return h.setPinned(c, true)
+}
+
+func (h *ProcessHandler) UnpinProcess(c *fiber.Ctx) error {
+	return h.setPinned(c, false)
+}
+
+func (h *ProcessHandler) setPinned(c *fiber.Ctx, pinned bool) error {
+	processID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid process ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	process, err := h.processService.PinProcess(c.Context(), userID, processID, pinned)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(process)
+}
+
 func (h *ProcessHandler) GetProcesses(c *fiber.Ctx) error {
 	user := c.Locals("user").(*utils.JWTClaims)
 	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
@@ -80,12 +131,115 @@ func (h *ProcessHandler) GetProcesses(c *fiber.Ctx) error {
 		})
 	}
 
-	processes, err := h.processService.GetProcesses(c.Context(), userID)
+	filter, sort, page, err := parseProcessQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if value := c.Query("script_id"); value != "" {
+		scriptID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid script ID",
+			})
+		}
+		filter.ScriptID = &scriptID
+	}
+
+	processes, err := h.processService.GetProcesses(c.Context(), userID, filter, sort, page)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(processes)
+}
+
+func (h *ProcessHandler) GetScriptProcesses(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	filter, sort, page, err := parseProcessQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	processes, err := h.processService.GetProcessesByScriptID(c.Context(), userID, scriptID, filter, sort, page)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
 	return c.JSON(processes)
 }
+
+// parseProcessQuery reads the ?status=, ?trigger=, ?from=, ?to= filters and
+// the ?sort=, ?order=, ?cursor=, ?limit= options of a process listing. Dates
+// are RFC 3339 and bound the start time of the processes.
+func parseProcessQuery(c *fiber.Ctx) (*models.ProcessFilter, models.ProcessSort, models.PageRequest, error) {
+	filter := &models.ProcessFilter{
+		Status:  models.ProcessStatus(c.Query("status")),
+		Trigger: models.ProcessTrigger(c.Query("trigger")),
+	}
+	sort := models.ProcessSort{Field: models.ProcessSortField(c.Query("sort", string(models.ProcessSortStartTime)))}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return nil, sort, models.PageRequest{}, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return nil, sort, models.PageRequest{}, err
+	}
+
+	if sort.Field != models.ProcessSortStartTime && sort.Field != models.ProcessSortStatus {
+		return nil, sort, models.PageRequest{}, fmt.Errorf("invalid sort field: %s", sort.Field)
+	}
+	order, err := parseSortOrder(c, models.SortDesc)
+	if err != nil {
+		return nil, sort, models.PageRequest{}, err
+	}
+	sort.Order = order
+
+	page, err := parsePageRequest(c)
+	return filter, sort, page, err
+}
+
+func parseTimeQuery(c *fiber.Ctx, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s date, expected RFC 3339", name)
+	}
+	return &t, nil
+}
@@ -1,7 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
 	"scripts-management/internal/services"
 	"scripts-management/pkg/utils"
 
@@ -37,11 +43,15 @@ func (h *ScriptHandler) CreateScript(c *fiber.Ctx) error {
 
 	script, err := h.scriptService.CreateScript(c.Context(), userID, &req)
 	if err != nil {
+		if checkErr, ok := asScriptCheckError(err); ok {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(checkErr)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	setScriptETag(c, script)
 	return c.Status(fiber.StatusCreated).JSON(script)
 }
 
@@ -68,6 +78,7 @@ func (h *ScriptHandler) GetScript(c *fiber.Ctx) error {
 		})
 	}
 
+	setScriptETag(c, script)
 	return c.JSON(script)
 }
 
@@ -80,7 +91,26 @@ func (h *ScriptHandler) GetUserScripts(c *fiber.Ctx) error {
 		})
 	}
 
-	scripts, err := h.scriptService.GetUserScripts(c.Context(), userID)
+	filter, err := parseScriptFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	sort, page, err := parseScriptPage(c, filter)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	scripts, err := h.scriptService.GetUserScripts(c.Context(), userID, filter, sort, page)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -90,6 +120,82 @@ func (h *ScriptHandler) GetUserScripts(c *fiber.Ctx) error {
 	return c.JSON(scripts)
 }
 
+func (h *ScriptHandler) SetRunRetention(c *fiber.Ctx) error {
+	var retention models.RunRetention
+	if err := c.BodyParser(&retention); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	return h.updateRunRetention(c, &retention)
+}
+
+func (h *ScriptHandler) ClearRunRetention(c *fiber.Ctx) error {
+	return h.updateRunRetention(c, nil)
+}
+
+func (h *ScriptHandler) updateRunRetention(c *fiber.Ctx, retention *models.RunRetention) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	script, err := h.scriptService.SetRunRetention(c.Context(), userID, scriptID, retention)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setScriptETag(c, script)
+	return c.JSON(script)
+}
+
+func (h *ScriptHandler) MoveScript(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	var req models.MoveScriptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	script, err := h.scriptService.MoveScript(c.Context(), userID, scriptID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setScriptETag(c, script)
+	return c.JSON(script)
+}
+
 func (h *ScriptHandler) UpdateScript(c *fiber.Ctx) error {
 	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
@@ -113,13 +219,27 @@ func (h *ScriptHandler) UpdateScript(c *fiber.Ctx) error {
 		})
 	}
 
-	script, err := h.scriptService.UpdateScript(c.Context(), userID, scriptID, &req)
+	expectedVersion, err := parseIfMatch(c.Get(fiber.HeaderIfMatch))
 	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid If-Match header",
+		})
+	}
+
+	script, err := h.scriptService.UpdateScript(c.Context(), userID, scriptID, &req, expectedVersion)
+	if err != nil {
+		if checkErr, ok := asScriptCheckError(err); ok {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(checkErr)
+		}
+		if status, conflict, ok := asScriptConflictError(c, err); ok {
+			return c.Status(status).JSON(conflict)
+		}
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	setScriptETag(c, script)
 	return c.JSON(script)
 }
 
@@ -150,6 +270,99 @@ func (h *ScriptHandler) DeleteScript(c *fiber.Ctx) error {
 	})
 }
 
+func (h *ScriptHandler) ListTrash(c *fiber.Ctx) error {
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	scripts, err := h.scriptService.ListTrash(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(scripts)
+}
+
+func (h *ScriptHandler) RestoreScript(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	script, err := h.scriptService.RestoreScript(c.Context(), userID, scriptID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setScriptETag(c, script)
+	return c.JSON(script)
+}
+
+func (h *ScriptHandler) PurgeScript(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	if err := h.scriptService.PurgeScript(c.Context(), user, scriptID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Script purged successfully",
+	})
+}
+
+func (h *ScriptHandler) TransferScript(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	var req models.TransferScriptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	script, err := h.scriptService.TransferScript(c.Context(), user, scriptID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setScriptETag(c, script)
+	return c.JSON(script)
+}
+
 func (h *ScriptHandler) ShareScript(c *fiber.Ctx) error {
 	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
@@ -173,7 +386,7 @@ func (h *ScriptHandler) ShareScript(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.scriptService.ShareScript(c.Context(), userID, scriptID, req.UserID); err != nil {
+	if err := h.scriptService.ShareScript(c.Context(), userID, scriptID, &req); err != nil {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -217,3 +430,488 @@ func (h *ScriptHandler) RevokeShare(c *fiber.Ctx) error {
 		"message": "Share revoked successfully",
 	})
 }
+
+func (h *ScriptHandler) ListFiles(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	files, err := h.scriptService.ListFiles(c.Context(), userID, scriptID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(files)
+}
+
+func (h *ScriptHandler) GetFile(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	file, err := h.scriptService.GetFile(c.Context(), userID, scriptID, c.Params("*"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(file)
+}
+
+func (h *ScriptHandler) WriteFile(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	var req models.WriteScriptFileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	file, err := h.scriptService.WriteFile(c.Context(), userID, scriptID, c.Params("*"), &req)
+	if err != nil {
+		if checkErr, ok := asScriptCheckError(err); ok {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(checkErr)
+		}
+		if status, conflict, ok := asScriptConflictError(c, err); ok {
+			return c.Status(status).JSON(conflict)
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(file)
+}
+
+func (h *ScriptHandler) DeleteFile(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if err := h.scriptService.DeleteFile(c.Context(), userID, scriptID, c.Params("*")); err != nil {
+		if status, conflict, ok := asScriptConflictError(c, err); ok {
+			return c.Status(status).JSON(conflict)
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "File deleted successfully",
+	})
+}
+
+func (h *ScriptHandler) CheckScript(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	result, err := h.scriptService.CheckScript(c.Context(), userID, scriptID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// asScriptConflictError builds the response of an update made against a stale
+// version: 412 when the client sent a stale If-Match, 409 when a concurrent
+// write won the race. The body carries the current server copy.
+func asScriptConflictError(c *fiber.Ctx, err error) (int, fiber.Map, bool) {
+	var conflictErr *services.ScriptConflictError
+	if !errors.As(err, &conflictErr) {
+		return 0, nil, false
+	}
+
+	status := fiber.StatusConflict
+	if conflictErr.PreconditionFailed {
+		status = fiber.StatusPreconditionFailed
+	}
+
+	setScriptETag(c, conflictErr.Current)
+	return status, fiber.Map{
+		"error":   conflictErr.Error(),
+		"current": conflictErr.Current,
+	}, true
+}
+
+func setScriptETag(c *fiber.Ctx, script *models.Script) {
+	c.Set(fiber.HeaderETag, fmt.Sprintf(`"%d"`, script.Version))
+}
+
+// parseIfMatch returns the script version required by an If-Match header,
+// or nil when the header is absent or "*".
+func parseIfMatch(header string) (*int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// asScriptCheckError builds the response body of a strict save rejected by the checker.
+func asScriptCheckError(err error) (fiber.Map, bool) {
+	var checkErr *services.ScriptCheckError
+	if !errors.As(err, &checkErr) {
+		return nil, false
+	}
+
+	return fiber.Map{
+		"error":       checkErr.Error(),
+		"diagnostics": checkErr.Result.Diagnostics,
+	}, true
+}
+
+func (h *ScriptHandler) ListRevisions(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	revisions, err := h.scriptService.ListRevisions(c.Context(), userID, scriptID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(revisions)
+}
+
+func (h *ScriptHandler) GetRevision(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	revision, err := c.ParamsInt("revision")
+	if err != nil || revision < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	rev, err := h.scriptService.GetRevision(c.Context(), userID, scriptID, revision)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(rev)
+}
+
+func (h *ScriptHandler) DiffRevisions(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	from := c.QueryInt("from")
+	to := c.QueryInt("to")
+	if from < 1 || to < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameters from and to are required",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	diff, err := h.scriptService.DiffRevisions(c.Context(), userID, scriptID, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(diff)
+}
+
+func (h *ScriptHandler) RestoreRevision(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	revision, err := c.ParamsInt("revision")
+	if err != nil || revision < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid revision",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	script, err := h.scriptService.RestoreRevision(c.Context(), userID, scriptID, revision)
+	if err != nil {
+		if status, conflict, ok := asScriptConflictError(c, err); ok {
+			return c.Status(status).JSON(conflict)
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(script)
+}
+
+func (h *ScriptHandler) RevokeGroupShare(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if err := h.scriptService.RevokeGroupShare(c.Context(), userID, scriptID, c.Params("groupId")); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Share revoked successfully",
+	})
+}
+
+func (h *ScriptHandler) ListShares(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	shares, err := h.scriptService.ListShares(c.Context(), userID, scriptID)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(shares)
+}
+
+func (h *ScriptHandler) BulkShare(c *fiber.Ctx) error {
+	scriptID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid script ID",
+		})
+	}
+
+	var req models.BulkShareRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	user := c.Locals("user").(*utils.JWTClaims)
+	userID, err := primitive.ObjectIDFromHex(user.UserID.Hex())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	results, err := h.scriptService.BulkShare(c.Context(), userID, scriptID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(results)
+}
+
+// parseScriptFilter reads the ?q=, ?folder_id=, ?tag=, ?type= and ?scope=
+// filters of the script listing. folder_id=root selects scripts outside any folder.
+func parseScriptFilter(c *fiber.Ctx) (*models.ScriptFilter, error) {
+	filter := &models.ScriptFilter{
+		Query: strings.TrimSpace(c.Query("q")),
+		Tag:   c.Query("tag"),
+		Type:  models.ScriptType(c.Query("type")),
+		Scope: models.ScriptScope(c.Query("scope")),
+	}
+
+	switch folderID := c.Query("folder_id"); folderID {
+	case "":
+	case "root":
+		root := primitive.NilObjectID
+		filter.FolderID = &root
+	default:
+		id, err := primitive.ObjectIDFromHex(folderID)
+		if err != nil {
+			return nil, errors.New("invalid folder ID")
+		}
+		filter.FolderID = &id
+	}
+
+	if filter.Type != "" && filter.Type != models.ScriptTypePython && filter.Type != models.ScriptTypeGolang {
+		return nil, errors.New("invalid script type")
+	}
+
+	switch filter.Scope {
+	case models.ScriptScopeAll, models.ScriptScopeOwned, models.ScriptScopeShared:
+	default:
+		return nil, errors.New("invalid scope, expected owned or shared")
+	}
+
+	return filter, nil
+}
+
+// parseScriptPage reads ?sort=, ?order=, ?cursor= and ?limit= of the script
+// listing. Searches are sorted by relevance and other listings by last update
+// unless a sort is given.
+func parseScriptPage(c *fiber.Ctx, filter *models.ScriptFilter) (models.ScriptSort, models.PageRequest, error) {
+	sort := models.ScriptSort{Field: models.ScriptSortField(c.Query("sort"))}
+
+	if sort.Field == "" {
+		sort.Field = models.ScriptSortUpdatedAt
+		if filter.Query != "" {
+			sort.Field = models.ScriptSortRelevance
+		}
+	}
+	if !sort.Field.IsValid() {
+		return sort, models.PageRequest{}, fmt.Errorf("invalid sort field: %s", sort.Field)
+	}
+	if sort.Field == models.ScriptSortRelevance && filter.Query == "" {
+		return sort, models.PageRequest{}, errors.New("relevance sort requires a search query")
+	}
+
+	// Names and types read naturally A to Z, dates and scores newest or best first
+	defaultOrder := models.SortDesc
+	if sort.Field == models.ScriptSortName || sort.Field == models.ScriptSortType {
+		defaultOrder = models.SortAsc
+	}
+	order, err := parseSortOrder(c, defaultOrder)
+	if err != nil {
+		return sort, models.PageRequest{}, err
+	}
+	sort.Order = order
+
+	page, err := parsePageRequest(c)
+	return sort, page, err
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import "testing"
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int
+		none    bool
+		wantErr bool
+	}{
+		{header: "", none: true},
+		{header: "*", none: true},
+		{header: `"3"`, want: 3},
+		{header: ` W/"12" `, want: 12},
+		{header: "7", want: 7},
+		{header: `"abc"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseIfMatch(tt.header)
+		switch {
+		case tt.wantErr:
+			if err == nil {
+				t.Errorf("parseIfMatch(%q) = %v, want an error", tt.header, got)
+			}
+		case err != nil:
+			t.Errorf("parseIfMatch(%q): %v", tt.header, err)
+		case tt.none:
+			if got != nil {
+				t.Errorf("parseIfMatch(%q) = %d, want no version", tt.header, *got)
+			}
+		case got == nil || *got != tt.want:
+			t.Errorf("parseIfMatch(%q) = %v, want %d", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/services"
+	"scripts-management/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+	}
+}
+
+func (h *TwoFactorHandler) Setup(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	setup, err := h.twoFactorService.Setup(c.Context(), currentUser.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(setup)
+}
+
+func (h *TwoFactorHandler) Enable(c *fiber.Ctx) error {
+	var req models.TOTPCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	recoveryCodes, err := h.twoFactorService.Enable(c.Context(), currentUser.UserID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return c.JSON(models.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func (h *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	var req models.DisableTOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.twoFactorService.Disable(c.Context(), currentUser.UserID, &req); err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "2FA disabled successfully",
+	})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req models.TOTPCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	recoveryCodes, err := h.twoFactorService.RegenerateRecoveryCodes(c.Context(), currentUser.UserID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return c.JSON(models.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func (h *TwoFactorHandler) Reset(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.twoFactorService.Reset(c.Context(), currentUser, userID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "2FA reset successfully",
+	})
+}
+
+func (h *TwoFactorHandler) GetSecuritySettings(c *fiber.Ctx) error {
+	settings, err := h.twoFactorService.GetSecuritySettings(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+func (h *TwoFactorHandler) UpdateSecuritySettings(c *fiber.Ctx) error {
+	var req models.SecuritySettings
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	settings, err := h.twoFactorService.UpdateSecuritySettings(c.Context(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// twoFactorError answers 401 for a wrong code and 400 otherwise.
+func twoFactorError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	if errors.Is(err, services.ErrInvalidTOTPCode) {
+		status = fiber.StatusUnauthorized
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+
 	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
 	"scripts-management/internal/services"
 	"scripts-management/pkg/utils"
 
@@ -39,6 +44,178 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	})
 }
 
+func (h *UserHandler) CreateServiceAccount(c *fiber.Ctx) error {
+	var req models.CreateServiceAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	user, err := h.userService.CreateServiceAccount(c.Context(), currentUser, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	filter := &models.UserFilter{
+		Query: c.Query("q"),
+		Role:  models.UserRole(c.Query("role")),
+	}
+	if value := c.Query("disabled"); value != "" {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid disabled filter",
+			})
+		}
+		filter.Disabled = &disabled
+	}
+
+	sort := models.UserSort{Field: models.UserSortField(c.Query("sort", string(models.UserSortUsername)))}
+	if sort.Field != models.UserSortUsername && sort.Field != models.UserSortCreatedAt {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("invalid sort field: %s", sort.Field),
+		})
+	}
+	order, err := parseSortOrder(c, models.SortAsc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	sort.Order = order
+
+	page, err := parsePageRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	users, err := h.userService.ListUsers(c.Context(), filter, sort, page)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(users)
+}
+
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	user, err := h.userService.GetUser(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(user)
+}
+
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req models.UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	user, err := h.userService.UpdateUser(c.Context(), currentUser, userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(user)
+}
+
+// GetMe returns the profile of the current user.
+func (h *UserHandler) GetMe(c *fiber.Ctx) error {
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	user, err := h.userService.GetUser(c.Context(), currentUser.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(user)
+}
+
+func (h *UserHandler) UpdateMe(c *fiber.Ctx) error {
+	var req models.UpdateProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	user, err := h.userService.UpdateProfile(c.Context(), currentUser.UserID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(user)
+}
+
+func (h *UserHandler) ChangeMyPassword(c *fiber.Ctx) error {
+	var req models.ChangeOwnPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	if err := h.userService.ChangeOwnPassword(c.Context(), currentUser, &req, c.IP()); err != nil {
+		var locked *services.LoginLockedError
+		if errors.As(err, &locked) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
+
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
@@ -47,8 +224,19 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Scripts of the deleted user go to ?reassign_to=, or to the current user
+	var reassignTo primitive.ObjectID
+	if value := c.Query("reassign_to"); value != "" {
+		reassignTo, err = primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid reassign_to user ID",
+			})
+		}
+	}
+
 	currentUser := c.Locals("user").(*utils.JWTClaims)
-	if err := h.userService.DeleteUser(c.Context(), currentUser, userID); err != nil {
+	if err := h.userService.DeleteUser(c.Context(), currentUser, userID, reassignTo); err != nil {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -59,6 +247,42 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	})
 }
 
+// ReassignScripts moves every script of a user to another user, keeping
+// the user itself.
+func (h *UserHandler) ReassignScripts(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req models.ReassignScriptsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request",
+		})
+	}
+	reassignTo, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user_id",
+		})
+	}
+
+	currentUser := c.Locals("user").(*utils.JWTClaims)
+	moved, err := h.userService.ReassignScripts(c.Context(), currentUser, userID, reassignTo)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"reassigned": moved,
+	})
+}
+
 func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
 	userID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
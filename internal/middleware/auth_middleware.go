@@ -1,14 +1,28 @@
 package middleware
 
 import (
+	"context"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"scripts-management/internal/models"
 	"scripts-management/pkg/utils"
 )
 
-func AuthMiddleware(jwtManager *utils.JWTManager) fiber.Handler {
+// TokenRevocationChecker reports whether an access token ID (jti) was
+// denylisted, or its user disabled since the token was issued.
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	IsUserDisabled(ctx context.Context, userID primitive.ObjectID) (bool, error)
+}
+
+// APITokenAuthenticator resolves a personal access token to its claims.
+type APITokenAuthenticator interface {
+	AuthenticateAPIToken(ctx context.Context, token string) (*utils.JWTClaims, error)
+}
+
+func AuthMiddleware(jwtManager *utils.JWTManager, revocations TokenRevocationChecker, apiTokens APITokenAuthenticator) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -18,6 +32,18 @@ func AuthMiddleware(jwtManager *utils.JWTManager) fiber.Handler {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+		if strings.HasPrefix(tokenString, utils.APITokenPrefix) {
+			claims, err := apiTokens.AuthenticateAPIToken(c.Context(), tokenString)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Invalid token",
+				})
+			}
+
+			c.Locals("user", claims)
+			return c.Next()
+		}
+
 		claims, err := jwtManager.ValidateToken(tokenString)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -25,6 +51,30 @@ func AuthMiddleware(jwtManager *utils.JWTManager) fiber.Handler {
 			})
 		}
 
+		revoked, err := revocations.IsTokenRevoked(c.Context(), claims.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check token",
+			})
+		}
+		if revoked {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Token has been revoked",
+			})
+		}
+
+		disabled, err := revocations.IsUserDisabled(c.Context(), claims.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check token",
+			})
+		}
+		if disabled {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Account is disabled",
+			})
+		}
+
 		c.Locals("user", claims)
 		return c.Next()
 	}
@@ -49,4 +99,31 @@ func RoleAuth(allowedRoles ...models.UserRole) fiber.Handler {
 			"error": "Insufficient permissions",
 		})
 	}
+}
+
+// ScopeAuth rejects personal access tokens lacking scope. Sessions are not
+// scoped and always pass.
+func ScopeAuth(scope models.TokenScope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*utils.JWTClaims)
+		if !user.HasScope(string(scope)) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Token is missing scope " + string(scope),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// SessionAuth rejects personal access tokens, for routes no scope covers.
+func SessionAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*utils.JWTClaims)
+		if user.IsAPIToken() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "API tokens cannot access this resource",
+			})
+		}
+		return c.Next()
+	}
 }
\ No newline at end of file
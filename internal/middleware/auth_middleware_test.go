@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"scripts-management/internal/models"
+	"scripts-management/pkg/utils"
+)
+
+// fakeRevocations denylists the token IDs and disables the users of its
+// maps.
+type fakeRevocations struct {
+	revoked  map[string]bool
+	disabled map[primitive.ObjectID]bool
+	err      error
+}
+
+func (f *fakeRevocations) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	return f.revoked[tokenID], f.err
+}
+
+func (f *fakeRevocations) IsUserDisabled(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	return f.disabled[userID], f.err
+}
+
+// fakeAPITokens accepts a single personal access token.
+type fakeAPITokens struct {
+	token  string
+	claims *utils.JWTClaims
+}
+
+func (f *fakeAPITokens) AuthenticateAPIToken(ctx context.Context, token string) (*utils.JWTClaims, error) {
+	if token != f.token {
+		return nil, errors.New("invalid API token")
+	}
+	return f.claims, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	jwtManager, err := utils.NewJWTManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, claims, err := jwtManager.GenerateToken(primitive.NewObjectID(), "alice", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		revocations *fakeRevocations
+		want        int
+	}{
+		{"valid token", "Bearer " + token, &fakeRevocations{}, fiber.StatusOK},
+		{"missing header", "", &fakeRevocations{}, fiber.StatusUnauthorized},
+		{"invalid token", "Bearer nope", &fakeRevocations{}, fiber.StatusUnauthorized},
+		{"revoked token", "Bearer " + token, &fakeRevocations{revoked: map[string]bool{claims.ID: true}}, fiber.StatusUnauthorized},
+		{"disabled user", "Bearer " + token, &fakeRevocations{disabled: map[primitive.ObjectID]bool{claims.UserID: true}}, fiber.StatusUnauthorized},
+		{"denylist unavailable", "Bearer " + token, &fakeRevocations{err: errors.New("timeout")}, fiber.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", AuthMiddleware(jwtManager, tt.revocations, &fakeAPITokens{}), func(c *fiber.Ctx) error {
+				if c.Locals("user").(*utils.JWTClaims).ID != claims.ID {
+					t.Error("claims were not stored in the context")
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareAPIToken(t *testing.T) {
+	jwtManager, err := utils.NewJWTManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiTokens := &fakeAPITokens{
+		token:  utils.APITokenPrefix + "secret",
+		claims: &utils.JWTClaims{Username: "ci", APITokenID: primitive.NewObjectID()},
+	}
+	// API tokens are not JWTs and never reach the denylist
+	revocations := &fakeRevocations{err: errors.New("not called")}
+
+	app := fiber.New()
+	app.Get("/", AuthMiddleware(jwtManager, revocations, apiTokens), func(c *fiber.Ctx) error {
+		if c.Locals("user") != apiTokens.claims {
+			t.Error("API token claims were not stored in the context")
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for token, want := range map[string]int{
+		utils.APITokenPrefix + "secret": fiber.StatusOK,
+		utils.APITokenPrefix + "other":  fiber.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s: status = %d, want %d", token, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestScopeAndSessionAuth(t *testing.T) {
+	session := &utils.JWTClaims{Username: "alice"}
+	readToken := &utils.JWTClaims{
+		Username:   "ci",
+		APITokenID: primitive.NewObjectID(),
+		Scopes:     []string{string(models.ScopeScriptsRead)},
+	}
+
+	tests := []struct {
+		name    string
+		claims  *utils.JWTClaims
+		handler fiber.Handler
+		want    int
+	}{
+		{"session on a scoped route", session, ScopeAuth(models.ScopeScriptsWrite), fiber.StatusOK},
+		{"token with the scope", readToken, ScopeAuth(models.ScopeScriptsRead), fiber.StatusOK},
+		{"token without the scope", readToken, ScopeAuth(models.ScopeScriptsWrite), fiber.StatusForbidden},
+		{"session on a session route", session, SessionAuth(), fiber.StatusOK},
+		{"token on a session route", readToken, SessionAuth(), fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				c.Locals("user", tt.claims)
+				return c.Next()
+			}, tt.handler, func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenScope limits what a personal access token can do. Browser sessions
+// are not scoped.
+type TokenScope string
+
+const (
+	ScopeScriptsRead    TokenScope = "scripts:read"
+	ScopeScriptsWrite   TokenScope = "scripts:write"
+	ScopeScriptsRun     TokenScope = "scripts:run"
+	ScopeProcessesRead  TokenScope = "processes:read"
+	ScopeProcessesWrite TokenScope = "processes:write"
+)
+
+func (s TokenScope) IsValid() bool {
+	switch s {
+	case ScopeScriptsRead, ScopeScriptsWrite, ScopeScriptsRun, ScopeProcessesRead, ScopeProcessesWrite:
+		return true
+	}
+	return false
+}
+
+// APIToken is a personal access token. Only the hash of the token is stored;
+// the token itself is returned once on creation.
+type APIToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	Scopes     []TokenScope       `bson:"scopes" json:"scopes"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type CreateAPITokenRequest struct {
+	Name      string       `json:"name"`
+	Scopes    []TokenScope `json:"scopes"`
+	ExpiresAt *time.Time   `json:"expires_at"`
+}
+
+type CreateAPITokenResponse struct {
+	Token    string    `json:"token"`
+	APIToken *APIToken `json:"api_token"`
+}
+
+type CreateServiceAccountRequest struct {
+	Username string   `json:"username"`
+	Role     UserRole `json:"role"`
+}
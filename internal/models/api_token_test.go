@@ -0,0 +1,16 @@
+package models
+
+import "testing"
+
+func TestTokenScopeIsValid(t *testing.T) {
+	for _, scope := range []TokenScope{ScopeScriptsRead, ScopeScriptsWrite, ScopeScriptsRun, ScopeProcessesRead, ScopeProcessesWrite} {
+		if !scope.IsValid() {
+			t.Errorf("%s is not valid", scope)
+		}
+	}
+	for _, scope := range []TokenScope{"", "scripts", "users:write", "SCRIPTS:READ"} {
+		if scope.IsValid() {
+			t.Errorf("%q is valid", scope)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditAction string
+
+const (
+	AuditLoginLockout AuditAction = "login.lockout"
+	AuditTOTPReset    AuditAction = "2fa.reset"
+)
+
+// AuditEntry records a security relevant event.
+type AuditEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Action    AuditAction        `bson:"action" json:"action"`
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitzero"`
+	Username  string             `bson:"username,omitempty" json:"username,omitempty"`
+	IP        string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	Message   string             `bson:"message" json:"message"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AuditFilter narrows an audit log listing.
+type AuditFilter struct {
+	Action AuditAction
+	UserID *primitive.ObjectID
+}
+
+// AuditPage is a page of the audit log, newest first.
+type AuditPage struct {
+	Entries    []*AuditEntry `json:"entries"`
+	Total      int64         `json:"total"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// LoginAttempts tracks the failed logins of one account or one IP address.
+// Key is "user:<username>" or "ip:<address>".
+type LoginAttempts struct {
+	Key         string     `bson:"_id"`
+	Failures    int        `bson:"failures"`
+	LastFailure time.Time  `bson:"last_failure"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty"`
+	ExpiresAt   time.Time  `bson:"expires_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Folder organizes the scripts of its owner. Folders without a ParentID are at
+// the root of the owner's tree.
+type Folder struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name      string             `bson:"name" json:"name"`
+	ParentID  primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitzero"`
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type CreateFolderRequest struct {
+	Name     string `json:"name" validate:"required"`
+	ParentID string `json:"parent_id"`
+}
+
+// UpdateFolderRequest renames or moves a folder. ParentID is left unchanged
+// when omitted and moves the folder to the root when empty.
+type UpdateFolderRequest struct {
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+// MoveScriptRequest moves a script into a folder, or to the root when FolderID is empty.
+type MoveScriptRequest struct {
+	FolderID string `json:"folder_id"`
+}
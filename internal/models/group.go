@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Group struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	Name        string               `bson:"name" json:"name"`
+	Description string               `bson:"description" json:"description"`
+	OwnerID     primitive.ObjectID   `bson:"owner_id" json:"owner_id"`
+	MemberIDs   []primitive.ObjectID `bson:"member_ids" json:"member_ids"`
+	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+type CreateGroupRequest struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	OwnerID     string   `json:"owner_id"`
+	MemberIDs   []string `json:"member_ids"`
+}
+
+type UpdateGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OwnerID     string `json:"owner_id"`
+}
+
+type GroupMemberRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+}
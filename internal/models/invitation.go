@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SignupMode controls who may use the public signup endpoint.
+type SignupMode string
+
+const (
+	SignupDisabled SignupMode = "disabled"
+	SignupInvite   SignupMode = "invite"
+	SignupOpen     SignupMode = "open"
+)
+
+// Invitation lets one person sign up with a preset role. Only the hash of
+// the invitation token is stored.
+type Invitation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	Role      UserRole           `bson:"role" json:"role"`
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedBy    primitive.ObjectID `bson:"used_by,omitempty" json:"used_by,omitzero"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type CreateInvitationRequest struct {
+	Role      UserRole   `json:"role"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateInvitationResponse struct {
+	Token      string      `json:"token"`
+	Invitation *Invitation `json:"invitation"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationType string
+
+const (
+	NotificationShareExpired NotificationType = "share_expired"
+)
+
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type      NotificationType   `bson:"type" json:"type"`
+	Message   string             `bson:"message" json:"message"`
+	ScriptID  primitive.ObjectID `bson:"script_id,omitempty" json:"script_id,omitzero"`
+	Read      bool               `bson:"read" json:"read"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OIDCState keeps the PKCE verifier and nonce of a login started with the
+// identity provider until its callback. Only the hash of the state is
+// stored.
+type OIDCState struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	StateHash string             `bson:"state_hash"`
+	Verifier  string             `bson:"verifier"`
+	Nonce     string             `bson:"nonce"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	// State is sent to the browser in a cookie instead of the body, so that
+	// a callback is only accepted from the browser that started the login
+	State string `json:"-"`
+}
+
+// OIDCCallbackRequest carries the parameters the identity provider added to
+// the redirect URL.
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Default and maximum page sizes of cursor-paginated listings.
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// PageRequest selects a page of a cursor-paginated listing. Cursor is the
+// NextCursor of the previous page, empty for the first page.
+type PageRequest struct {
+	Cursor string
+	Limit  int
+}
@@ -17,20 +17,77 @@ const (
 	ProcessStatusError   ProcessStatus = "error"
 )
 
+// ProcessTrigger is what started a process. Processes recorded before triggers
+// were tracked have none and count as manual runs.
+type ProcessTrigger string
+
+const (
+	ProcessTriggerManual ProcessTrigger = "manual"
+	ProcessTriggerAPI    ProcessTrigger = "api"
+)
+
 type Process struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	ScriptID   primitive.ObjectID `bson:"script_id" json:"script_id"`
-	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
-	PID        int                `bson:"pid" json:"pid"`
-	Status     ProcessStatus      `bson:"status" json:"status"`
-	StartTime  time.Time          `bson:"start_time" json:"start_time"`
-	EndTime    *time.Time         `bson:"end_time,omitempty" json:"end_time,omitempty"`
-	ExitCode   *int               `bson:"exit_code,omitempty" json:"exit_code,omitempty"`
-	OutputPath string             `bson:"output_path,omitempty" json:"output_path,omitempty"`
-	Cmd        *exec.Cmd          `bson:"cmd" json:"cmd"`
-	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ScriptID       primitive.ObjectID `bson:"script_id" json:"script_id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ScriptRevision int                `bson:"script_revision" json:"script_revision"`
+	PID            int                `bson:"pid" json:"pid"`
+	Status         ProcessStatus      `bson:"status" json:"status"`
+	Trigger        ProcessTrigger     `bson:"trigger,omitempty" json:"trigger,omitempty"`
+	StartTime      time.Time          `bson:"start_time" json:"start_time"`
+	EndTime        *time.Time         `bson:"end_time,omitempty" json:"end_time,omitempty"`
+	ExitCode       *int               `bson:"exit_code,omitempty" json:"exit_code,omitempty"`
+	OutputPath     string             `bson:"output_path,omitempty" json:"output_path,omitempty"`
+	Workspace      string             `bson:"workspace,omitempty" json:"-"`
+	Cmd            *exec.Cmd          `bson:"-" json:"-"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	Pinned         bool               `bson:"pinned,omitempty" json:"pinned,omitempty"`
+}
+
+// RunRetention limits the run history kept for a script. MaxRuns keeps that
+// many of the newest unpinned runs and MaxAgeDays removes runs that started
+// more than that many days ago. Zero means no limit; pinned and running
+// processes are never removed.
+type RunRetention struct {
+	MaxRuns    int `bson:"max_runs" json:"max_runs"`
+	MaxAgeDays int `bson:"max_age_days" json:"max_age_days"`
+}
+
+// IsUnlimited reports whether the policy keeps every run.
+func (r *RunRetention) IsUnlimited() bool {
+	return r.MaxRuns <= 0 && r.MaxAgeDays <= 0
 }
 
 type RunScriptRequest struct {
 	Args []string `json:"args,omitempty"`
 }
+
+// ProcessFilter narrows a process listing. From and To bound the start time.
+type ProcessFilter struct {
+	UserID   *primitive.ObjectID
+	ScriptID *primitive.ObjectID
+	Status   ProcessStatus
+	Trigger  ProcessTrigger
+	From     *time.Time
+	To       *time.Time
+}
+
+type ProcessSortField string
+
+const (
+	ProcessSortStartTime ProcessSortField = "start_time"
+	ProcessSortStatus    ProcessSortField = "status"
+)
+
+type ProcessSort struct {
+	Field ProcessSortField
+	Order SortOrder
+}
+
+// ProcessPage is a page of a process listing. Total counts all matching
+// processes and NextCursor is empty on the last page.
+type ProcessPage struct {
+	Processes  []*Process `json:"processes"`
+	Total      int64      `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "testing"
+
+func TestRunRetentionIsUnlimited(t *testing.T) {
+	if !(&RunRetention{}).IsUnlimited() {
+		t.Error("zero retention is limited")
+	}
+	if (&RunRetention{MaxRuns: 10}).IsUnlimited() || (&RunRetention{MaxAgeDays: 30}).IsUnlimited() {
+		t.Error("retention with a limit is unlimited")
+	}
+}
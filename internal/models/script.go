@@ -13,38 +13,284 @@ const (
 	ScriptTypeGolang ScriptType = "golang"
 )
 
+// DefaultEntrypoint returns the entrypoint used when a script is created from a single content string.
+func DefaultEntrypoint(scriptType ScriptType) string {
+	if scriptType == ScriptTypeGolang {
+		return "main.go"
+	}
+	return "main.py"
+}
+
+type ScriptFile struct {
+	Path      string    `bson:"path" json:"path"`
+	Content   string    `bson:"content" json:"content"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Script is a file tree executed from its entrypoint. Content only holds the
+// source of scripts created before multi-file support; Revision is the number
+// of the latest ScriptRevision of the files. Version is bumped on every write
+// and serves as the ETag for optimistic concurrency control. FolderID is a
+// folder of the owner, zero at the root. RunRetention overrides the global run
+// history retention when set. DeletedAt is set while the script is in the trash.
 type Script struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name        string             `bson:"name" json:"name"`
-	Description string             `bson:"description" json:"description"`
-	Content     string             `bson:"content" json:"content"`
-	Type        ScriptType         `bson:"type" json:"type"`
-	OwnerID     primitive.ObjectID `bson:"owner_id" json:"owner_id"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name         string             `bson:"name" json:"name"`
+	Description  string             `bson:"description" json:"description"`
+	Content      string             `bson:"content,omitempty" json:"content,omitempty"`
+	Files        []ScriptFile       `bson:"files" json:"files"`
+	Entrypoint   string             `bson:"entrypoint" json:"entrypoint"`
+	Type         ScriptType         `bson:"type" json:"type"`
+	Revision     int                `bson:"revision" json:"revision"`
+	Version      int                `bson:"version" json:"version"`
+	OwnerID      primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	FolderID     primitive.ObjectID `bson:"folder_id,omitempty" json:"folder_id,omitzero"`
+	Tags         []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	RunRetention *RunRetention      `bson:"run_retention,omitempty" json:"run_retention,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	DeletedAt    *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+
+// FileTree returns the files of the script, converting a legacy single-content
+// script into a one-file tree rooted at its entrypoint.
+func (s *Script) FileTree() []ScriptFile {
+	if len(s.Files) > 0 || s.Content == "" {
+		return s.Files
+	}
+	return []ScriptFile{{
+		Path:      s.EntrypointPath(),
+		Content:   s.Content,
+		UpdatedAt: s.UpdatedAt,
+	}}
+}
+
+// EntrypointPath returns the file executed when the script runs.
+func (s *Script) EntrypointPath() string {
+	if s.Entrypoint != "" {
+		return s.Entrypoint
+	}
+	return DefaultEntrypoint(s.Type)
+}
+
+// FindFile returns the file stored at path, or nil if the script has no such file.
+func (s *Script) FindFile(path string) *ScriptFile {
+	for i := range s.Files {
+		if s.Files[i].Path == path {
+			return &s.Files[i]
+		}
+	}
+	return nil
+}
+
+// SharePermission is the access level granted by a share. Each level includes
+// the ones before it: view < run < edit < manage.
+type SharePermission string
+
+const (
+	PermissionView   SharePermission = "view"
+	PermissionRun    SharePermission = "run"
+	PermissionEdit   SharePermission = "edit"
+	PermissionManage SharePermission = "manage"
+)
+
+var permissionRank = map[SharePermission]int{
+	PermissionView:   1,
+	PermissionRun:    2,
+	PermissionEdit:   3,
+	PermissionManage: 4,
 }
 
+func (p SharePermission) IsValid() bool {
+	_, ok := permissionRank[p]
+	return ok
+}
+
+// Includes reports whether p grants at least the required permission.
+func (p SharePermission) Includes(required SharePermission) bool {
+	return permissionRank[p] >= permissionRank[required]
+}
+
+// ScriptShare grants a permission on a script to either a single user or
+// every member of a group.
 type ScriptShare struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	ScriptID  primitive.ObjectID `bson:"script_id" json:"script_id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ScriptID   primitive.ObjectID `bson:"script_id" json:"script_id"`
+	UserID     primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitzero"`
+	GroupID    primitive.ObjectID `bson:"group_id,omitempty" json:"group_id,omitzero"`
+	Permission SharePermission    `bson:"permission" json:"permission"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// IsActive reports whether the share still grants access at the given time.
+func (s *ScriptShare) IsActive(now time.Time) bool {
+	return s.ExpiresAt == nil || s.ExpiresAt.After(now)
+}
+
+// EffectivePermission returns the permission of the share. Shares created
+// before permission levels existed allowed reading and running the script.
+func (s *ScriptShare) EffectivePermission() SharePermission {
+	if s.Permission == "" {
+		return PermissionRun
+	}
+	return s.Permission
+}
+
+type ScriptFileInput struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
 }
 
 type CreateScriptRequest struct {
-	Name        string     `json:"name" validate:"required"`
-	Description string     `json:"description"`
-	Content     string     `json:"content" validate:"required"`
-	Type        ScriptType `json:"type" validate:"required,oneof=python golang"`
+	Name        string            `json:"name" validate:"required"`
+	Description string            `json:"description"`
+	Content     string            `json:"content"`
+	Files       []ScriptFileInput `json:"files"`
+	Entrypoint  string            `json:"entrypoint"`
+	Type        ScriptType        `json:"type" validate:"required,oneof=python golang"`
+	FolderID    string            `json:"folder_id"`
+	Tags        []string          `json:"tags"`
+	Strict      bool              `json:"strict"`
 }
 
+// UpdateScriptRequest changes the fields that are set. Tags replace the
+// existing tags when present, an empty list removes them.
 type UpdateScriptRequest struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Content     string     `json:"content"`
+	Entrypoint  string     `json:"entrypoint"`
 	Type        ScriptType `json:"type" validate:"omitempty,oneof=python golang"`
+	Tags        []string   `json:"tags"`
+	Strict      bool       `json:"strict"`
 }
 
+// ScriptScope restricts a script listing to owned or shared scripts.
+type ScriptScope string
+
+const (
+	ScriptScopeAll    ScriptScope = ""
+	ScriptScopeOwned  ScriptScope = "owned"
+	ScriptScopeShared ScriptScope = "shared"
+)
+
+// ScriptFilter narrows a script listing. Query is a full-text search over the
+// name, description and content. FolderID is nil for any folder and zero for
+// the root; folders belong to their owner, so a folder filter only matches
+// owned scripts.
+type ScriptFilter struct {
+	Query    string
+	FolderID *primitive.ObjectID
+	Tag      string
+	Type     ScriptType
+	Scope    ScriptScope
+}
+
+type ScriptSortField string
+
+const (
+	ScriptSortName      ScriptSortField = "name"
+	ScriptSortType      ScriptSortField = "type"
+	ScriptSortCreatedAt ScriptSortField = "created_at"
+	ScriptSortUpdatedAt ScriptSortField = "updated_at"
+	// ScriptSortRelevance orders by text score and needs a Query.
+	ScriptSortRelevance ScriptSortField = "relevance"
+)
+
+func (f ScriptSortField) IsValid() bool {
+	switch f {
+	case ScriptSortName, ScriptSortType, ScriptSortCreatedAt, ScriptSortUpdatedAt, ScriptSortRelevance:
+		return true
+	}
+	return false
+}
+
+type ScriptSort struct {
+	Field ScriptSortField
+	Order SortOrder
+}
+
+// ScriptPage is a page of a script listing. Total counts all matching scripts
+// and NextCursor is empty on the last page.
+type ScriptPage struct {
+	Scripts    []*Script `json:"scripts"`
+	Total      int64     `json:"total"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type WriteScriptFileRequest struct {
+	Content string `json:"content"`
+	Strict  bool   `json:"strict"`
+}
+
+type DiagnosticSeverity string
+
+const (
+	SeverityError   DiagnosticSeverity = "error"
+	SeverityWarning DiagnosticSeverity = "warning"
+)
+
+type Diagnostic struct {
+	File     string             `json:"file"`
+	Line     int                `json:"line"`
+	Column   int                `json:"column"`
+	Message  string             `json:"message"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Source   string             `json:"source"`
+}
+
+// CheckResult is the outcome of validating a script. Valid is false when any
+// diagnostic is an error, i.e. the script would not compile.
+type CheckResult struct {
+	Valid       bool         `json:"valid"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// ShareTarget identifies the grantee of a share: a user by ID, username or
+// email, or a group by ID. Exactly one field is expected.
+type ShareTarget struct {
+	UserID   string `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	GroupID  string `json:"group_id,omitempty"`
+}
+
+// TransferScriptRequest names the new owner of a script by ID, username or email.
+type TransferScriptRequest struct {
+	UserID   string `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// ShareScriptRequest grants a permission to a target. The share can be limited
+// in time with either an absolute ExpiresAt or a Duration such as "7d" or "12h".
 type ShareScriptRequest struct {
-	UserID string `json:"user_id" validate:"required"`
+	ShareTarget
+	Permission SharePermission `json:"permission" validate:"omitempty,oneof=view run edit manage"`
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"`
+	Duration   string          `json:"duration,omitempty"`
+}
+
+type BulkShareRequest struct {
+	Add    []ShareScriptRequest `json:"add"`
+	Remove []ShareTarget        `json:"remove"`
+}
+
+type BulkShareResult struct {
+	Action string      `json:"action"`
+	Target ShareTarget `json:"target"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// ScriptShareInfo describes a grantee of a script for the share list.
+type ScriptShareInfo struct {
+	UserID     primitive.ObjectID `json:"user_id,omitzero"`
+	Username   string             `json:"username,omitempty"`
+	Email      string             `json:"email,omitempty"`
+	GroupID    primitive.ObjectID `json:"group_id,omitzero"`
+	GroupName  string             `json:"group_name,omitempty"`
+	Permission SharePermission    `json:"permission"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
 }
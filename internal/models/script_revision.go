@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ScriptRevision is an immutable snapshot of the runnable content of a script.
+// A new revision is stored every time the files, entrypoint or type change.
+type ScriptRevision struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ScriptID     primitive.ObjectID `bson:"script_id" json:"script_id"`
+	Revision     int                `bson:"revision" json:"revision"`
+	Files        []ScriptFile       `bson:"files" json:"files,omitempty"`
+	Entrypoint   string             `bson:"entrypoint" json:"entrypoint"`
+	Type         ScriptType         `bson:"type" json:"type"`
+	AuthorID     primitive.ObjectID `bson:"author_id" json:"author_id"`
+	RestoredFrom int                `bson:"restored_from,omitempty" json:"restored_from,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type FileDiffStatus string
+
+const (
+	FileDiffAdded    FileDiffStatus = "added"
+	FileDiffRemoved  FileDiffStatus = "removed"
+	FileDiffModified FileDiffStatus = "modified"
+)
+
+type FileDiff struct {
+	Path   string         `json:"path"`
+	Status FileDiffStatus `json:"status"`
+	Diff   string         `json:"diff"`
+}
+
+type RevisionDiff struct {
+	From           int        `json:"from"`
+	To             int        `json:"to"`
+	EntrypointFrom string     `json:"entrypoint_from"`
+	EntrypointTo   string     `json:"entrypoint_to"`
+	Files          []FileDiff `json:"files"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScriptFileTree(t *testing.T) {
+	legacy := &Script{Type: ScriptTypeGolang, Content: "package main"}
+	files := legacy.FileTree()
+	if len(files) != 1 || files[0].Path != "main.go" || files[0].Content != "package main" {
+		t.Errorf("FileTree of a legacy script = %+v", files)
+	}
+
+	script := &Script{
+		Type:       ScriptTypePython,
+		Content:    "ignored",
+		Files:      []ScriptFile{{Path: "app.py"}, {Path: "lib/util.py"}},
+		Entrypoint: "app.py",
+	}
+	if files := script.FileTree(); len(files) != 2 {
+		t.Errorf("FileTree = %+v, want the stored files", files)
+	}
+
+	if files := (&Script{Type: ScriptTypePython}).FileTree(); len(files) != 0 {
+		t.Errorf("FileTree of an empty script = %+v", files)
+	}
+}
+
+func TestScriptEntrypointPath(t *testing.T) {
+	tests := []struct {
+		script Script
+		want   string
+	}{
+		{Script{Type: ScriptTypePython}, "main.py"},
+		{Script{Type: ScriptTypeGolang}, "main.go"},
+		{Script{Type: ScriptTypePython, Entrypoint: "src/app.py"}, "src/app.py"},
+	}
+	for _, tt := range tests {
+		if got := tt.script.EntrypointPath(); got != tt.want {
+			t.Errorf("EntrypointPath of %+v = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestScriptFindFile(t *testing.T) {
+	script := &Script{Files: []ScriptFile{{Path: "main.py"}, {Path: "lib/util.py"}}}
+
+	file := script.FindFile("lib/util.py")
+	if file == nil {
+		t.Fatal("FindFile did not find lib/util.py")
+	}
+	// The result points into the script, so it can be edited in place
+	file.Content = "x = 1"
+	if script.Files[1].Content != "x = 1" {
+		t.Error("FindFile returned a copy")
+	}
+
+	if script.FindFile("util.py") != nil {
+		t.Error("FindFile matched a file by name only")
+	}
+}
+
+func TestSharePermissionIncludes(t *testing.T) {
+	order := []SharePermission{PermissionView, PermissionRun, PermissionEdit, PermissionManage}
+	for i, granted := range order {
+		for j, required := range order {
+			if got := granted.Includes(required); got != (i >= j) {
+				t.Errorf("%s.Includes(%s) = %v", granted, required, got)
+			}
+		}
+	}
+
+	if SharePermission("owner").IsValid() || SharePermission("").IsValid() {
+		t.Error("unknown permission is valid")
+	}
+	if SharePermission("").Includes(PermissionView) {
+		t.Error("empty permission includes view")
+	}
+}
+
+func TestScriptShareEffectivePermission(t *testing.T) {
+	if got := (&ScriptShare{}).EffectivePermission(); got != PermissionRun {
+		t.Errorf("legacy share permission = %s, want run", got)
+	}
+	if got := (&ScriptShare{Permission: PermissionEdit}).EffectivePermission(); got != PermissionEdit {
+		t.Errorf("share permission = %s, want edit", got)
+	}
+}
+
+func TestScriptShareIsActive(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	if !(&ScriptShare{}).IsActive(now) {
+		t.Error("share without expiry is inactive")
+	}
+	if !(&ScriptShare{ExpiresAt: &future}).IsActive(now) {
+		t.Error("share expiring later is inactive")
+	}
+	if (&ScriptShare{ExpiresAt: &past}).IsActive(now) {
+		t.Error("expired share is active")
+	}
+	if (&ScriptShare{ExpiresAt: &now}).IsActive(now) {
+		t.Error("share is active at its expiry")
+	}
+}
+
+func TestScriptSortFieldIsValid(t *testing.T) {
+	for _, field := range []ScriptSortField{ScriptSortName, ScriptSortType, ScriptSortCreatedAt, ScriptSortUpdatedAt, ScriptSortRelevance} {
+		if !field.IsValid() {
+			t.Errorf("%s is not valid", field)
+		}
+	}
+	for _, field := range []ScriptSortField{"", "content", "owner_id"} {
+		if field.IsValid() {
+			t.Errorf("%q is valid", field)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a login session kept alive by a rotating refresh token. Only
+// hashes of refresh tokens are stored. The previous hash is kept so that
+// reuse of an already rotated token can be detected.
+type Session struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID            primitive.ObjectID `bson:"user_id" json:"user_id"`
+	TokenHash         string             `bson:"token_hash" json:"-"`
+	PreviousTokenHash string             `bson:"previous_token_hash,omitempty" json:"-"`
+	AccessTokenID     string             `bson:"access_token_id" json:"-"`
+	AccessExpiresAt   time.Time          `bson:"access_expires_at" json:"-"`
+	ExpiresAt         time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// RevokedToken is a denylisted access token, kept until the token expires.
+type RevokedToken struct {
+	ID        string    `bson:"_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SigningKey is a JWT signing key of the shared keyring. The ID is the key
+// thumbprint and is used as the kid header of the tokens it signs.
+// Generation numbers keys in creation order, so that replicas rotating at
+// the same time cannot both add a key. PrivateKey is encrypted unless the
+// key was stored before encryption was introduced.
+type SigningKey struct {
+	ID         string    `bson:"_id"`
+	Generation int       `bson:"generation,omitempty"`
+	PrivateKey string    `bson:"private_key"`
+	Encrypted  bool      `bson:"encrypted,omitempty"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
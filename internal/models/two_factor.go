@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TOTPSetup holds a new TOTP secret and its otpauth:// provisioning URI,
+// which clients render as a QR code.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAChallenge is the second step of a login after the password was
+// checked. Only the hash of its token is stored.
+type MFAChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Attempts  int                `bson:"attempts"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+// SecuritySettings are global settings changed at runtime by root.
+type SecuritySettings struct {
+	RequireAdmin2FA bool      `bson:"require_admin_2fa" json:"require_admin_2fa"`
+	UpdatedAt       time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// MFALoginRequest completes a login with a TOTP or recovery code.
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// LoginResponse is either a token pair, or a challenge to complete with
+// POST /auth/login/2fa. MFASetup is set when the user must enroll first.
+type LoginResponse struct {
+	*TokenResponse
+	MFARequired   bool       `json:"mfa_required,omitempty"`
+	MFAToken      string     `json:"mfa_token,omitempty"`
+	MFASetup      *TOTPSetup `json:"mfa_setup,omitempty"`
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"`
+}
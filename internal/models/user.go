@@ -14,13 +14,34 @@ const (
 	RoleMember UserRole = "member"
 )
 
+// AuthSource is where an account authenticates. Local accounts have none
+// and sign in with the password stored in the user document.
+type AuthSource string
+
+const (
+	AuthSourceLDAP AuthSource = "ldap"
+	AuthSourceOIDC AuthSource = "oidc"
+)
+
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Username  string            `bson:"username" json:"username"`
-	Password  string            `bson:"password" json:"-"`
-	Role      UserRole          `bson:"role" json:"role"`
-	CreatedAt time.Time         `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time         `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Username       string             `bson:"username" json:"username"`
+	DisplayName    string             `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	Email          string             `bson:"email,omitempty" json:"email,omitempty"`
+	Password       string             `bson:"password" json:"-"`
+	AuthSource     AuthSource         `bson:"auth_source,omitempty" json:"auth_source,omitempty"`
+	OIDCIssuer     string             `bson:"oidc_issuer,omitempty" json:"-"`
+	OIDCSubject    string             `bson:"oidc_subject,omitempty" json:"-"`
+	Role           UserRole           `bson:"role" json:"role"`
+	ServiceAccount bool               `bson:"service_account,omitempty" json:"service_account,omitempty"`
+	Disabled       bool               `bson:"disabled,omitempty" json:"disabled"`
+	TOTPEnabled    bool               `bson:"totp_enabled,omitempty" json:"totp_enabled"`
+	TOTPSecret     string             `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPending    string             `bson:"totp_pending_secret,omitempty" json:"-"`
+	TOTPLastStep   int64              `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes  []string           `bson:"recovery_codes,omitempty" json:"-"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 type LoginRequest struct {
@@ -29,7 +50,61 @@ type LoginRequest struct {
 }
 
 type SignupRequest struct {
-	Username string   `json:"username"`
-	Password string   `json:"password"`
-	Role     UserRole `json:"role"`
-}
\ No newline at end of file
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Password    string   `json:"password"`
+	Role        UserRole `json:"role"`
+	InviteToken string   `json:"invite_token"`
+}
+
+// UpdateProfileRequest changes the profile of the current user. Nil fields
+// are left unchanged and empty strings clear them.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	Email       *string `json:"email"`
+}
+
+type ChangeOwnPasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// UpdateUserRequest changes the role or disabled status of a user. Nil
+// fields are left unchanged.
+type UpdateUserRequest struct {
+	Role     *UserRole `json:"role"`
+	Disabled *bool     `json:"disabled"`
+}
+
+// ReassignScriptsRequest names the user that receives all scripts of
+// another user.
+type ReassignScriptsRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// UserFilter narrows a user listing. Query matches the username or email.
+type UserFilter struct {
+	Query    string
+	Role     UserRole
+	Disabled *bool
+}
+
+type UserSortField string
+
+const (
+	UserSortUsername  UserSortField = "username"
+	UserSortCreatedAt UserSortField = "created_at"
+)
+
+type UserSort struct {
+	Field UserSortField
+	Order SortOrder
+}
+
+// UserPage is a page of a user listing. Total counts all matching users and
+// NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []*User `json:"users"`
+	Total      int64   `json:"total"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
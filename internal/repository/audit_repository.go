@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *mongo.Database) *AuditRepository {
+	return &AuditRepository{
+		collection: db.Collection("audit_log"),
+	}
+}
+
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+func (r *AuditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	entry.CreatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// List returns a page of the audit log, newest first.
+func (r *AuditRepository) List(ctx context.Context, filter *models.AuditFilter, page models.PageRequest) (*models.AuditPage, error) {
+	match := bson.M{}
+	if filter.Action != "" {
+		match["action"] = filter.Action
+	}
+	if filter.UserID != nil {
+		match["user_id"] = *filter.UserID
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	found, total, hasMore, err := aggregatePage[*models.AuditEntry](ctx, r.collection, pipeline, "created_at", models.SortDesc, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.AuditPage{Entries: found, Total: total}
+	if result.Entries == nil {
+		result.Entries = []*models.AuditEntry{}
+	}
+
+	if hasMore {
+		last := found[len(found)-1]
+		if result.NextCursor, err = encodeCursor(last.CreatedAt, last.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
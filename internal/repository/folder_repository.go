@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FolderRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFolderRepository(db *mongo.Database) *FolderRepository {
+	return &FolderRepository{
+		collection: db.Collection("folders"),
+	}
+}
+
+func (r *FolderRepository) Create(ctx context.Context, folder *models.Folder) error {
+	folder.CreatedAt = time.Now()
+	folder.UpdatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, folder)
+	if err != nil {
+		return err
+	}
+	folder.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *FolderRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Folder, error) {
+	var folder models.Folder
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&folder)
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// FindByOwnerID returns all folders of a user sorted by name.
+func (r *FolderRepository) FindByOwnerID(ctx context.Context, ownerID primitive.ObjectID) ([]*models.Folder, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var folders []*models.Folder
+	if err := cursor.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (r *FolderRepository) Update(ctx context.Context, folder *models.Folder) error {
+	folder.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"name":       folder.Name,
+			"updated_at": folder.UpdatedAt,
+		},
+	}
+	if folder.ParentID.IsZero() {
+		update["$unset"] = bson.M{"parent_id": ""}
+	} else {
+		update["$set"].(bson.M)["parent_id"] = folder.ParentID
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": folder.ID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// MoveChildren moves the subfolders of a folder under another parent, or to
+// the root when parentID is zero.
+func (r *FolderRepository) MoveChildren(ctx context.Context, folderID, parentID primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{"updated_at": time.Now()}}
+	if parentID.IsZero() {
+		update["$unset"] = bson.M{"parent_id": ""}
+	} else {
+		update["$set"].(bson.M)["parent_id"] = parentID
+	}
+
+	_, err := r.collection.UpdateMany(ctx, bson.M{"parent_id": folderID}, update)
+	return err
+}
+
+// ReassignOwner moves every folder of one owner to another.
+func (r *FolderRepository) ReassignOwner(ctx context.Context, fromOwnerID, toOwnerID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{"owner_id": fromOwnerID}, bson.M{
+		"$set": bson.M{"owner_id": toOwnerID, "updated_at": time.Now()},
+	})
+	return err
+}
+
+func (r *FolderRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type GroupRepository struct {
+	collection *mongo.Collection
+}
+
+func NewGroupRepository(db *mongo.Database) *GroupRepository {
+	return &GroupRepository{
+		collection: db.Collection("groups"),
+	}
+}
+
+func (r *GroupRepository) Create(ctx context.Context, group *models.Group) error {
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = time.Now()
+	if group.MemberIDs == nil {
+		group.MemberIDs = []primitive.ObjectID{}
+	}
+	result, err := r.collection.InsertOne(ctx, group)
+	if err != nil {
+		return err
+	}
+	group.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *GroupRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Group, error) {
+	var group models.Group
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&group)
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *GroupRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*models.Group, error) {
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (r *GroupRepository) List(ctx context.Context) ([]*models.Group, error) {
+	return r.find(ctx, bson.M{})
+}
+
+// FindByUserID returns the groups the user owns or is a member of.
+func (r *GroupRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Group, error) {
+	return r.find(ctx, bson.M{"$or": bson.A{
+		bson.M{"owner_id": userID},
+		bson.M{"member_ids": userID},
+	}})
+}
+
+// FindIDsByUserID returns the IDs of the groups the user owns or is a member of.
+func (r *GroupRepository) FindIDsByUserID(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	groups, err := r.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	return ids, nil
+}
+
+func (r *GroupRepository) Update(ctx context.Context, group *models.Group) error {
+	group.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"name":        group.Name,
+			"description": group.Description,
+			"owner_id":    group.OwnerID,
+			"updated_at":  group.UpdatedAt,
+		},
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": group.ID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *GroupRepository) AddMember(ctx context.Context, groupID, userID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": groupID}, bson.M{
+		"$addToSet": bson.M{"member_ids": userID},
+		"$set":      bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *GroupRepository) RemoveMember(ctx context.Context, groupID, userID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": groupID, "member_ids": userID}, bson.M{
+		"$pull": bson.M{"member_ids": userID},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RemoveMemberFromAll removes a user from every group they are a member of.
+func (r *GroupRepository) RemoveMemberFromAll(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{"member_ids": userID}, bson.M{
+		"$pull": bson.M{"member_ids": userID},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+// ReassignOwner moves every group of one owner to another.
+func (r *GroupRepository) ReassignOwner(ctx context.Context, fromOwnerID, toOwnerID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{"owner_id": fromOwnerID}, bson.M{
+		"$set": bson.M{"owner_id": toOwnerID, "updated_at": time.Now()},
+	})
+	return err
+}
+
+func (r *GroupRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *GroupRepository) find(ctx context.Context, filter bson.M) ([]*models.Group, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []*models.Group
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
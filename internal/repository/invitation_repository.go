@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type InvitationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewInvitationRepository(db *mongo.Database) *InvitationRepository {
+	return &InvitationRepository{
+		collection: db.Collection("invitations"),
+	}
+}
+
+func (r *InvitationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "token_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *InvitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	invitation.CreatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, invitation)
+	if err != nil {
+		return err
+	}
+	invitation.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// List returns every invitation, newest first.
+func (r *InvitationRepository) List(ctx context.Context) ([]*models.Invitation, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var invitations []*models.Invitation
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *InvitationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// Consume marks an unused, unexpired invitation as used by userID and
+// returns it. It returns mongo.ErrNoDocuments when no such invitation
+// exists, so an invitation can only be used once.
+func (r *InvitationRepository) Consume(ctx context.Context, tokenHash string, userID primitive.ObjectID) (*models.Invitation, error) {
+	now := time.Now()
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_at": now, "used_by": userID}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var invitation models.Invitation
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *InvitationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
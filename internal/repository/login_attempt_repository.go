@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLoginAttemptRepository(db *mongo.Database) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		collection: db.Collection("login_attempts"),
+	}
+}
+
+// EnsureIndexes lets MongoDB forget failures once they are old enough.
+func (r *LoginAttemptRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// FindByKeys returns the tracked attempts of the given keys that still exist.
+func (r *LoginAttemptRepository) FindByKeys(ctx context.Context, keys []string) ([]*models.LoginAttempts, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": keys}, "expires_at": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var attempts []*models.LoginAttempts
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// RecordFailure counts a failed login for key and returns the updated
+// counter. Counters whose last failure is older than reset start over.
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, key string, reset time.Duration) (*models.LoginAttempts, error) {
+	now := time.Now()
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return nil, err
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failure": now, "expires_at": now.Add(reset)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempts models.LoginAttempts
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempts); err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// Lock blocks logins for key until the given time. The counter is kept at
+// least as long as the lock.
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{
+		"$set": bson.M{"locked_until": until},
+		"$max": bson.M{"expires_at": until},
+	})
+	return err
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
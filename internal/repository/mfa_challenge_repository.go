@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MFAChallengeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMFAChallengeRepository(db *mongo.Database) *MFAChallengeRepository {
+	return &MFAChallengeRepository{
+		collection: db.Collection("mfa_challenges"),
+	}
+}
+
+func (r *MFAChallengeRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+func (r *MFAChallengeRepository) Create(ctx context.Context, challenge *models.MFAChallenge) error {
+	result, err := r.collection.InsertOne(ctx, challenge)
+	if err != nil {
+		return err
+	}
+	challenge.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// CountAttempt counts an attempt on the unexpired challenge with the given
+// token hash and returns it with the updated count.
+func (r *MFAChallengeRepository) CountAttempt(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	filter := bson.M{"token_hash": tokenHash, "expires_at": bson.M{"$gt": time.Now()}}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var challenge models.MFAChallenge
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *MFAChallengeRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewNotificationRepository(db *mongo.Database) *NotificationRepository {
+	return &NotificationRepository{
+		collection: db.Collection("notifications"),
+	}
+}
+
+func (r *NotificationRepository) Create(ctx context.Context, notification *models.Notification) error {
+	notification.CreatedAt = time.Now()
+	_, err := r.collection.InsertOne(ctx, notification)
+	return err
+}
+
+// FindByUserID lists the notifications of a user, newest first.
+func (r *NotificationRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]*models.Notification, error) {
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var notifications []*models.Notification
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, id, userID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{
+		"_id":     id,
+		"user_id": userID,
+	}, bson.M{
+		"$set": bson.M{"read": true},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *NotificationRepository) DeleteByScriptID(ctx context.Context, scriptID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"script_id": scriptID})
+	return err
+}
+
+func (r *NotificationRepository) DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
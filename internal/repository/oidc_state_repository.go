@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OIDCStateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewOIDCStateRepository(db *mongo.Database) *OIDCStateRepository {
+	return &OIDCStateRepository{
+		collection: db.Collection("oidc_states"),
+	}
+}
+
+func (r *OIDCStateRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+func (r *OIDCStateRepository) Create(ctx context.Context, state *models.OIDCState) error {
+	result, err := r.collection.InsertOne(ctx, state)
+	if err != nil {
+		return err
+	}
+	state.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Consume deletes and returns the unexpired state with the given hash, so
+// each state completes a single login.
+func (r *OIDCStateRepository) Consume(ctx context.Context, stateHash string) (*models.OIDCState, error) {
+	filter := bson.M{"state_hash": stateHash, "expires_at": bson.M{"$gt": time.Now()}}
+
+	var state models.OIDCState
+	if err := r.collection.FindOneAndDelete(ctx, filter).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
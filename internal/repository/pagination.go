@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidCursor is returned when a page cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorValueTypes is the BSON type of the sort value in the cursors of each
+// sort field.
+var cursorValueTypes = map[string]bsontype.Type{
+	"name":       bson.TypeString,
+	"type":       bson.TypeString,
+	"status":     bson.TypeString,
+	"username":   bson.TypeString,
+	"created_at": bson.TypeDateTime,
+	"updated_at": bson.TypeDateTime,
+	"start_time": bson.TypeDateTime,
+	"score":      bson.TypeDouble,
+}
+
+// encodeCursor returns the cursor of the page that starts after the item with
+// the given sort value and ID.
+func encodeCursor(value interface{}, id primitive.ObjectID) (string, error) {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: value}, {Key: "id", Value: id}})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// keysetFilter matches the items after cursor in a listing sorted by field
+// then _id, both in the given order.
+func keysetFilter(cursor, field string, order models.SortOrder) (bson.M, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	raw := bson.Raw(data)
+	if err := raw.Validate(); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	value, err := raw.LookupErr("v")
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	// Documents and arrays would put client-chosen operators in the filter
+	if value.Type == bson.TypeEmbeddedDocument || value.Type == bson.TypeArray {
+		return nil, ErrInvalidCursor
+	}
+	if want, ok := cursorValueTypes[field]; ok && value.Type != want {
+		return nil, ErrInvalidCursor
+	}
+	id, ok := raw.Lookup("id").ObjectIDOK()
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	op := "$gt"
+	if order == models.SortDesc {
+		op = "$lt"
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, "_id": bson.M{op: id}},
+	}}, nil
+}
+
+// pageLimit bounds the requested page size.
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return models.DefaultPageLimit
+	}
+	if limit > models.MaxPageLimit {
+		return models.MaxPageLimit
+	}
+	return limit
+}
+
+// aggregatePage runs pipeline followed by a $facet that counts the matching
+// documents and returns the page after page.Cursor, sorted by field then _id.
+// hasMore reports whether more documents follow the page.
+func aggregatePage[T any](ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline, field string, order models.SortOrder, page models.PageRequest) (items []T, total int64, hasMore bool, err error) {
+	direction := 1
+	if order == models.SortDesc {
+		direction = -1
+	}
+	limit := pageLimit(page.Limit)
+
+	stages := bson.A{}
+	if page.Cursor != "" {
+		after, err := keysetFilter(page.Cursor, field, order)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		stages = append(stages, bson.M{"$match": after})
+	}
+	stages = append(stages,
+		bson.M{"$sort": bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}},
+		bson.M{"$limit": limit + 1},
+	)
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"total": bson.A{bson.M{"$count": "count"}},
+		"items": stages,
+	}}})
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Items []T `bson:"items"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, false, err
+	}
+	if len(results) == 0 {
+		return nil, 0, false, nil
+	}
+
+	if len(results[0].Total) > 0 {
+		total = results[0].Total[0].Count
+	}
+	items = results[0].Items
+	if len(items) > limit {
+		return items[:limit], total, true, nil
+	}
+	return items, total, false, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestKeysetFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+	cursor, err := encodeCursor("deploy", id)
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+
+	tests := []struct {
+		order models.SortOrder
+		op    string
+	}{
+		{models.SortAsc, "$gt"},
+		{models.SortDesc, "$lt"},
+	}
+	for _, tt := range tests {
+		filter, err := keysetFilter(cursor, "name", tt.order)
+		if err != nil {
+			t.Fatalf("keysetFilter: %v", err)
+		}
+
+		or := filter["$or"].(bson.A)
+		after := or[0].(bson.M)["name"].(bson.M)[tt.op].(bson.RawValue)
+		if after.StringValue() != "deploy" {
+			t.Errorf("%s: name %s %v, want deploy", tt.order, tt.op, after)
+		}
+		tie := or[1].(bson.M)
+		if tie["name"].(bson.RawValue).StringValue() != "deploy" || tie["_id"].(bson.M)[tt.op] != id {
+			t.Errorf("%s: tie breaker = %v", tt.order, tie)
+		}
+	}
+}
+
+func TestKeysetFilterTimeCursor(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	cursor, err := encodeCursor(updatedAt, primitive.NewObjectID())
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+
+	filter, err := keysetFilter(cursor, "updated_at", models.SortDesc)
+	if err != nil {
+		t.Fatalf("keysetFilter: %v", err)
+	}
+	value := filter["$or"].(bson.A)[0].(bson.M)["updated_at"].(bson.M)["$lt"].(bson.RawValue)
+	if !value.Time().Equal(updatedAt) {
+		t.Errorf("updated_at cursor = %v, want %v", value.Time(), updatedAt)
+	}
+}
+
+func TestKeysetFilterInvalidCursor(t *testing.T) {
+	noID, _ := bson.Marshal(bson.D{{Key: "v", Value: "deploy"}})
+	stringID, _ := bson.Marshal(bson.D{{Key: "v", Value: "deploy"}, {Key: "id", Value: "abc"}})
+	noValue, _ := bson.Marshal(bson.D{{Key: "id", Value: primitive.NewObjectID()}})
+
+	cursors := []string{
+		"not base64!",
+		"bm90IGJzb24",
+		base64.RawURLEncoding.EncodeToString(noID),
+		base64.RawURLEncoding.EncodeToString(stringID),
+		base64.RawURLEncoding.EncodeToString(noValue),
+	}
+	for _, cursor := range cursors {
+		if _, err := keysetFilter(cursor, "name", models.SortAsc); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("keysetFilter(%q) = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestKeysetFilterValueType(t *testing.T) {
+	id := primitive.NewObjectID()
+	cursor := func(value interface{}) string {
+		c, err := encodeCursor(value, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		field  string
+	}{
+		{"operator document", cursor(bson.D{{Key: "$ne", Value: nil}}), "name"},
+		{"array", cursor(bson.A{"a", "b"}), "name"},
+		{"string for a date", cursor("2024-05-06"), "created_at"},
+		{"date for a string", cursor(time.Now()), "username"},
+		{"string for a score", cursor("1.5"), "score"},
+		{"document for an unknown field", cursor(bson.D{{Key: "$gt", Value: ""}}), "other"},
+	}
+	for _, tt := range tests {
+		if _, err := keysetFilter(tt.cursor, tt.field, models.SortAsc); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: keysetFilter = %v, want ErrInvalidCursor", tt.name, err)
+		}
+	}
+
+	if _, err := keysetFilter(cursor(2.5), "score", models.SortDesc); err != nil {
+		t.Errorf("score cursor: %v", err)
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct{ limit, want int }{
+		{0, models.DefaultPageLimit},
+		{-5, models.DefaultPageLimit},
+		{10, 10},
+		{models.MaxPageLimit + 1, models.MaxPageLimit},
+	}
+	for _, tt := range tests {
+		if got := pageLimit(tt.limit); got != tt.want {
+			t.Errorf("pageLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
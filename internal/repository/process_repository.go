@@ -9,6 +9,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ProcessRepository struct {
@@ -47,6 +48,23 @@ func (r *ProcessRepository) FindRunningByScriptID(ctx context.Context, scriptID
 	return &process, nil
 }
 
+func (r *ProcessRepository) FindRunningByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Process, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"user_id": userID,
+		"status":  models.ProcessStatusRunning,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var processes []*models.Process
+	if err := cursor.All(ctx, &processes); err != nil {
+		return nil, err
+	}
+	return processes, nil
+}
+
 // func (r *ProcessRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status models.ProcessStatus, exitCode *int) error {
 // 	update := bson.M{
 // 		"$set": bson.M{
@@ -63,20 +81,158 @@ func (r *ProcessRepository) FindRunningByScriptID(ctx context.Context, scriptID
 // 	return err
 // }
 
-func (r *ProcessRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Process, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+// EnsureIndexes creates the compound indexes behind process listings and the
+// running process lookups.
+func (r *ProcessRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "start_time", Value: -1}}},
+		{Keys: bson.D{{Key: "script_id", Value: 1}, {Key: "start_time", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "start_time", Value: -1}}},
+		{Keys: bson.D{{Key: "script_id", Value: 1}, {Key: "status", Value: 1}, {Key: "start_time", Value: -1}}},
+	})
+	return err
+}
+
+// List returns a page of the processes that match filter together with the
+// total count.
+func (r *ProcessRepository) List(ctx context.Context, filter *models.ProcessFilter, sort models.ProcessSort, page models.PageRequest) (*models.ProcessPage, error) {
+	match := bson.M{}
+	if filter.UserID != nil {
+		match["user_id"] = *filter.UserID
+	}
+	if filter.ScriptID != nil {
+		match["script_id"] = *filter.ScriptID
+	}
+	if filter.Status != "" {
+		match["status"] = filter.Status
+	}
+	switch filter.Trigger {
+	case "":
+	case models.ProcessTriggerManual:
+		match["trigger"] = bson.M{"$in": bson.A{filter.Trigger, nil}}
+	default:
+		match["trigger"] = filter.Trigger
+	}
+	if filter.From != nil || filter.To != nil {
+		startTime := bson.M{}
+		if filter.From != nil {
+			startTime["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			startTime["$lt"] = *filter.To
+		}
+		match["start_time"] = startTime
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	found, total, hasMore, err := aggregatePage[*models.Process](ctx, r.collection, pipeline, string(sort.Field), sort.Order, page)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
-	var processes []*models.Process
-	if err := cursor.All(ctx, &processes); err != nil {
+	result := &models.ProcessPage{Processes: found, Total: total}
+	if result.Processes == nil {
+		result.Processes = []*models.Process{}
+	}
+
+	if hasMore {
+		last := found[len(found)-1]
+		var value interface{} = last.StartTime
+		if sort.Field == models.ProcessSortStatus {
+			value = last.Status
+		}
+		if result.NextCursor, err = encodeCursor(value, last.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *ProcessRepository) SetPinned(ctx context.Context, id primitive.ObjectID, pinned bool) error {
+	update := bson.M{"$set": bson.M{"pinned": true}}
+	if !pinned {
+		update = bson.M{"$unset": bson.M{"pinned": ""}}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindScriptIDs returns the IDs of the scripts that have run history.
+func (r *ProcessRepository) FindScriptIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	values, err := r.collection.Distinct(ctx, "script_id", bson.M{})
+	if err != nil {
 		return nil, err
 	}
+
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// FindExpiredRuns returns the finished, unpinned runs of a script that fall
+// outside the retention: all but the keep newest ones when keep is positive,
+// and those started before the cutoff when it is set.
+func (r *ProcessRepository) FindExpiredRuns(ctx context.Context, scriptID primitive.ObjectID, keep int, before *time.Time) ([]*models.Process, error) {
+	filter := bson.M{
+		"script_id": scriptID,
+		"status":    bson.M{"$ne": models.ProcessStatusRunning},
+		"pinned":    bson.M{"$ne": true},
+	}
+	projection := bson.M{"_id": 1, "output_path": 1, "workspace": 1}
+	expired := map[primitive.ObjectID]*models.Process{}
+
+	if keep > 0 {
+		opts := options.Find().
+			SetSort(bson.D{{Key: "start_time", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip(int64(keep)).
+			SetProjection(projection)
+		processes, err := r.find(ctx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, process := range processes {
+			expired[process.ID] = process
+		}
+	}
+
+	if before != nil {
+		filter["start_time"] = bson.M{"$lt": *before}
+		processes, err := r.find(ctx, filter, options.Find().SetProjection(projection))
+		if err != nil {
+			return nil, err
+		}
+		for _, process := range processes {
+			expired[process.ID] = process
+		}
+	}
+
+	processes := make([]*models.Process, 0, len(expired))
+	for _, process := range expired {
+		processes = append(processes, process)
+	}
 	return processes, nil
 }
 
+func (r *ProcessRepository) DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
 func (r *ProcessRepository) Update(ctx context.Context, process *models.Process) error {
 	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": process.ID}, process)
 	return err
@@ -101,3 +257,32 @@ func (r *ProcessRepository) UpdateStatus(ctx context.Context, id primitive.Objec
 	_, updateErr := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
 	return updateErr
 }
+
+func (r *ProcessRepository) DeleteByScriptID(ctx context.Context, scriptID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"script_id": scriptID})
+	return err
+}
+
+// ClearUserID detaches the runs started by a user from them, keeping the
+// run history of the scripts they ran.
+func (r *ProcessRepository) ClearUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"user_id": primitive.NilObjectID}},
+	)
+	return err
+}
+
+func (r *ProcessRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*models.Process, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var processes []*models.Process
+	if err := cursor.All(ctx, &processes); err != nil {
+		return nil, err
+	}
+	return processes, nil
+}
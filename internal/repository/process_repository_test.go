@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestFindExpiredRuns(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("count and age limits", func(mt *mtest.T) {
+		repo := NewProcessRepository(mt.DB)
+		old, older, oldest := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.processes", mtest.FirstBatch, bson.D{{Key: "_id", Value: old}}, bson.D{{Key: "_id", Value: older}}),
+			mtest.CreateCursorResponse(0, "db.processes", mtest.FirstBatch, bson.D{{Key: "_id", Value: older}}, bson.D{{Key: "_id", Value: oldest}}),
+		)
+
+		cutoff := time.Now().AddDate(0, 0, -30)
+		processes, err := repo.FindExpiredRuns(context.Background(), primitive.NewObjectID(), 10, &cutoff)
+		if err != nil {
+			t.Fatalf("FindExpiredRuns: %v", err)
+		}
+		// A run beyond both limits is returned once
+		if len(processes) != 3 {
+			t.Errorf("expired runs = %d, want 3", len(processes))
+		}
+
+		events := mt.GetAllStartedEvents()
+		if len(events) != 2 {
+			t.Fatalf("started commands = %d, want 2", len(events))
+		}
+		if skip := events[0].Command.Lookup("skip").AsInt64(); skip != 10 {
+			t.Errorf("skip = %d, want 10", skip)
+		}
+		filter := events[1].Command.Lookup("filter").Document()
+		if _, err := filter.Lookup("start_time").Document().LookupErr("$lt"); err != nil {
+			t.Errorf("age filter = %v", filter)
+		}
+		for _, field := range []string{"status", "pinned"} {
+			if _, err := filter.LookupErr(field); err != nil {
+				t.Errorf("filter does not exclude runs by %s: %v", field, filter)
+			}
+		}
+	})
+
+	mt.Run("unlimited", func(mt *mtest.T) {
+		repo := NewProcessRepository(mt.DB)
+		processes, err := repo.FindExpiredRuns(context.Background(), primitive.NewObjectID(), 0, nil)
+		if err != nil || len(processes) != 0 {
+			t.Fatalf("FindExpiredRuns = %v, %v", processes, err)
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 0 {
+			t.Errorf("started commands = %d, want none", len(events))
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevokedTokenRepository is the denylist of access token IDs (jti).
+type RevokedTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRevokedTokenRepository(db *mongo.Database) *RevokedTokenRepository {
+	return &RevokedTokenRepository{
+		collection: db.Collection("revoked_tokens"),
+	}
+}
+
+// EnsureIndexes lets MongoDB drop entries once the token has expired.
+func (r *RevokedTokenRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Add denylists the tokens, ignoring the ones already revoked.
+func (r *RevokedTokenRepository) Add(ctx context.Context, tokens []models.RevokedToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(tokens))
+	for _, token := range tokens {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": token.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"expires_at": token.ExpiresAt}}).
+			SetUpsert(true))
+	}
+	_, err := r.collection.BulkWrite(ctx, writes)
+	return err
+}
+
+func (r *RevokedTokenRepository) Exists(ctx context.Context, id string) (bool, error) {
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"scripts-management/internal/models"
@@ -9,8 +10,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrVersionConflict is returned by Update when the script was modified after it was read.
+var ErrVersionConflict = errors.New("script was modified by another request")
+
 type ScriptRepository struct {
 	collection *mongo.Collection
 }
@@ -24,54 +29,350 @@ func NewScriptRepository(db *mongo.Database) *ScriptRepository {
 func (r *ScriptRepository) Create(ctx context.Context, script *models.Script) error {
 	script.CreatedAt = time.Now()
 	script.UpdatedAt = time.Now()
-	_, err := r.collection.InsertOne(ctx, script)
-	return err
+	script.Version = 1
+	result, err := r.collection.InsertOne(ctx, script)
+	if err != nil {
+		return err
+	}
+	script.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
 }
 
+// FindByID returns a script that is not in the trash.
 func (r *ScriptRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Script, error) {
-	var script models.Script
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&script)
+	return r.findOne(ctx, bson.M{"_id": id, "deleted_at": nil})
+}
+
+// FindDeletedByID returns a script that is in the trash.
+func (r *ScriptRepository) FindDeletedByID(ctx context.Context, id primitive.ObjectID) (*models.Script, error) {
+	return r.findOne(ctx, bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}})
+}
+
+// FindByOwnerID returns the scripts of an owner that are not in the trash.
+func (r *ScriptRepository) FindByOwnerID(ctx context.Context, ownerID primitive.ObjectID) ([]*models.Script, error) {
+	return r.find(ctx, bson.M{"owner_id": ownerID, "deleted_at": nil})
+}
+
+// EnsureIndexes creates the indexes used by script listings: the text index
+// behind search and the index behind the owner listing.
+func (r *ScriptRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "name", Value: "text"},
+				{Key: "description", Value: "text"},
+				{Key: "content", Value: "text"},
+				{Key: "files.content", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("scripts_text").
+				SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "description", Value: 5}}),
+		},
+		{
+			Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "deleted_at", Value: 1}, {Key: "updated_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+// scoredScript is a script with the text score of a search.
+type scoredScript struct {
+	models.Script `bson:",inline"`
+	Score         float64 `bson:"score,omitempty"`
+}
+
+// List returns a page of the scripts that userID owns or that are among
+// sharedIDs and match filter. The page and the total count are computed in a
+// single aggregation.
+func (r *ScriptRepository) List(ctx context.Context, userID primitive.ObjectID, sharedIDs []primitive.ObjectID, filter *models.ScriptFilter, sort models.ScriptSort, page models.PageRequest) (*models.ScriptPage, error) {
+	if sharedIDs == nil {
+		sharedIDs = []primitive.ObjectID{}
+	}
+	owned := bson.M{"owner_id": userID}
+	shared := bson.M{"_id": bson.M{"$in": sharedIDs}, "owner_id": bson.M{"$ne": userID}}
+
+	match := scriptFilterQuery(filter)
+	switch {
+	case filter.Scope == models.ScriptScopeOwned || filter.FolderID != nil:
+		// Folders belong to their owner, so shared scripts never match a folder filter
+		match["owner_id"] = userID
+	case filter.Scope == models.ScriptScopeShared:
+		match["_id"] = shared["_id"]
+		match["owner_id"] = shared["owner_id"]
+	default:
+		match["$or"] = bson.A{owned, shared}
+	}
+	if filter.Query != "" {
+		match["$text"] = bson.M{"$search": filter.Query}
+	}
+
+	field := string(sort.Field)
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if sort.Field == models.ScriptSortRelevance {
+		field = "score"
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}})
+	}
+
+	found, total, hasMore, err := aggregatePage[*scoredScript](ctx, r.collection, pipeline, field, sort.Order, page)
 	if err != nil {
 		return nil, err
 	}
-	return &script, nil
+
+	result := &models.ScriptPage{Scripts: make([]*models.Script, 0, len(found)), Total: total}
+	for _, item := range found {
+		result.Scripts = append(result.Scripts, &item.Script)
+	}
+
+	if hasMore {
+		last := found[len(found)-1]
+		var value interface{}
+		switch sort.Field {
+		case models.ScriptSortName:
+			value = last.Name
+		case models.ScriptSortType:
+			value = last.Type
+		case models.ScriptSortCreatedAt:
+			value = last.CreatedAt
+		case models.ScriptSortUpdatedAt:
+			value = last.UpdatedAt
+		case models.ScriptSortRelevance:
+			value = last.Score
+		}
+		if result.NextCursor, err = encodeCursor(value, last.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
 }
 
-func (r *ScriptRepository) FindByOwnerID(ctx context.Context, ownerID primitive.ObjectID) ([]*models.Script, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"owner_id": ownerID})
+// FindDeletedByOwnerID returns the trashed scripts of an owner, most recently
+// deleted first.
+func (r *ScriptRepository) FindDeletedByOwnerID(ctx context.Context, ownerID primitive.ObjectID) ([]*models.Script, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	return r.find(ctx, bson.M{"owner_id": ownerID, "deleted_at": bson.M{"$ne": nil}}, opts)
+}
+
+// FindDeletedBefore returns the scripts that were moved to the trash before cutoff.
+func (r *ScriptRepository) FindDeletedBefore(ctx context.Context, cutoff time.Time) ([]*models.Script, error) {
+	return r.find(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+}
+
+// FindIDsByOwnerID returns the IDs of all scripts of an owner, trashed ones included.
+func (r *ScriptRepository) FindIDsByOwnerID(ctx context.Context, ownerID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	scripts, err := r.find(ctx, bson.M{"owner_id": ownerID}, opts)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
-	var scripts []*models.Script
-	if err := cursor.All(ctx, &scripts); err != nil {
+	ids := make([]primitive.ObjectID, len(scripts))
+	for i, script := range scripts {
+		ids[i] = script.ID
+	}
+	return ids, nil
+}
+
+// UpdateOwner moves a script to another owner and bumps its version.
+func (r *ScriptRepository) UpdateOwner(ctx context.Context, scriptID, ownerID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": scriptID, "deleted_at": nil},
+		bson.M{
+			"$set": bson.M{"owner_id": ownerID, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateFolder moves a script into a folder, or to the root when folderID is
+// zero, and bumps its version.
+func (r *ScriptRepository) UpdateFolder(ctx context.Context, scriptID, folderID primitive.ObjectID) error {
+	update := bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	if folderID.IsZero() {
+		update["$unset"] = bson.M{"folder_id": ""}
+	} else {
+		update["$set"].(bson.M)["folder_id"] = folderID
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": scriptID, "deleted_at": nil}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateRunRetention sets the run retention of a script, or removes it when
+// retention is nil, and bumps its version.
+func (r *ScriptRepository) UpdateRunRetention(ctx context.Context, scriptID primitive.ObjectID, retention *models.RunRetention) error {
+	update := bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	if retention == nil {
+		update["$unset"] = bson.M{"run_retention": ""}
+	} else {
+		update["$set"].(bson.M)["run_retention"] = retention
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": scriptID, "deleted_at": nil}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindRunRetentions returns the run retention of the scripts that have one,
+// trashed ones included.
+func (r *ScriptRepository) FindRunRetentions(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*models.RunRetention, error) {
+	opts := options.Find().SetProjection(bson.M{"run_retention": 1})
+	scripts, err := r.find(ctx, bson.M{
+		"_id":           bson.M{"$in": ids},
+		"run_retention": bson.M{"$ne": nil},
+	}, opts)
+	if err != nil {
 		return nil, err
 	}
-	return scripts, nil
+
+	retentions := make(map[primitive.ObjectID]*models.RunRetention, len(scripts))
+	for _, script := range scripts {
+		retentions[script.ID] = script.RunRetention
+	}
+	return retentions, nil
+}
+
+// MoveFolder moves the scripts of a folder, trashed ones included, into
+// another folder or to the root when toFolderID is zero.
+func (r *ScriptRepository) MoveFolder(ctx context.Context, fromFolderID, toFolderID primitive.ObjectID) error {
+	update := bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	if toFolderID.IsZero() {
+		update["$unset"] = bson.M{"folder_id": ""}
+	} else {
+		update["$set"].(bson.M)["folder_id"] = toFolderID
+	}
+
+	_, err := r.collection.UpdateMany(ctx, bson.M{"folder_id": fromFolderID}, update)
+	return err
 }
 
+// ReassignOwner moves every script of one owner to another, trashed ones
+// included, and returns the number of scripts that were moved.
+func (r *ScriptRepository) ReassignOwner(ctx context.Context, fromOwnerID, toOwnerID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"owner_id": fromOwnerID},
+		bson.M{
+			"$set": bson.M{"owner_id": toOwnerID, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// Update saves script only if its version is still the one that was read and
+// bumps the version on success.
 func (r *ScriptRepository) Update(ctx context.Context, script *models.Script) error {
-	script.UpdatedAt = time.Now()
+	updatedAt := time.Now()
+	filter := bson.M{"_id": script.ID, "version": script.Version, "deleted_at": nil}
+	if script.Version == 0 {
+		// Scripts created before versioning have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
 	update := bson.M{
 		"$set": bson.M{
 			"name":        script.Name,
 			"description": script.Description,
-			"content":     script.Content,
+			"files":       script.Files,
+			"entrypoint":  script.Entrypoint,
 			"type":        script.Type,
-			"updated_at":  script.UpdatedAt,
+			"revision":    script.Revision,
+			"version":     script.Version + 1,
+			"updated_at":  updatedAt,
 		},
+		"$unset": bson.M{
+			"content": "",
+		},
+	}
+	if len(script.Tags) > 0 {
+		update["$set"].(bson.M)["tags"] = script.Tags
+	} else {
+		update["$unset"].(bson.M)["tags"] = ""
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := r.collection.CountDocuments(ctx, bson.M{"_id": script.ID, "deleted_at": nil})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return mongo.ErrNoDocuments
+		}
+		return ErrVersionConflict
+	}
+
+	script.Version++
+	script.UpdatedAt = updatedAt
+	return nil
+}
+
+// SoftDelete moves a script to the trash.
+func (r *ScriptRepository) SoftDelete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
 	}
-	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": script.ID}, update)
+	return nil
+}
+
+// Restore takes a script out of the trash.
+func (r *ScriptRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{
+			"$unset": bson.M{"deleted_at": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
 	if err != nil {
 		return err
 	}
-	if result.ModifiedCount == 0 {
+	if result.MatchedCount == 0 {
 		return mongo.ErrNoDocuments
 	}
 	return nil
 }
 
+// Delete removes a script permanently.
 func (r *ScriptRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
 	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
@@ -82,3 +383,49 @@ func (r *ScriptRepository) Delete(ctx context.Context, id primitive.ObjectID) er
 	}
 	return nil
 }
+
+func (r *ScriptRepository) findOne(ctx context.Context, filter bson.M) (*models.Script, error) {
+	var script models.Script
+	err := r.collection.FindOne(ctx, filter).Decode(&script)
+	if err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+func (r *ScriptRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*models.Script, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var scripts []*models.Script
+	if err := cursor.All(ctx, &scripts); err != nil {
+		return nil, err
+	}
+	return scripts, nil
+}
+
+// scriptFilterQuery builds the query for filter, excluding trashed scripts.
+func scriptFilterQuery(filter *models.ScriptFilter) bson.M {
+	query := bson.M{"deleted_at": nil}
+	if filter == nil {
+		return query
+	}
+
+	if filter.FolderID != nil {
+		if filter.FolderID.IsZero() {
+			query["folder_id"] = nil
+		} else {
+			query["folder_id"] = *filter.FolderID
+		}
+	}
+	if filter.Tag != "" {
+		query["tags"] = filter.Tag
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	return query
+}
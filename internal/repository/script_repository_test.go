@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestScriptRepositoryUpdateVersion(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("bumps the version", func(mt *mtest.T) {
+		repo := NewScriptRepository(mt.DB)
+		script := &models.Script{ID: primitive.NewObjectID(), Version: 3}
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		if err := repo.Update(context.Background(), script); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if script.Version != 4 {
+			t.Errorf("version = %d, want 4", script.Version)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		if version := filter.Lookup("version").AsInt64(); version != 3 {
+			t.Errorf("update filter version = %d, want 3", version)
+		}
+	})
+
+	mt.Run("stale version", func(mt *mtest.T) {
+		repo := NewScriptRepository(mt.DB)
+		script := &models.Script{ID: primitive.NewObjectID(), Version: 3}
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+		)
+
+		if err := repo.Update(context.Background(), script); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("Update = %v, want ErrVersionConflict", err)
+		}
+		if script.Version != 3 {
+			t.Errorf("version changed to %d on a conflict", script.Version)
+		}
+	})
+
+	mt.Run("deleted script", func(mt *mtest.T) {
+		repo := NewScriptRepository(mt.DB)
+		script := &models.Script{ID: primitive.NewObjectID(), Version: 3}
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch),
+		)
+
+		if err := repo.Update(context.Background(), script); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Fatalf("Update = %v, want ErrNoDocuments", err)
+		}
+	})
+}
+
+func TestScriptRepositoryTrashFilters(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("FindByID skips trashed scripts", func(mt *mtest.T) {
+		repo := NewScriptRepository(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch))
+
+		if _, err := repo.FindByID(context.Background(), primitive.NewObjectID()); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Fatalf("FindByID = %v, want ErrNoDocuments", err)
+		}
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if deletedAt := filter.Lookup("deleted_at"); deletedAt.Type != bson.TypeNull {
+			t.Errorf("deleted_at filter = %v, want null", deletedAt)
+		}
+	})
+
+	mt.Run("FindDeletedByID only finds trashed scripts", func(mt *mtest.T) {
+		repo := NewScriptRepository(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch))
+
+		repo.FindDeletedByID(context.Background(), primitive.NewObjectID())
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if _, err := filter.Lookup("deleted_at").Document().LookupErr("$ne"); err != nil {
+			t.Errorf("deleted_at filter = %v, want $ne null", filter.Lookup("deleted_at"))
+		}
+	})
+}
+
+func TestScriptFilterQuery(t *testing.T) {
+	folderID := primitive.NewObjectID()
+	root := primitive.NilObjectID
+
+	tests := []struct {
+		name   string
+		filter *models.ScriptFilter
+		want   bson.M
+	}{
+		{"no filter", nil, bson.M{"deleted_at": nil}},
+		{"root folder", &models.ScriptFilter{FolderID: &root}, bson.M{"deleted_at": nil, "folder_id": nil}},
+		{
+			name:   "folder, tag and type",
+			filter: &models.ScriptFilter{FolderID: &folderID, Tag: "ops", Type: models.ScriptTypeGolang},
+			want:   bson.M{"deleted_at": nil, "folder_id": folderID, "tags": "ops", "type": models.ScriptTypeGolang},
+		},
+	}
+	for _, tt := range tests {
+		if got := scriptFilterQuery(tt.filter); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("scriptFilterQuery(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
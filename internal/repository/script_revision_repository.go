@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ScriptRevisionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewScriptRevisionRepository(db *mongo.Database) *ScriptRevisionRepository {
+	return &ScriptRevisionRepository{
+		collection: db.Collection("script_revisions"),
+	}
+}
+
+func (r *ScriptRevisionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "script_id", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (r *ScriptRevisionRepository) Create(ctx context.Context, revision *models.ScriptRevision) error {
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+	_, err := r.collection.InsertOne(ctx, revision)
+	return err
+}
+
+// FindByScriptID lists the revisions of a script, newest first, without file contents.
+func (r *ScriptRevisionRepository) FindByScriptID(ctx context.Context, scriptID primitive.ObjectID) ([]*models.ScriptRevision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "revision", Value: -1}}).
+		SetProjection(bson.M{"files": 0})
+	cursor, err := r.collection.Find(ctx, bson.M{"script_id": scriptID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var revisions []*models.ScriptRevision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *ScriptRevisionRepository) FindByScriptIDAndRevision(ctx context.Context, scriptID primitive.ObjectID, revision int) (*models.ScriptRevision, error) {
+	var rev models.ScriptRevision
+	err := r.collection.FindOne(ctx, bson.M{
+		"script_id": scriptID,
+		"revision":  revision,
+	}).Decode(&rev)
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+func (r *ScriptRevisionRepository) DeleteByScriptID(ctx context.Context, scriptID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"script_id": scriptID})
+	return err
+}
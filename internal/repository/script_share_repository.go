@@ -39,6 +39,10 @@ func (r *ScriptShareRepository) FindByScriptIDAndUserID(ctx context.Context, scr
 	return &share, nil
 }
 
+func (r *ScriptShareRepository) FindByScriptID(ctx context.Context, scriptID primitive.ObjectID) ([]*models.ScriptShare, error) {
+	return r.find(ctx, bson.M{"script_id": scriptID})
+}
+
 func (r *ScriptShareRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.ScriptShare, error) {
 	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
 	if err != nil {
@@ -53,6 +57,95 @@ func (r *ScriptShareRepository) FindByUserID(ctx context.Context, userID primiti
 	return shares, nil
 }
 
+func (r *ScriptShareRepository) FindByScriptIDAndGroupID(ctx context.Context, scriptID, groupID primitive.ObjectID) (*models.ScriptShare, error) {
+	var share models.ScriptShare
+	err := r.collection.FindOne(ctx, bson.M{
+		"script_id": scriptID,
+		"group_id":  groupID,
+	}).Decode(&share)
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// FindByScriptIDAndGroupIDs returns the shares of a script granted to any of the groups.
+func (r *ScriptShareRepository) FindByScriptIDAndGroupIDs(ctx context.Context, scriptID primitive.ObjectID, groupIDs []primitive.ObjectID) ([]*models.ScriptShare, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	return r.find(ctx, bson.M{
+		"script_id": scriptID,
+		"group_id":  bson.M{"$in": groupIDs},
+	})
+}
+
+// FindByGroupIDs returns the shares granted to any of the groups.
+func (r *ScriptShareRepository) FindByGroupIDs(ctx context.Context, groupIDs []primitive.ObjectID) ([]*models.ScriptShare, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	return r.find(ctx, bson.M{"group_id": bson.M{"$in": groupIDs}})
+}
+
+// FindActiveScriptIDs returns the IDs of the scripts shared with a user,
+// directly or through one of the groups, by a share that has not expired.
+func (r *ScriptShareRepository) FindActiveScriptIDs(ctx context.Context, userID primitive.ObjectID, groupIDs []primitive.ObjectID, now time.Time) ([]primitive.ObjectID, error) {
+	grantee := bson.A{bson.M{"user_id": userID}}
+	if len(groupIDs) > 0 {
+		grantee = append(grantee, bson.M{"group_id": bson.M{"$in": groupIDs}})
+	}
+
+	values, err := r.collection.Distinct(ctx, "script_id", bson.M{"$and": bson.A{
+		bson.M{"$or": grantee},
+		bson.M{"$or": bson.A{
+			bson.M{"expires_at": nil},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// UpdatePermission changes the permission and expiry of a user share. A nil
+// expiresAt makes the share permanent.
+func (r *ScriptShareRepository) UpdatePermission(ctx context.Context, scriptID, userID primitive.ObjectID, permission models.SharePermission, expiresAt *time.Time) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{
+		"script_id": scriptID,
+		"user_id":   userID,
+	}, grantUpdate(permission, expiresAt))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *ScriptShareRepository) UpdateGroupPermission(ctx context.Context, scriptID, groupID primitive.ObjectID, permission models.SharePermission, expiresAt *time.Time) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{
+		"script_id": scriptID,
+		"group_id":  groupID,
+	}, grantUpdate(permission, expiresAt))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 func (r *ScriptShareRepository) Delete(ctx context.Context, scriptID, userID primitive.ObjectID) error {
 	result, err := r.collection.DeleteOne(ctx, bson.M{
 		"script_id": scriptID,
@@ -66,3 +159,83 @@ func (r *ScriptShareRepository) Delete(ctx context.Context, scriptID, userID pri
 	}
 	return nil
 }
+
+func (r *ScriptShareRepository) DeleteGroupShare(ctx context.Context, scriptID, groupID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{
+		"script_id": scriptID,
+		"group_id":  groupID,
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteByScriptIDsAndUserID removes the user shares of a user on the given scripts.
+func (r *ScriptShareRepository) DeleteByScriptIDsAndUserID(ctx context.Context, scriptIDs []primitive.ObjectID, userID primitive.ObjectID) error {
+	if len(scriptIDs) == 0 {
+		return nil
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"script_id": bson.M{"$in": scriptIDs}, "user_id": userID})
+	return err
+}
+
+func (r *ScriptShareRepository) DeleteByScriptID(ctx context.Context, scriptID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"script_id": scriptID})
+	return err
+}
+
+func (r *ScriptShareRepository) DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *ScriptShareRepository) DeleteByGroupID(ctx context.Context, groupID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"group_id": groupID})
+	return err
+}
+
+// FindExpired returns the shares whose expiry is at or before now.
+func (r *ScriptShareRepository) FindExpired(ctx context.Context, now time.Time) ([]*models.ScriptShare, error) {
+	return r.find(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+}
+
+func (r *ScriptShareRepository) DeleteByID(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func grantUpdate(permission models.SharePermission, expiresAt *time.Time) bson.M {
+	if expiresAt == nil {
+		return bson.M{
+			"$set":   bson.M{"permission": permission},
+			"$unset": bson.M{"expires_at": ""},
+		}
+	}
+	return bson.M{
+		"$set": bson.M{"permission": permission, "expires_at": expiresAt},
+	}
+}
+
+func (r *ScriptShareRepository) find(ctx context.Context, filter bson.M) ([]*models.ScriptShare, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var shares []*models.ScriptShare
+	if err := cursor.All(ctx, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
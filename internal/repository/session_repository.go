@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository(db *mongo.Database) *SessionRepository {
+	return &SessionRepository{
+		collection: db.Collection("sessions"),
+	}
+}
+
+// EnsureIndexes creates the token lookup indexes and lets MongoDB remove
+// expired sessions.
+func (r *SessionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "previous_token_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	session.CreatedAt = time.Now()
+	session.UpdatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, session)
+	if err != nil {
+		return err
+	}
+	session.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByTokenHash returns the unexpired session whose current refresh token
+// has the given hash.
+func (r *SessionRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	return r.findOne(ctx, bson.M{"token_hash": tokenHash, "expires_at": bson.M{"$gt": time.Now()}})
+}
+
+// FindByPreviousTokenHash returns the session a rotated refresh token
+// belonged to.
+func (r *SessionRepository) FindByPreviousTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	return r.findOne(ctx, bson.M{"previous_token_hash": tokenHash})
+}
+
+func (r *SessionRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []*models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Rotate replaces the refresh and access tokens of a session. It only
+// succeeds while the session still holds session.TokenHash, so a token can
+// be rotated once.
+func (r *SessionRepository) Rotate(ctx context.Context, session *models.Session, tokenHash, accessTokenID string, accessExpiresAt time.Time) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": session.ID, "token_hash": session.TokenHash}, bson.M{
+		"$set": bson.M{
+			"token_hash":          tokenHash,
+			"previous_token_hash": session.TokenHash,
+			"access_token_id":     accessTokenID,
+			"access_expires_at":   accessExpiresAt,
+			"updated_at":          time.Now(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *SessionRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *SessionRepository) findOne(ctx context.Context, filter bson.M) (*models.Session, error) {
+	var session models.Session
+	if err := r.collection.FindOne(ctx, filter).Decode(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
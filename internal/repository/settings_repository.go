@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// securitySettingsID is the ID of the single security settings document.
+const securitySettingsID = "security"
+
+type SettingsRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSettingsRepository(db *mongo.Database) *SettingsRepository {
+	return &SettingsRepository{
+		collection: db.Collection("settings"),
+	}
+}
+
+// GetSecurity returns the security settings, or the defaults when they were
+// never saved.
+func (r *SettingsRepository) GetSecurity(ctx context.Context) (*models.SecuritySettings, error) {
+	var settings models.SecuritySettings
+	err := r.collection.FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&settings)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &models.SecuritySettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *SettingsRepository) UpdateSecurity(ctx context.Context, settings *models.SecuritySettings) error {
+	settings.UpdatedAt = time.Now()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": securitySettingsID}, bson.M{
+		"$set": bson.M{
+			"require_admin_2fa": settings.RequireAdmin2FA,
+			"updated_at":        settings.UpdatedAt,
+		},
+	}, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs repository calls in a MongoDB transaction. Transactions need
+// a replica set or a sharded cluster; on a standalone server the calls run
+// without one.
+type Transactor struct {
+	db        *mongo.Database
+	mu        sync.Mutex
+	checked   bool
+	supported bool
+}
+
+func NewTransactor(db *mongo.Database) *Transactor {
+	return &Transactor{db: db}
+}
+
+// WithTransaction calls fn with a context bound to a transaction. Calls made
+// inside an ongoing transaction join it instead of starting a new one. fn may
+// be retried on transient errors and should not have side effects outside the
+// database.
+func (t *Transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil || !t.supportsTransactions(ctx) {
+		return fn(ctx)
+	}
+
+	return t.db.Client().UseSession(ctx, func(sc mongo.SessionContext) error {
+		_, err := sc.WithTransaction(sc, func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc)
+		})
+		return err
+	})
+}
+
+func (t *Transactor) supportsTransactions(ctx context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.checked {
+		var hello bson.M
+		if err := t.db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+			// Try again on the next call
+			return false
+		}
+		_, replicaSet := hello["setName"]
+		t.supported = replicaSet || hello["msg"] == "isdbgrid"
+		t.checked = true
+	}
+	return t.supported
+}
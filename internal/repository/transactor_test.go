@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestTransactorStandalone(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("runs without a transaction", func(mt *mtest.T) {
+		transactor := NewTransactor(mt.DB)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "isWritablePrimary", Value: true}})
+
+		for i := 0; i < 2; i++ {
+			calls := 0
+			err := transactor.WithTransaction(context.Background(), func(ctx context.Context) error {
+				calls++
+				return nil
+			})
+			if err != nil || calls != 1 {
+				t.Fatalf("WithTransaction = %v after %d calls", err, calls)
+			}
+		}
+
+		// The server is probed once
+		if events := mt.GetAllStartedEvents(); len(events) != 1 || events[0].CommandName != "hello" {
+			t.Errorf("started commands = %d, want a single hello", len(events))
+		}
+	})
+
+	mt.Run("probes again after a failure", func(mt *mtest.T) {
+		transactor := NewTransactor(mt.DB)
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "unavailable"}},
+			bson.D{{Key: "ok", Value: 1}},
+		)
+
+		for i := 0; i < 2; i++ {
+			if err := transactor.WithTransaction(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+				t.Fatalf("WithTransaction: %v", err)
+			}
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 2 {
+			t.Errorf("started commands = %d, want two hello probes", len(events))
+		}
+	})
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"scripts-management/internal/models"
@@ -9,6 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserRepository struct {
@@ -21,11 +23,52 @@ func NewUserRepository(db *mongo.Database) *UserRepository {
 	}
 }
 
+// EnsureIndexes creates the indexes used by logins and user listings.
+func (r *UserRepository) EnsureIndexes(ctx context.Context) error {
+	if err := r.dropNonUniqueIndex(ctx, "username_1"); err != nil {
+		return err
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "oidc_issuer", Value: 1}, {Key: "oidc_subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"oidc_subject": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
+// dropNonUniqueIndex removes an index created before it was made unique,
+// so that it can be created again with the unique option.
+func (r *UserRepository) dropNonUniqueIndex(ctx context.Context, name string) error {
+	specs, err := r.collection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec.Name == name && (spec.Unique == nil || !*spec.Unique) {
+			_, err := r.collection.Indexes().DropOne(ctx, name)
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
-	_, err := r.collection.InsertOne(ctx, user)
-	return err
+	result, err := r.collection.InsertOne(ctx, user)
+	if err != nil {
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
 }
 
 func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
@@ -37,6 +80,39 @@ func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*
 	return &user, nil
 }
 
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByOIDCSubject finds the user linked to an identity provider account.
+func (r *UserRepository) FindByOIDCSubject(ctx context.Context, issuer, subject string) (*models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, bson.M{"oidc_issuer": issuer, "oidc_subject": subject}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*models.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *UserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
 	var user models.User
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
@@ -74,16 +150,183 @@ func (r *UserRepository) UpdatePassword(ctx context.Context, id primitive.Object
 	return nil
 }
 
-func (r *UserRepository) List(ctx context.Context) ([]*models.User, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{})
+// List returns a page of the users matching filter.
+func (r *UserRepository) List(ctx context.Context, filter *models.UserFilter, sort models.UserSort, page models.PageRequest) (*models.UserPage, error) {
+	match := bson.M{}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		match["$or"] = bson.A{
+			bson.M{"username": pattern},
+			bson.M{"email": pattern},
+		}
+	}
+	if filter.Role != "" {
+		match["role"] = filter.Role
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			match["disabled"] = true
+		} else {
+			match["disabled"] = bson.M{"$ne": true}
+		}
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	found, total, hasMore, err := aggregatePage[*models.User](ctx, r.collection, pipeline, string(sort.Field), sort.Order, page)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
-	var users []*models.User
-	if err := cursor.All(ctx, &users); err != nil {
-		return nil, err
+	result := &models.UserPage{Users: found, Total: total}
+	if result.Users == nil {
+		result.Users = []*models.User{}
 	}
-	return users, nil
+
+	if hasMore {
+		last := found[len(found)-1]
+		var value interface{} = last.Username
+		if sort.Field == models.UserSortCreatedAt {
+			value = last.CreatedAt
+		}
+		if result.NextCursor, err = encodeCursor(value, last.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// UpdateProfile sets the display name and/or email of a user. Nil values
+// are left unchanged and empty ones are removed.
+func (r *UserRepository) UpdateProfile(ctx context.Context, id primitive.ObjectID, displayName, email *string) error {
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	for field, value := range map[string]*string{"display_name": displayName, "email": email} {
+		switch {
+		case value == nil:
+		case *value == "":
+			unset[field] = ""
+		default:
+			set[field] = *value
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateAccess sets the role and/or disabled status of a user. Nil values
+// are left unchanged.
+func (r *UserRepository) UpdateAccess(ctx context.Context, id primitive.ObjectID, role *models.UserRole, disabled *bool) error {
+	set := bson.M{"updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if role != nil {
+		set["role"] = *role
+	}
+	if disabled != nil {
+		if *disabled {
+			set["disabled"] = true
+		} else {
+			update["$unset"] = bson.M{"disabled": ""}
+		}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetPendingTOTP stores a TOTP secret awaiting confirmation.
+func (r *UserRepository) SetPendingTOTP(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return r.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"totp_pending_secret": secret, "updated_at": time.Now()},
+	})
+}
+
+// EnableTOTP activates the pending secret with hashed recovery codes.
+func (r *UserRepository) EnableTOTP(ctx context.Context, id primitive.ObjectID, secret string, step int64, recoveryCodes []string) error {
+	return r.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"totp_enabled":   true,
+			"totp_secret":    secret,
+			"totp_last_step": step,
+			"recovery_codes": recoveryCodes,
+			"updated_at":     time.Now(),
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	})
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, id primitive.ObjectID) error {
+	return r.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{
+			"totp_enabled":        "",
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_last_step":      "",
+			"recovery_codes":      "",
+		},
+	})
+}
+
+func (r *UserRepository) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, recoveryCodes []string) error {
+	return r.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"recovery_codes": recoveryCodes, "updated_at": time.Now()},
+	})
+}
+
+// UseTOTPStep records step as the last used TOTP step. It fails with
+// mongo.ErrNoDocuments when a code of that step or a later one was already
+// used, so each code works once.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	return r.updateOne(ctx, bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"totp_last_step": bson.M{"$lt": step}},
+			bson.M{"totp_last_step": bson.M{"$exists": false}},
+		},
+	}, bson.M{"$set": bson.M{"totp_last_step": step}})
+}
+
+// UseRecoveryCode removes a hashed recovery code. It fails with
+// mongo.ErrNoDocuments when the user has no such code.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, recoveryCode string) error {
+	return r.updateOne(ctx, bson.M{"_id": id, "recovery_codes": recoveryCode}, bson.M{
+		"$pull": bson.M{"recovery_codes": recoveryCode},
+	})
+}
+
+func (r *UserRepository) updateOne(ctx context.Context, filter, update bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// LinkOIDC links a user to an identity provider account.
+func (r *UserRepository) LinkOIDC(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	return r.updateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"oidc_issuer": issuer, "oidc_subject": subject, "updated_at": time.Now()},
+	})
 }
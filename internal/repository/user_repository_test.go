@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestUserRepositoryList(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("filters", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+			{Key: "total", Value: bson.A{bson.D{{Key: "count", Value: int64(0)}}}},
+			{Key: "items", Value: bson.A{}},
+		}))
+
+		enabled := false
+		filter := &models.UserFilter{Query: "a.b", Role: models.RoleAdmin, Disabled: &enabled}
+		sort := models.UserSort{Field: models.UserSortUsername, Order: models.SortAsc}
+		page, err := repo.List(context.Background(), filter, sort, models.PageRequest{})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if page.Users == nil || page.NextCursor != "" {
+			t.Errorf("empty page = %+v", page)
+		}
+
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		pattern, _ := match.Lookup("$or").Array().Index(0).Value().Document().Lookup("username").Regex()
+		if pattern != `a\.b` {
+			t.Errorf("query pattern = %q, want it quoted", pattern)
+		}
+		if role := match.Lookup("role").StringValue(); role != "admin" {
+			t.Errorf("role filter = %q", role)
+		}
+		if ne := match.Lookup("disabled", "$ne").Boolean(); !ne {
+			t.Error("enabled users are not matched by disabled != true")
+		}
+	})
+
+	mt.Run("next cursor", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB)
+		items := bson.A{}
+		for _, name := range []string{"alice", "bob", "carol"} {
+			items = append(items, bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "username", Value: name}})
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+			{Key: "total", Value: bson.A{bson.D{{Key: "count", Value: int64(5)}}}},
+			{Key: "items", Value: items},
+		}))
+
+		sort := models.UserSort{Field: models.UserSortUsername, Order: models.SortAsc}
+		page, err := repo.List(context.Background(), &models.UserFilter{}, sort, models.PageRequest{Limit: 2})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page.Users) != 2 || page.Total != 5 {
+			t.Fatalf("page has %d users of %d, want 2 of 5", len(page.Users), page.Total)
+		}
+
+		after, err := keysetFilter(page.NextCursor, "username", models.SortAsc)
+		if err != nil {
+			t.Fatalf("next cursor: %v", err)
+		}
+		value := after["$or"].(bson.A)[0].(bson.M)["username"].(bson.M)["$gt"].(bson.RawValue)
+		if value.StringValue() != "bob" {
+			t.Errorf("next cursor starts after %v, want bob", value)
+		}
+	})
+}
+
+func TestUserRepositoryUpdateAccess(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("re-enable unsets the flag", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		role := models.RoleAdmin
+		enabled := false
+		if err := repo.UpdateAccess(context.Background(), primitive.NewObjectID(), &role, &enabled); err != nil {
+			t.Fatalf("UpdateAccess: %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if got := update.Lookup("$set", "role").StringValue(); got != "admin" {
+			t.Errorf("role = %q, want admin", got)
+		}
+		if _, err := update.LookupErr("$set", "disabled"); err == nil {
+			t.Error("re-enabling set the disabled field")
+		}
+		if _, err := update.LookupErr("$unset", "disabled"); err != nil {
+			t.Error("re-enabling did not unset the disabled field")
+		}
+	})
+
+	mt.Run("missing user", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		disabled := true
+		if err := repo.UpdateAccess(context.Background(), primitive.NewObjectID(), nil, &disabled); err == nil {
+			t.Error("UpdateAccess of a missing user succeeded")
+		}
+	})
+}
+
+func TestUserRepositoryUpdateProfile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("empty values are removed", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		displayName := "Alice"
+		email := ""
+		if err := repo.UpdateProfile(context.Background(), primitive.NewObjectID(), &displayName, &email); err != nil {
+			t.Fatalf("UpdateProfile: %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if got := update.Lookup("$set", "display_name").StringValue(); got != "Alice" {
+			t.Errorf("display_name = %q, want Alice", got)
+		}
+		if _, err := update.LookupErr("$unset", "email"); err != nil {
+			t.Error("empty email was not removed")
+		}
+	})
+}
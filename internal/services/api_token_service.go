@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidAPIToken is returned when an API token is unknown or expired.
+var ErrInvalidAPIToken = errors.New("invalid API token")
+
+// lastUsedResolution limits how often the last use of a token is written.
+const lastUsedResolution = time.Minute
+
+type APITokenService struct {
+	apiTokenRepo *repository.APITokenRepository
+	userRepo     *repository.UserRepository
+}
+
+func NewAPITokenService(apiTokenRepo *repository.APITokenRepository, userRepo *repository.UserRepository) *APITokenService {
+	return &APITokenService{
+		apiTokenRepo: apiTokenRepo,
+		userRepo:     userRepo,
+	}
+}
+
+// CreateToken creates a token for userID. The plain token is only part of
+// the returned response.
+func (s *APITokenService) CreateToken(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID, req *models.CreateAPITokenRequest) (*models.CreateAPITokenResponse, error) {
+	if err := s.authorize(ctx, currentUser, userID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("token name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	scopes := make([]models.TokenScope, 0, len(req.Scopes))
+	seen := make(map[models.TokenScope]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	plain := utils.APITokenPrefix + secret
+
+	token := &models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plain[:len(utils.APITokenPrefix)+6],
+		TokenHash: hashToken(plain),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.apiTokenRepo.Create(ctx, token); err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return &models.CreateAPITokenResponse{Token: plain, APIToken: token}, nil
+}
+
+func (s *APITokenService) ListTokens(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID) ([]*models.APIToken, error) {
+	if err := s.authorize(ctx, currentUser, userID); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.apiTokenRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	if tokens == nil {
+		tokens = []*models.APIToken{}
+	}
+	return tokens, nil
+}
+
+func (s *APITokenService) RevokeToken(ctx context.Context, currentUser *utils.JWTClaims, userID, tokenID primitive.ObjectID) error {
+	if err := s.authorize(ctx, currentUser, userID); err != nil {
+		return err
+	}
+
+	if err := s.apiTokenRepo.Delete(ctx, tokenID, userID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.New("token not found")
+		}
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserTokens revokes every token of a user.
+func (s *APITokenService) DeleteUserTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return s.apiTokenRepo.DeleteByUserID(ctx, userID)
+}
+
+// AuthenticateAPIToken resolves a personal access token to the claims of its
+// user, restricted to the token scopes.
+func (s *APITokenService) AuthenticateAPIToken(ctx context.Context, plain string) (*utils.JWTClaims, error) {
+	token, err := s.apiTokenRepo.FindByTokenHash(ctx, hashToken(plain))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil || user.Disabled {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		if err := s.apiTokenRepo.UpdateLastUsed(ctx, token.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to update token: %w", err)
+		}
+	}
+
+	scopes := make([]string, 0, len(token.Scopes))
+	for _, scope := range token.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return &utils.JWTClaims{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       string(user.Role),
+		Scopes:     scopes,
+		APITokenID: token.ID,
+	}, nil
+}
+
+// authorize lets users manage their own tokens, and root or admins manage
+// the tokens of service accounts they could have created.
+func (s *APITokenService) authorize(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID) error {
+	if currentUser.UserID == userID {
+		return nil
+	}
+
+	currentRole := models.UserRole(currentUser.Role)
+	if currentRole != models.RoleRoot && currentRole != models.RoleAdmin {
+		return errors.New("insufficient permissions")
+	}
+
+	targetUser, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !targetUser.ServiceAccount {
+		return errors.New("tokens can only be managed for service accounts")
+	}
+	if currentRole == models.RoleAdmin && targetUser.Role != models.RoleMember {
+		return errors.New("insufficient permissions")
+	}
+	return nil
+}
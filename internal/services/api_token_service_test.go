@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCreateAPIToken(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	userID := primitive.NewObjectID()
+	owner := &utils.JWTClaims{UserID: userID, Role: string(models.RoleMember)}
+
+	mt.Run("stores only the hash", func(mt *mtest.T) {
+		service := NewAPITokenService(repository.NewAPITokenRepository(mt.DB), repository.NewUserRepository(mt.DB))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}})
+
+		resp, err := service.CreateToken(context.Background(), owner, userID, &models.CreateAPITokenRequest{
+			Name:   " ci ",
+			Scopes: []models.TokenScope{models.ScopeScriptsRead, models.ScopeScriptsRun, models.ScopeScriptsRead},
+		})
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		if !strings.HasPrefix(resp.Token, utils.APITokenPrefix) || !strings.HasPrefix(resp.Token, resp.APIToken.Prefix) {
+			t.Errorf("token %q does not start with %q", resp.Token, resp.APIToken.Prefix)
+		}
+		if resp.APIToken.Name != "ci" || len(resp.APIToken.Scopes) != 2 {
+			t.Errorf("stored token = %+v", resp.APIToken)
+		}
+
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if doc.Lookup("token_hash").StringValue() != hashToken(resp.Token) {
+			t.Error("stored hash does not match the token")
+		}
+		if strings.Contains(doc.String(), resp.Token) {
+			t.Error("plain token was stored")
+		}
+	})
+
+	mt.Run("rejects invalid requests", func(mt *mtest.T) {
+		service := NewAPITokenService(repository.NewAPITokenRepository(mt.DB), repository.NewUserRepository(mt.DB))
+		past := time.Now().Add(-time.Hour)
+
+		invalid := []*models.CreateAPITokenRequest{
+			{Scopes: []models.TokenScope{models.ScopeScriptsRead}},
+			{Name: "ci"},
+			{Name: "ci", Scopes: []models.TokenScope{"users:write"}},
+			{Name: "ci", Scopes: []models.TokenScope{models.ScopeScriptsRead}, ExpiresAt: &past},
+		}
+		for _, req := range invalid {
+			if _, err := service.CreateToken(context.Background(), owner, userID, req); err == nil {
+				t.Errorf("CreateToken(%+v) succeeded", req)
+			}
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 0 {
+			t.Errorf("invalid requests reached the database: %d commands", len(events))
+		}
+	})
+}
+
+func TestAuthenticateAPITokenExpired(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("expired", func(mt *mtest.T) {
+		service := NewAPITokenService(repository.NewAPITokenRepository(mt.DB), repository.NewUserRepository(mt.DB))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.api_tokens", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "user_id", Value: primitive.NewObjectID()},
+			{Key: "expires_at", Value: time.Now().Add(-time.Minute)},
+		}))
+
+		if _, err := service.AuthenticateAPIToken(context.Background(), utils.APITokenPrefix+"secret"); err != ErrInvalidAPIToken {
+			t.Fatalf("AuthenticateAPIToken = %v, want ErrInvalidAPIToken", err)
+		}
+	})
+
+	mt.Run("scoped claims", func(mt *mtest.T) {
+		service := NewAPITokenService(repository.NewAPITokenRepository(mt.DB), repository.NewUserRepository(mt.DB))
+		tokenID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.api_tokens", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: tokenID},
+				{Key: "user_id", Value: userID},
+				{Key: "scopes", Value: bson.A{"scripts:read"}},
+				{Key: "last_used_at", Value: time.Now()},
+			}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "username", Value: "ci"},
+				{Key: "role", Value: "member"},
+			}),
+		)
+
+		claims, err := service.AuthenticateAPIToken(context.Background(), utils.APITokenPrefix+"secret")
+		if err != nil {
+			t.Fatalf("AuthenticateAPIToken: %v", err)
+		}
+		if claims.APITokenID != tokenID || claims.UserID != userID || !claims.HasScope("scripts:read") || claims.HasScope("scripts:write") {
+			t.Errorf("claims = %+v", claims)
+		}
+		// A token used within the last minute is not written again
+		if events := mt.GetAllStartedEvents(); len(events) != 2 {
+			t.Errorf("started commands = %d, want 2", len(events))
+		}
+	})
+}
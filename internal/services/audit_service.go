@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+)
+
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// ListEntries returns a page of the audit log, newest first.
+func (s *AuditService) ListEntries(ctx context.Context, filter *models.AuditFilter, page models.PageRequest) (*models.AuditPage, error) {
+	entries, err := s.auditRepo.List(ctx, filter, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	return entries, nil
+}
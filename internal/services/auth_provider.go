@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned by an AuthProvider that does not know
+// the user or rejects the password, so the next provider is tried.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// AuthProvider checks a username and password. Providers backed by an
+// external directory create or update the matching user.
+type AuthProvider interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// NewAuthProviders builds the providers listed in AUTH_PROVIDERS, in the
+// order they are tried.
+func NewAuthProviders(userRepo *repository.UserRepository, config *config.Config) ([]AuthProvider, error) {
+	var providers []AuthProvider
+	for _, name := range strings.Split(config.AuthProviders, ",") {
+		switch strings.TrimSpace(name) {
+		case "local":
+			providers = append(providers, NewPasswordProvider(userRepo))
+		case "ldap":
+			provider, err := NewLDAPProvider(userRepo, config, DialLDAP(config))
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no auth provider configured")
+	}
+	return providers, nil
+}
+
+// authenticate returns the user of the first provider accepting the
+// credentials. A provider that fails is logged and skipped, so that an
+// unreachable directory does not keep users of the other providers out.
+func authenticate(ctx context.Context, providers []AuthProvider, logger *zap.Logger, username, password string) (*models.User, error) {
+	for _, provider := range providers {
+		user, err := provider.Authenticate(ctx, username, password)
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		if err != nil {
+			logger.Error("Auth provider failed", zap.String("provider", provider.Name()), zap.Error(err))
+			continue
+		}
+		return user, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// checkPassword reports whether a provider accepts password for user, to
+// confirm sensitive changes of signed in users.
+func checkPassword(ctx context.Context, providers []AuthProvider, logger *zap.Logger, user *models.User, password string) bool {
+	authenticated, err := authenticate(ctx, providers, logger, user.Username, password)
+	return err == nil && authenticated.ID == user.ID
+}
+
+// PasswordProvider authenticates local accounts against the bcrypt hash
+// stored in the user document.
+type PasswordProvider struct {
+	userRepo *repository.UserRepository
+}
+
+func NewPasswordProvider(userRepo *repository.UserRepository) *PasswordProvider {
+	return &PasswordProvider{
+		userRepo: userRepo,
+	}
+}
+
+func (p *PasswordProvider) Name() string {
+	return "local"
+}
+
+func (p *PasswordProvider) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := p.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user.AuthSource != "" || user.ServiceAccount {
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
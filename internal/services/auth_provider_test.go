@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"scripts-management/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// fakeProvider returns a fixed result and counts its calls.
+type fakeProvider struct {
+	name  string
+	user  *models.User
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	p.calls++
+	return p.user, p.err
+}
+
+func TestAuthenticateProviderChain(t *testing.T) {
+	alice := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+
+	tests := []struct {
+		name      string
+		providers []*fakeProvider
+		want      *models.User
+	}{
+		{
+			name: "first provider accepts",
+			providers: []*fakeProvider{
+				{name: "local", user: alice},
+				{name: "ldap", err: errors.New("unreachable")},
+			},
+			want: alice,
+		},
+		{
+			name: "rejected then accepted",
+			providers: []*fakeProvider{
+				{name: "local", err: ErrInvalidCredentials},
+				{name: "ldap", user: alice},
+			},
+			want: alice,
+		},
+		{
+			name: "failing provider is skipped",
+			providers: []*fakeProvider{
+				{name: "ldap", err: errors.New("unreachable")},
+				{name: "local", user: alice},
+			},
+			want: alice,
+		},
+		{
+			name: "no directory role",
+			providers: []*fakeProvider{
+				{name: "ldap", err: ErrNoDirectoryRole},
+				{name: "local", err: ErrInvalidCredentials},
+			},
+		},
+		{
+			name: "every provider fails",
+			providers: []*fakeProvider{
+				{name: "ldap", err: errors.New("unreachable")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := make([]AuthProvider, len(tt.providers))
+			for i, provider := range tt.providers {
+				providers[i] = provider
+			}
+
+			user, err := authenticate(context.Background(), providers, zap.NewNop(), "alice", "secret")
+			if tt.want == nil {
+				if err != ErrInvalidCredentials {
+					t.Fatalf("authenticate = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if err != nil || user != tt.want {
+				t.Fatalf("authenticate = %v, %v", user, err)
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	alice := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	other := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+
+	providers := []AuthProvider{
+		&fakeProvider{name: "ldap", err: errors.New("unreachable")},
+		&fakeProvider{name: "local", user: alice},
+	}
+	if !checkPassword(context.Background(), providers, zap.NewNop(), alice, "secret") {
+		t.Error("password of alice was not accepted")
+	}
+	// A provider resolving the name to another account does not confirm it
+	if checkPassword(context.Background(), providers, zap.NewNop(), other, "secret") {
+		t.Error("password of another account was accepted")
+	}
+}
@@ -2,54 +2,505 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"scripts-management/internal/config"
 	"scripts-management/internal/models"
 	"scripts-management/internal/repository"
 	"scripts-management/pkg/utils"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown,
+	// expired or already used.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrSignupDisabled is returned when public signup is turned off.
+	ErrSignupDisabled = errors.New("signup is disabled")
+	// ErrInvitationRequired is returned when signup is invite-only and no
+	// invitation was given.
+	ErrInvitationRequired = errors.New("an invitation is required to sign up")
+	// ErrInvalidInvitation is returned when an invitation token is unknown,
+	// expired or already used.
+	ErrInvalidInvitation = errors.New("invalid invitation")
+)
+
+// userStatusTTL is how long IsUserDisabled trusts a looked up status.
+// Disabling a user on another replica takes effect within it.
+const userStatusTTL = 30 * time.Second
+
+type userStatus struct {
+	disabled  bool
+	checkedAt time.Time
+}
+
 type AuthService struct {
-	userRepo   *repository.UserRepository
-	jwtManager *utils.JWTManager
+	userRepo         *repository.UserRepository
+	sessionRepo      *repository.SessionRepository
+	revokedTokenRepo *repository.RevokedTokenRepository
+	invitationRepo   *repository.InvitationRepository
+	transactor       *repository.Transactor
+	jwtManager       *utils.JWTManager
+	providers        []AuthProvider
+	passwordPolicy   *PasswordPolicy
+	loginThrottle    *LoginThrottle
+	twoFactorService *TwoFactorService
+	oidcService      *OIDCService
+	config           *config.Config
+	logger           *zap.Logger
+
+	statusMu     sync.Mutex
+	userStatuses map[primitive.ObjectID]userStatus
 }
 
 func NewAuthService(
 	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	revokedTokenRepo *repository.RevokedTokenRepository,
+	invitationRepo *repository.InvitationRepository,
+	transactor *repository.Transactor,
 	jwtManager *utils.JWTManager,
+	providers []AuthProvider,
+	passwordPolicy *PasswordPolicy,
+	loginThrottle *LoginThrottle,
+	twoFactorService *TwoFactorService,
+	oidcService *OIDCService,
+	config *config.Config,
+	logger *zap.Logger,
 ) *AuthService {
 	return &AuthService{
-		userRepo:   userRepo,
-		jwtManager: jwtManager,
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		revokedTokenRepo: revokedTokenRepo,
+		invitationRepo:   invitationRepo,
+		transactor:       transactor,
+		jwtManager:       jwtManager,
+		providers:        providers,
+		passwordPolicy:   passwordPolicy,
+		loginThrottle:    loginThrottle,
+		twoFactorService: twoFactorService,
+		oidcService:      oidcService,
+		config:           config,
+		logger:           logger,
 	}
 }
 
+// CreateUser creates a user with the requested role, defaulting to member.
+// Root accounts can only come from the configuration.
 func (s *AuthService) CreateUser(ctx context.Context, req *models.SignupRequest) error {
+	role := req.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+	if role != models.RoleMember && role != models.RoleAdmin {
+		return errors.New("invalid role")
+	}
+
+	return s.createUser(ctx, primitive.NewObjectID(), req, role)
+}
+
+// Signup creates an account through the public endpoint, according to the
+// configured signup mode. The role always comes from the invitation, or is
+// member when signup is open.
+func (s *AuthService) Signup(ctx context.Context, req *models.SignupRequest) error {
+	if req.Role != "" {
+		return errors.New("role cannot be chosen on signup")
+	}
+
+	switch models.SignupMode(s.config.SignupMode) {
+	case models.SignupOpen:
+		if req.InviteToken == "" {
+			return s.createUser(ctx, primitive.NewObjectID(), req, models.RoleMember)
+		}
+	case models.SignupInvite:
+		if req.InviteToken == "" {
+			return ErrInvitationRequired
+		}
+	default:
+		return ErrSignupDisabled
+	}
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		userID := primitive.NewObjectID()
+		invitation, err := s.invitationRepo.Consume(ctx, hashToken(req.InviteToken), userID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrInvalidInvitation
+		}
+		if err != nil {
+			return fmt.Errorf("failed to use invitation: %w", err)
+		}
+
+		return s.createUser(ctx, userID, req, invitation.Role)
+	})
+}
+
+func (s *AuthService) createUser(ctx context.Context, userID primitive.ObjectID, req *models.SignupRequest, role models.UserRole) error {
+	if req.Username == "" {
+		return errors.New("username is required")
+	}
+	if err := s.passwordPolicy.Validate(req.Password, req.Username); err != nil {
+		return err
+	}
+
+	_, err := s.userRepo.FindByUsername(ctx, req.Username)
+	if err == nil {
+		return errors.New("username already taken")
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("failed to check username: %w", err)
+	}
+
+	email, err := normalizeEmail(ctx, s.userRepo, userID, req.Email)
+	if err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
 	user := &models.User{
+		ID:       userID,
 		Username: req.Username,
+		Email:    email,
 		Password: string(hashedPassword),
-		Role:     req.Role,
+		Role:     role,
 	}
 
-	return s.userRepo.Create(ctx, user)
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("username or email already in use")
+		}
+		return err
+	}
+	return nil
 }
 
-func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (string, error) {
-	user, err := s.userRepo.FindByUsername(ctx, req.Username)
+// Login checks the credentials of a user coming from ip with each auth
+// provider in turn. Failed attempts are counted per account and per IP
+// address, and lock them out once they reach the configured limits. Users
+// with 2FA get a challenge to complete with LoginMFA instead of tokens.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ip string) (*models.LoginResponse, error) {
+	if err := s.loginThrottle.Check(ctx, req.Username, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := authenticate(ctx, s.providers, s.logger, req.Username, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		var userID primitive.ObjectID
+		if user, err := s.userRepo.FindByUsername(ctx, req.Username); err == nil {
+			userID = user.ID
+		}
+		if err := s.loginThrottle.Failure(ctx, req.Username, ip, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return "", errors.New("invalid credentials")
+	response, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	// With 2FA the failures are only reset once the challenge is passed
+	if !response.MFARequired {
+		if err := s.loginThrottle.Success(ctx, req.Username); err != nil {
+			return nil, err
+		}
 	}
+	return response, nil
+}
+
+// StartOIDCLogin returns the URL of the identity provider to log in at.
+func (s *AuthService) StartOIDCLogin(ctx context.Context) (*models.OIDCLoginResponse, error) {
+	authorizationURL, state, err := s.oidcService.StartLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OIDCLoginResponse{AuthorizationURL: authorizationURL, State: state}, nil
+}
+
+// LoginOIDC completes a login at the identity provider. Like a password
+// login it issues tokens, or a 2FA challenge.
+func (s *AuthService) LoginOIDC(ctx context.Context, req *models.OIDCCallbackRequest) (*models.LoginResponse, error) {
+	user, err := s.oidcService.CompleteLogin(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.completeLogin(ctx, user)
+}
+
+// completeLogin issues tokens to an authenticated user, or a challenge when
+// the user has to pass 2FA first.
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User) (*models.LoginResponse, error) {
+	if user.Disabled {
+		return nil, errors.New("account is disabled")
+	}
+
+	mfaRequired := user.TOTPEnabled
+	var err error
+	if !mfaRequired {
+		if mfaRequired, err = s.twoFactorService.Required(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	if mfaRequired {
+		mfaToken, setup, err := s.twoFactorService.StartChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &models.LoginResponse{MFARequired: true, MFAToken: mfaToken, MFASetup: setup}, nil
+	}
+
+	tokens, err := s.createSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResponse{TokenResponse: tokens}, nil
+}
+
+// LoginMFA completes a login challenge with a TOTP or recovery code sent
+// from ip. Wrong codes are counted like wrong passwords in Login. When the
+// challenge enrolled the user, the response holds their recovery codes.
+func (s *AuthService) LoginMFA(ctx context.Context, req *models.MFALoginRequest, ip string) (*models.LoginResponse, error) {
+	user, recoveryCodes, err := s.twoFactorService.CompleteChallenge(ctx, req.MFAToken, req.Code, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.createSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResponse{TokenResponse: tokens, RecoveryCodes: recoveryCodes}, nil
+}
+
+// ValidatePassword checks a new password of the given user against the
+// password policy.
+func (s *AuthService) ValidatePassword(password, username string) error {
+	return s.passwordPolicy.Validate(password, username)
+}
+
+// Refresh rotates a refresh token and issues a new access token for its
+// session. Presenting a token that was already rotated revokes the whole
+// session, since either the client or an attacker holds a stolen copy.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenResponse, error) {
+	tokenHash := hashToken(refreshToken)
+
+	session, err := s.sessionRepo.FindByTokenHash(ctx, tokenHash)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if reused, err := s.sessionRepo.FindByPreviousTokenHash(ctx, tokenHash); err == nil {
+			if err := s.revokeSessions(ctx, []*models.Session{reused}); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil || user.Disabled {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	accessToken, claims, err := s.jwtManager.GenerateToken(user.ID, user.Username, string(user.Role))
+	if err != nil {
+		return nil, err
+	}
+	newRefreshToken, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.sessionRepo.Rotate(ctx, session, hashToken(newRefreshToken), claims.ID, claims.ExpiresAt.Time)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Rotated concurrently by another request with the same token
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate session: %w", err)
+	}
+
+	// The previous access token of the session is replaced by the new one
+	if err := s.revokedTokenRepo.Add(ctx, []models.RevokedToken{{ID: session.AccessTokenID, ExpiresAt: session.AccessExpiresAt}}); err != nil {
+		return nil, fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return &models.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Logout ends the session of a refresh token and revokes its access token.
+// Unknown tokens are ignored so that logging out twice is not an error.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	session, err := s.sessionRepo.FindByTokenHash(ctx, hashToken(refreshToken))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+
+	return s.revokeSessions(ctx, []*models.Session{session})
+}
+
+// RevokeUserSessions ends every session of a user and revokes their access
+// tokens.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID) error {
+	sessions, err := s.sessionRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to find sessions: %w", err)
+	}
+
+	return s.revokeSessions(ctx, sessions)
+}
+
+// RevokeOtherSessions ends every session of a user except the one that
+// issued the access token keepTokenID.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID primitive.ObjectID, keepTokenID string) error {
+	sessions, err := s.sessionRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to find sessions: %w", err)
+	}
+
+	others := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.AccessTokenID != keepTokenID {
+			others = append(others, session)
+		}
+	}
+	return s.revokeSessions(ctx, others)
+}
+
+// IsTokenRevoked reports whether an access token was denylisted.
+func (s *AuthService) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	return s.revokedTokenRepo.Exists(ctx, tokenID)
+}
+
+// IsUserDisabled reports whether a user was disabled or deleted. Statuses
+// are cached for userStatusTTL so that every request does not hit the
+// database; it backs up session revocation for access tokens issued while
+// the user was being disabled.
+func (s *AuthService) IsUserDisabled(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	s.statusMu.Lock()
+	status, ok := s.userStatuses[userID]
+	s.statusMu.Unlock()
+	if ok && time.Since(status.checkedAt) < userStatusTTL {
+		return status.disabled, nil
+	}
+
+	disabled := false
+	user, err := s.userRepo.FindByID(ctx, userID)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		disabled = true
+	case err != nil:
+		return false, fmt.Errorf("failed to find user: %w", err)
+	default:
+		disabled = user.Disabled
+	}
+
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	if s.userStatuses == nil {
+		s.userStatuses = make(map[primitive.ObjectID]userStatus)
+	}
+	for id, cached := range s.userStatuses {
+		if time.Since(cached.checkedAt) >= userStatusTTL {
+			delete(s.userStatuses, id)
+		}
+	}
+	s.userStatuses[userID] = userStatus{disabled: disabled, checkedAt: time.Now()}
+	return disabled, nil
+}
+
+// forgetUserStatus drops the cached status of a user after it changed, so
+// this replica applies the change at once.
+func (s *AuthService) forgetUserStatus(userID primitive.ObjectID) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	delete(s.userStatuses, userID)
+}
+
+func (s *AuthService) createSession(ctx context.Context, user *models.User) (*models.TokenResponse, error) {
+	accessToken, claims, err := s.jwtManager.GenerateToken(user.ID, user.Username, string(user.Role))
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:          user.ID,
+		TokenHash:       hashToken(refreshToken),
+		AccessTokenID:   claims.ID,
+		AccessExpiresAt: claims.ExpiresAt.Time,
+		ExpiresAt:       time.Now().Add(s.config.RefreshTokenTTL),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return &models.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (s *AuthService) revokeSessions(ctx context.Context, sessions []*models.Session) error {
+	revoked := make([]models.RevokedToken, 0, len(sessions))
+	for _, session := range sessions {
+		revoked = append(revoked, models.RevokedToken{ID: session.AccessTokenID, ExpiresAt: session.AccessExpiresAt})
+	}
+	if err := s.revokedTokenRepo.Add(ctx, revoked); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	for _, session := range sessions {
+		if err := s.sessionRepo.Delete(ctx, session.ID); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("failed to delete session: %w", err)
+		}
+	}
+	return nil
+}
+
+// generateToken returns an opaque random token. Only its hash is stored.
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
-	return s.jwtManager.GenerateToken(user.ID, user.Username, string(user.Role))
+// JWKS returns the public keys tokens can be verified with.
+func (s *AuthService) JWKS() utils.JWKS {
+	return s.jwtManager.JWKS()
 }
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 43 || token == other {
+		t.Errorf("refresh tokens %q and %q are not 32 random bytes", token, other)
+	}
+
+	hash := hashToken(token)
+	if len(hash) != 64 || hash != hashToken(token) || hash == hashToken(other) {
+		t.Errorf("hashToken(%q) = %q", token, hash)
+	}
+}
+
+func TestRefreshReusedToken(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("revokes the session", func(mt *mtest.T) {
+		service := &AuthService{
+			sessionRepo:      repository.NewSessionRepository(mt.DB),
+			revokedTokenRepo: repository.NewRevokedTokenRepository(mt.DB),
+		}
+		sessionID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			// No session holds the token anymore
+			mtest.CreateCursorResponse(0, "db.sessions", mtest.FirstBatch),
+			// It was rotated out of this session
+			mtest.CreateCursorResponse(0, "db.sessions", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: sessionID},
+				{Key: "access_token_id", Value: "jti-1"},
+			}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		)
+
+		if _, err := service.Refresh(context.Background(), "stolen"); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("Refresh = %v, want ErrInvalidRefreshToken", err)
+		}
+
+		var commands []string
+		for _, event := range mt.GetAllStartedEvents() {
+			commands = append(commands, event.CommandName)
+		}
+		if len(commands) != 4 || commands[2] != "update" || commands[3] != "delete" {
+			t.Fatalf("commands = %v, want the access token revoked and the session deleted", commands)
+		}
+	})
+}
+
+func TestSignupMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		req  models.SignupRequest
+		want error
+	}{
+		{"disabled", models.SignupRequest{Username: "alice", Password: "secret"}, ErrSignupDisabled},
+		{"", models.SignupRequest{Username: "alice", Password: "secret"}, ErrSignupDisabled},
+		{"invite", models.SignupRequest{Username: "alice", Password: "secret"}, ErrInvitationRequired},
+	}
+	for _, tt := range tests {
+		service := &AuthService{config: &config.Config{SignupMode: tt.mode}}
+		if err := service.Signup(context.Background(), &tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("Signup in mode %q = %v, want %v", tt.mode, err, tt.want)
+		}
+	}
+
+	// The role of a public signup never comes from the request
+	service := &AuthService{config: &config.Config{SignupMode: "open"}}
+	if err := service.Signup(context.Background(), &models.SignupRequest{Username: "alice", Password: "secret", Role: models.RoleAdmin}); err == nil {
+		t.Error("signup chose its own role")
+	}
+}
+
+func TestCreateUserRole(t *testing.T) {
+	service := &AuthService{}
+	for _, role := range []models.UserRole{models.RoleRoot, "owner"} {
+		if err := service.CreateUser(context.Background(), &models.SignupRequest{Username: "alice", Password: "secret", Role: role}); err == nil {
+			t.Errorf("CreateUser created a %s account", role)
+		}
+	}
+}
+
+func TestIsUserDisabled(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("caches the status", func(mt *mtest.T) {
+		service := &AuthService{userRepo: repository.NewUserRepository(mt.DB)}
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "disabled", Value: false},
+			}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "disabled", Value: true},
+			}),
+		)
+
+		for i := 0; i < 2; i++ {
+			if disabled, err := service.IsUserDisabled(context.Background(), userID); err != nil || disabled {
+				t.Fatalf("IsUserDisabled = %v, %v, want false", disabled, err)
+			}
+		}
+		if n := len(mt.GetAllStartedEvents()); n != 1 {
+			t.Fatalf("%d lookups, want the second one served from the cache", n)
+		}
+
+		service.forgetUserStatus(userID)
+		if disabled, err := service.IsUserDisabled(context.Background(), userID); err != nil || !disabled {
+			t.Fatalf("IsUserDisabled after a change = %v, %v, want true", disabled, err)
+		}
+	})
+
+	mt.Run("deleted users are disabled", func(mt *mtest.T) {
+		service := &AuthService{userRepo: repository.NewUserRepository(mt.DB)}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		if disabled, err := service.IsUserDisabled(context.Background(), primitive.NewObjectID()); err != nil || !disabled {
+			t.Fatalf("IsUserDisabled = %v, %v, want true", disabled, err)
+		}
+	})
+}
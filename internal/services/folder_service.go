@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FolderService struct {
+	folderRepo *repository.FolderRepository
+	scriptRepo *repository.ScriptRepository
+	transactor *repository.Transactor
+}
+
+func NewFolderService(
+	folderRepo *repository.FolderRepository,
+	scriptRepo *repository.ScriptRepository,
+	transactor *repository.Transactor,
+) *FolderService {
+	return &FolderService{
+		folderRepo: folderRepo,
+		scriptRepo: scriptRepo,
+		transactor: transactor,
+	}
+}
+
+// ListFolders returns all folders of a user. The tree is built from the
+// parent IDs of the folders.
+func (s *FolderService) ListFolders(ctx context.Context, userID primitive.ObjectID) ([]*models.Folder, error) {
+	folders, err := s.folderRepo.FindByOwnerID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find folders: %w", err)
+	}
+
+	return folders, nil
+}
+
+func (s *FolderService) CreateFolder(ctx context.Context, userID primitive.ObjectID, req *models.CreateFolderRequest) (*models.Folder, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("folder name is required")
+	}
+
+	folders, err := s.folderRepo.FindByOwnerID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find folders: %w", err)
+	}
+
+	parentID, err := parseParentID(folders, req.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &models.Folder{
+		Name:     name,
+		ParentID: parentID,
+		OwnerID:  userID,
+	}
+	if err := checkSiblingName(folders, folder); err != nil {
+		return nil, err
+	}
+
+	if err := s.folderRepo.Create(ctx, folder); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+// UpdateFolder renames a folder or moves it under another parent. A folder
+// cannot be moved into itself or one of its subfolders.
+func (s *FolderService) UpdateFolder(ctx context.Context, userID, folderID primitive.ObjectID, req *models.UpdateFolderRequest) (*models.Folder, error) {
+	folders, err := s.folderRepo.FindByOwnerID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find folders: %w", err)
+	}
+
+	folder := findFolder(folders, folderID)
+	if folder == nil {
+		return nil, errors.New("folder not found")
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		folder.Name = name
+	}
+	if req.ParentID != nil {
+		parentID, err := parseParentID(folders, *req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Walk up from the new parent to make sure the tree stays acyclic
+		for ancestor := findFolder(folders, parentID); ancestor != nil; ancestor = findFolder(folders, ancestor.ParentID) {
+			if ancestor.ID == folder.ID {
+				return nil, errors.New("cannot move a folder into itself")
+			}
+		}
+		folder.ParentID = parentID
+	}
+
+	if err := checkSiblingName(folders, folder); err != nil {
+		return nil, err
+	}
+
+	if err := s.folderRepo.Update(ctx, folder); err != nil {
+		return nil, fmt.Errorf("failed to update folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+// DeleteFolder removes a folder. Its subfolders and scripts move up to the
+// parent of the folder.
+func (s *FolderService) DeleteFolder(ctx context.Context, userID, folderID primitive.ObjectID) error {
+	folder, err := s.folderRepo.FindByID(ctx, folderID)
+	if err != nil || folder.OwnerID != userID {
+		return errors.New("folder not found")
+	}
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.folderRepo.MoveChildren(ctx, folder.ID, folder.ParentID); err != nil {
+			return fmt.Errorf("failed to move subfolders: %w", err)
+		}
+		if err := s.scriptRepo.MoveFolder(ctx, folder.ID, folder.ParentID); err != nil {
+			return fmt.Errorf("failed to move scripts: %w", err)
+		}
+		if err := s.folderRepo.Delete(ctx, folder.ID); err != nil {
+			return fmt.Errorf("failed to delete folder: %w", err)
+		}
+		return nil
+	})
+}
+
+// parseParentID parses a parent folder ID among the folders of the user. An
+// empty ID is the root.
+func parseParentID(folders []*models.Folder, parentID string) (primitive.ObjectID, error) {
+	if parentID == "" {
+		return primitive.NilObjectID, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(parentID)
+	if err != nil {
+		return primitive.NilObjectID, errors.New("invalid parent folder ID format")
+	}
+	if findFolder(folders, id) == nil {
+		return primitive.NilObjectID, errors.New("parent folder not found")
+	}
+
+	return id, nil
+}
+
+func checkSiblingName(folders []*models.Folder, folder *models.Folder) error {
+	for _, other := range folders {
+		if other.ID != folder.ID && other.ParentID == folder.ParentID && strings.EqualFold(other.Name, folder.Name) {
+			return fmt.Errorf("folder %s already exists", folder.Name)
+		}
+	}
+	return nil
+}
+
+func findFolder(folders []*models.Folder, id primitive.ObjectID) *models.Folder {
+	if id.IsZero() {
+		return nil
+	}
+	for _, folder := range folders {
+		if folder.ID == id {
+			return folder
+		}
+	}
+	return nil
+}
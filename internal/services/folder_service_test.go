@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// folderTree returns a root folder "a" with a child "b" and a grandchild "c".
+func folderTree() (a, b, c *models.Folder) {
+	a = &models.Folder{ID: primitive.NewObjectID(), Name: "a"}
+	b = &models.Folder{ID: primitive.NewObjectID(), Name: "b", ParentID: a.ID}
+	c = &models.Folder{ID: primitive.NewObjectID(), Name: "c", ParentID: b.ID}
+	return a, b, c
+}
+
+func TestParseParentID(t *testing.T) {
+	a, b, c := folderTree()
+	folders := []*models.Folder{a, b, c}
+
+	if id, err := parseParentID(folders, ""); err != nil || !id.IsZero() {
+		t.Errorf("parseParentID of the root = %v, %v", id, err)
+	}
+	if id, err := parseParentID(folders, b.ID.Hex()); err != nil || id != b.ID {
+		t.Errorf("parseParentID = %v, %v, want %v", id, err, b.ID)
+	}
+	if _, err := parseParentID(folders, "folder"); err == nil {
+		t.Error("parseParentID accepted an invalid ID")
+	}
+	if _, err := parseParentID(folders, primitive.NewObjectID().Hex()); err == nil {
+		t.Error("parseParentID accepted a folder of another user")
+	}
+}
+
+func TestCheckSiblingName(t *testing.T) {
+	a, b, c := folderTree()
+	folders := []*models.Folder{a, b, c}
+
+	if err := checkSiblingName(folders, &models.Folder{Name: "B", ParentID: a.ID}); err == nil {
+		t.Error("duplicate name in the same folder was accepted")
+	}
+	if err := checkSiblingName(folders, &models.Folder{Name: "b"}); err != nil {
+		t.Errorf("same name in another folder was rejected: %v", err)
+	}
+	// Renaming a folder to its own name is not a conflict
+	if err := checkSiblingName(folders, b); err != nil {
+		t.Errorf("folder conflicts with itself: %v", err)
+	}
+}
+
+func TestFindFolder(t *testing.T) {
+	a, b, c := folderTree()
+	folders := []*models.Folder{a, b, c}
+
+	if findFolder(folders, c.ID) != c {
+		t.Error("findFolder did not find c")
+	}
+	if findFolder(folders, primitive.NilObjectID) != nil || findFolder(folders, primitive.NewObjectID()) != nil {
+		t.Error("findFolder found a folder that does not exist")
+	}
+}
+
+func TestUpdateFolderRejectsCycles(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("move into a descendant", func(mt *mtest.T) {
+		a, b, c := folderTree()
+		var docs []bson.D
+		for _, folder := range []*models.Folder{a, b, c} {
+			doc := bson.D{{Key: "_id", Value: folder.ID}, {Key: "name", Value: folder.Name}}
+			if !folder.ParentID.IsZero() {
+				doc = append(doc, bson.E{Key: "parent_id", Value: folder.ParentID})
+			}
+			docs = append(docs, doc)
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.folders", mtest.FirstBatch, docs...))
+
+		service := NewFolderService(repository.NewFolderRepository(mt.DB), nil, nil)
+		parentID := c.ID.Hex()
+		if _, err := service.UpdateFolder(context.Background(), primitive.NewObjectID(), a.ID, &models.UpdateFolderRequest{ParentID: &parentID}); err == nil {
+			t.Fatal("folder was moved into its own descendant")
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 1 {
+			t.Errorf("started commands = %d, want only the folder lookup", len(events))
+		}
+	})
+}
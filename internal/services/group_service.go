@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GroupService struct {
+	groupRepo       *repository.GroupRepository
+	userRepo        *repository.UserRepository
+	scriptShareRepo *repository.ScriptShareRepository
+}
+
+func NewGroupService(
+	groupRepo *repository.GroupRepository,
+	userRepo *repository.UserRepository,
+	scriptShareRepo *repository.ScriptShareRepository,
+) *GroupService {
+	return &GroupService{
+		groupRepo:       groupRepo,
+		userRepo:        userRepo,
+		scriptShareRepo: scriptShareRepo,
+	}
+}
+
+func (s *GroupService) CreateGroup(ctx context.Context, currentUser *utils.JWTClaims, req *models.CreateGroupRequest) (*models.Group, error) {
+	if req.Name == "" {
+		return nil, errors.New("group name is required")
+	}
+
+	ownerID := currentUser.UserID
+	if req.OwnerID != "" {
+		var err error
+		if ownerID, err = s.findUserID(ctx, req.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+
+	memberIDs := make([]primitive.ObjectID, 0, len(req.MemberIDs))
+	for _, id := range req.MemberIDs {
+		memberID, err := s.findUserID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+
+	group := &models.Group{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     ownerID,
+		MemberIDs:   memberIDs,
+	}
+
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	return group, nil
+}
+
+// ListGroups returns every group to root and admins, and the groups the user
+// owns or belongs to otherwise.
+func (s *GroupService) ListGroups(ctx context.Context, currentUser *utils.JWTClaims) ([]*models.Group, error) {
+	var groups []*models.Group
+	var err error
+	if isAdmin(currentUser) {
+		groups, err = s.groupRepo.List(ctx)
+	} else {
+		groups, err = s.groupRepo.FindByUserID(ctx, currentUser.UserID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find groups: %w", err)
+	}
+
+	return groups, nil
+}
+
+func (s *GroupService) GetGroup(ctx context.Context, currentUser *utils.JWTClaims, groupID primitive.ObjectID) (*models.Group, error) {
+	group, err := s.groupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find group: %w", err)
+	}
+
+	if !canManageGroup(currentUser, group) && !isGroupMember(group, currentUser.UserID) {
+		return nil, errors.New("access denied: not a member of this group")
+	}
+
+	return group, nil
+}
+
+func (s *GroupService) UpdateGroup(ctx context.Context, currentUser *utils.JWTClaims, groupID primitive.ObjectID, req *models.UpdateGroupRequest) (*models.Group, error) {
+	group, err := s.findManagedGroup(ctx, currentUser, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		group.Name = req.Name
+	}
+	if req.Description != "" {
+		group.Description = req.Description
+	}
+	if req.OwnerID != "" {
+		if group.OwnerID, err = s.findUserID(ctx, req.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		return nil, fmt.Errorf("failed to update group: %w", err)
+	}
+
+	return group, nil
+}
+
+// DeleteGroup removes a group together with the script shares granted to it.
+func (s *GroupService) DeleteGroup(ctx context.Context, currentUser *utils.JWTClaims, groupID primitive.ObjectID) error {
+	if _, err := s.findManagedGroup(ctx, currentUser, groupID); err != nil {
+		return err
+	}
+
+	if err := s.scriptShareRepo.DeleteByGroupID(ctx, groupID); err != nil {
+		return fmt.Errorf("failed to delete group shares: %w", err)
+	}
+
+	if err := s.groupRepo.Delete(ctx, groupID); err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	return nil
+}
+
+func (s *GroupService) AddMember(ctx context.Context, currentUser *utils.JWTClaims, groupID primitive.ObjectID, userID string) error {
+	if _, err := s.findManagedGroup(ctx, currentUser, groupID); err != nil {
+		return err
+	}
+
+	memberID, err := s.findUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.groupRepo.AddMember(ctx, groupID, memberID); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *GroupService) RemoveMember(ctx context.Context, currentUser *utils.JWTClaims, groupID primitive.ObjectID, userID string) error {
+	if _, err := s.findManagedGroup(ctx, currentUser, groupID); err != nil {
+		return err
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID format")
+	}
+
+	if err := s.groupRepo.RemoveMember(ctx, groupID, memberID); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *GroupService) findManagedGroup(ctx context.Context, currentUser *utils.JWTClaims, groupID primitive.ObjectID) (*models.Group, error) {
+	group, err := s.groupRepo.FindByID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find group: %w", err)
+	}
+
+	if !canManageGroup(currentUser, group) {
+		return nil, errors.New("access denied: only admins and the group owner can manage the group")
+	}
+
+	return group, nil
+}
+
+func (s *GroupService) findUserID(ctx context.Context, id string) (primitive.ObjectID, error) {
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, errors.New("invalid user ID format")
+	}
+
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return primitive.NilObjectID, fmt.Errorf("user %s not found", id)
+	}
+
+	return userID, nil
+}
+
+func isAdmin(currentUser *utils.JWTClaims) bool {
+	role := models.UserRole(currentUser.Role)
+	return role == models.RoleRoot || role == models.RoleAdmin
+}
+
+func canManageGroup(currentUser *utils.JWTClaims, group *models.Group) bool {
+	return isAdmin(currentUser) || group.OwnerID == currentUser.UserID
+}
+
+func isGroupMember(group *models.Group, userID primitive.ObjectID) bool {
+	for _, memberID := range group.MemberIDs {
+		if memberID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"scripts-management/internal/models"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCanManageGroup(t *testing.T) {
+	owner := primitive.NewObjectID()
+	member := primitive.NewObjectID()
+	group := &models.Group{OwnerID: owner, MemberIDs: []primitive.ObjectID{owner, member}}
+
+	tests := []struct {
+		name   string
+		claims *utils.JWTClaims
+		want   bool
+	}{
+		{"owner", &utils.JWTClaims{UserID: owner, Role: string(models.RoleMember)}, true},
+		{"member", &utils.JWTClaims{UserID: member, Role: string(models.RoleMember)}, false},
+		{"admin", &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleAdmin)}, true},
+		{"root", &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleRoot)}, true},
+	}
+	for _, tt := range tests {
+		if got := canManageGroup(tt.claims, group); got != tt.want {
+			t.Errorf("canManageGroup(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsGroupMember(t *testing.T) {
+	member := primitive.NewObjectID()
+	group := &models.Group{MemberIDs: []primitive.ObjectID{primitive.NewObjectID(), member}}
+
+	if !isGroupMember(group, member) {
+		t.Error("member is not a member")
+	}
+	if isGroupMember(group, primitive.NewObjectID()) {
+		t.Error("stranger is a member")
+	}
+	if isGroupMember(&models.Group{}, member) {
+		t.Error("member of an empty group")
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultInvitationTTL is used when an invitation is created without expiry.
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+type InvitationService struct {
+	invitationRepo *repository.InvitationRepository
+}
+
+func NewInvitationService(invitationRepo *repository.InvitationRepository) *InvitationService {
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+	}
+}
+
+// CreateInvitation creates a single-use signup invitation. Admins can only
+// invite members; root can also invite admins.
+func (s *InvitationService) CreateInvitation(ctx context.Context, currentUser *utils.JWTClaims, req *models.CreateInvitationRequest) (*models.CreateInvitationResponse, error) {
+	currentRole := models.UserRole(currentUser.Role)
+	if currentRole != models.RoleRoot && currentRole != models.RoleAdmin {
+		return nil, errors.New("insufficient permissions")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+	if role != models.RoleMember && role != models.RoleAdmin {
+		return nil, errors.New("invalid role")
+	}
+	if currentRole == models.RoleAdmin && role == models.RoleAdmin {
+		return nil, errors.New("admin cannot invite other admins")
+	}
+
+	expiresAt := time.Now().Add(defaultInvitationTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, errors.New("expiry must be in the future")
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &models.Invitation{
+		TokenHash: hashToken(token),
+		Role:      role,
+		CreatedBy: currentUser.UserID,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.invitationRepo.Create(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return &models.CreateInvitationResponse{Token: token, Invitation: invitation}, nil
+}
+
+func (s *InvitationService) ListInvitations(ctx context.Context) ([]*models.Invitation, error) {
+	invitations, err := s.invitationRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	if invitations == nil {
+		invitations = []*models.Invitation{}
+	}
+	return invitations, nil
+}
+
+// RevokeInvitation deletes an invitation. Admins cannot revoke invitations
+// for admin accounts.
+func (s *InvitationService) RevokeInvitation(ctx context.Context, currentUser *utils.JWTClaims, invitationID primitive.ObjectID) error {
+	invitation, err := s.invitationRepo.FindByID(ctx, invitationID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.New("invitation not found")
+		}
+		return fmt.Errorf("failed to find invitation: %w", err)
+	}
+
+	if models.UserRole(currentUser.Role) == models.RoleAdmin && invitation.Role != models.RoleMember {
+		return errors.New("insufficient permissions")
+	}
+
+	return s.invitationRepo.Delete(ctx, invitationID)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCreateInvitation(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	claims := func(role models.UserRole) *utils.JWTClaims {
+		return &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(role)}
+	}
+
+	mt.Run("role rules", func(mt *mtest.T) {
+		service := NewInvitationService(repository.NewInvitationRepository(mt.DB))
+		past := time.Now().Add(-time.Hour)
+
+		denied := []struct {
+			name    string
+			inviter models.UserRole
+			req     models.CreateInvitationRequest
+		}{
+			{"member inviting", models.RoleMember, models.CreateInvitationRequest{}},
+			{"admin inviting an admin", models.RoleAdmin, models.CreateInvitationRequest{Role: models.RoleAdmin}},
+			{"root account", models.RoleRoot, models.CreateInvitationRequest{Role: models.RoleRoot}},
+			{"past expiry", models.RoleRoot, models.CreateInvitationRequest{ExpiresAt: &past}},
+		}
+		for _, tt := range denied {
+			if _, err := service.CreateInvitation(context.Background(), claims(tt.inviter), &tt.req); err == nil {
+				t.Errorf("%s was allowed", tt.name)
+			}
+		}
+	})
+
+	mt.Run("defaults", func(mt *mtest.T) {
+		service := NewInvitationService(repository.NewInvitationRepository(mt.DB))
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}})
+
+		resp, err := service.CreateInvitation(context.Background(), claims(models.RoleAdmin), &models.CreateInvitationRequest{})
+		if err != nil {
+			t.Fatalf("CreateInvitation: %v", err)
+		}
+		if resp.Invitation.Role != models.RoleMember {
+			t.Errorf("role = %s, want member", resp.Invitation.Role)
+		}
+		if ttl := time.Until(resp.Invitation.ExpiresAt); ttl < defaultInvitationTTL-time.Minute || ttl > defaultInvitationTTL {
+			t.Errorf("invitation expires in %v, want %v", ttl, defaultInvitationTTL)
+		}
+		if resp.Invitation.TokenHash != hashToken(resp.Token) {
+			t.Error("stored hash does not match the token")
+		}
+	})
+}
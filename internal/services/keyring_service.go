@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// KeyringService keeps the JWT signing keys in MongoDB so that every replica
+// signs and validates with the same keys and tokens survive restarts.
+//
+// A new key is only used for signing once it is older than the refresh
+// interval, which gives the other replicas time to load it first. A replaced
+// key stays in the keyring until the last token it signed has expired.
+// Private keys are stored encrypted with JWT_KEY_SECRET.
+type KeyringService struct {
+	signingKeyRepo *repository.SigningKeyRepository
+	jwtManager     *utils.JWTManager
+	cipher         *utils.Cipher
+	logger         *zap.Logger
+}
+
+func NewKeyringService(signingKeyRepo *repository.SigningKeyRepository, jwtManager *utils.JWTManager, secret string, logger *zap.Logger) (*KeyringService, error) {
+	if secret == "" {
+		return nil, errors.New("JWT_KEY_SECRET is required to store signing keys")
+	}
+	cipher, err := utils.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyringService{
+		signingKeyRepo: signingKeyRepo,
+		jwtManager:     jwtManager,
+		cipher:         cipher,
+		logger:         logger,
+	}, nil
+}
+
+// Run syncs the keyring every refresh interval until ctx is cancelled. A
+// rotation of 0 disables scheduled rotation.
+func (s *KeyringService) Run(ctx context.Context, rotation, refresh time.Duration) {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Sync(ctx, rotation, refresh); err != nil {
+			s.logger.Error("Failed to sync signing keys", zap.Error(err))
+		}
+	}
+}
+
+// Sync loads the keyring, creates a key when the newest one is due for
+// rotation, drops keys no token can still reference and installs the
+// result in the JWT manager.
+func (s *KeyringService) Sync(ctx context.Context, rotation, refresh time.Duration) error {
+	keys, err := s.signingKeyRepo.FindAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	now := time.Now()
+	if len(keys) == 0 || (rotation > 0 && now.Sub(keys[0].CreatedAt) >= rotation) {
+		generation := 1
+		if len(keys) > 0 {
+			generation = keys[0].Generation + 1
+		}
+		key, err := s.createKey(ctx, generation, now)
+		switch {
+		case err == nil:
+			keys = append([]*models.SigningKey{key}, keys...)
+			s.logger.Info("Created signing key", zap.String("kid", key.ID))
+		case mongo.IsDuplicateKeyError(err):
+			// Another replica rotated first, use its key instead
+			if keys, err = s.signingKeyRepo.FindAll(ctx); err != nil {
+				return fmt.Errorf("failed to load signing keys: %w", err)
+			}
+		default:
+			return err
+		}
+	}
+
+	// keys are newest first; a key is in use from its creation plus refresh,
+	// so everything older than a key in use for longer than the token TTL
+	// can no longer be referenced by a valid token
+	for i, key := range keys {
+		if key.CreatedAt.Add(refresh + utils.AccessTokenTTL).Before(now) {
+			if i+1 < len(keys) {
+				if _, err := s.signingKeyRepo.DeleteCreatedBefore(ctx, key.CreatedAt); err != nil {
+					return fmt.Errorf("failed to delete expired signing keys: %w", err)
+				}
+				keys = keys[:i+1]
+			}
+			break
+		}
+	}
+
+	ring := make([]*utils.SigningKey, 0, len(keys))
+	activeID := ""
+	for _, key := range keys {
+		signingKey, err := s.parseKey(key)
+		if err != nil {
+			s.logger.Error("Skipping invalid signing key", zap.String("kid", key.ID), zap.Error(err))
+			continue
+		}
+		ring = append(ring, signingKey)
+		if activeID == "" && !key.CreatedAt.Add(refresh).After(now) {
+			activeID = signingKey.ID
+		}
+	}
+	if len(ring) == 0 {
+		return errors.New("no valid signing key")
+	}
+	// No key has propagated yet, sign with the oldest one which other
+	// replicas most likely know already
+	if activeID == "" {
+		activeID = ring[len(ring)-1].ID
+	}
+
+	return s.jwtManager.SetKeys(ring, activeID)
+}
+
+func (s *KeyringService) createKey(ctx context.Context, generation int, now time.Time) (*models.SigningKey, error) {
+	signingKey, err := utils.GenerateSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	encoded, err := utils.EncodeSigningKey(signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	// The kid is authenticated so a key cannot be swapped for another one
+	encrypted, err := s.cipher.Seal(encoded, []byte(signingKey.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	key := &models.SigningKey{
+		ID:         signingKey.ID,
+		Generation: generation,
+		PrivateKey: encrypted,
+		Encrypted:  true,
+		CreatedAt:  now,
+	}
+	if err := s.signingKeyRepo.Create(ctx, key); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	return key, nil
+}
+
+// parseKey decrypts and decodes a stored key. Keys stored before encryption
+// was introduced are read as they are until they are rotated out.
+func (s *KeyringService) parseKey(key *models.SigningKey) (*utils.SigningKey, error) {
+	if !key.Encrypted {
+		return utils.ParseSigningKey([]byte(key.PrivateKey))
+	}
+
+	encoded, err := s.cipher.Open(key.PrivateKey, []byte(key.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt signing key: %w", err)
+	}
+	return utils.ParseSigningKey(encoded)
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+)
+
+const keyringRefresh = time.Minute
+
+// storedKey returns a new signing key and its document in the signing_keys
+// collection.
+func storedKey(t *testing.T, createdAt time.Time) (*utils.SigningKey, bson.D) {
+	t.Helper()
+	key, err := utils.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := utils.EncodeSigningKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, bson.D{
+		{Key: "_id", Value: key.ID},
+		{Key: "private_key", Value: string(encoded)},
+		{Key: "created_at", Value: createdAt},
+	}
+}
+
+// activeKeyID returns the kid of the tokens signed by m.
+func activeKeyID(t *testing.T, m *utils.JWTManager) string {
+	t.Helper()
+	token, _, err := m.GenerateToken(primitive.NilObjectID, "alice", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &utils.JWTClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Header["kid"].(string)
+}
+
+func keyIDs(m *utils.JWTManager) map[string]bool {
+	ids := make(map[string]bool)
+	for _, key := range m.JWKS().Keys {
+		ids[key.Kid] = true
+	}
+	return ids
+}
+
+func TestKeyringSync(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ok := bson.D{{Key: "ok", Value: 1}}
+
+	newService := func(mt *mtest.T) (*KeyringService, *utils.JWTManager) {
+		jwtManager, err := utils.NewJWTManager()
+		if err != nil {
+			t.Fatal(err)
+		}
+		service, err := NewKeyringService(repository.NewSigningKeyRepository(mt.DB), jwtManager, "keyring-secret", zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return service, jwtManager
+	}
+
+	mt.Run("creates the first key", func(mt *mtest.T) {
+		service, jwtManager := newService(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch), ok)
+
+		if err := service.Sync(context.Background(), 24*time.Hour, keyringRefresh); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		mt.GetStartedEvent()
+		insert := mt.GetStartedEvent()
+		if insert == nil || insert.CommandName != "insert" {
+			t.Fatal("no key was stored")
+		}
+		stored := insert.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if !stored.Lookup("encrypted").Boolean() || strings.Contains(stored.Lookup("private_key").StringValue(), "PRIVATE KEY") {
+			t.Error("private key was stored in plain text")
+		}
+		if generation := stored.Lookup("generation").AsInt64(); generation != 1 {
+			t.Errorf("generation = %d, want 1", generation)
+		}
+		// Without an older key the new key signs right away
+		if ids := keyIDs(jwtManager); len(ids) != 1 || !ids[activeKeyID(t, jwtManager)] {
+			t.Errorf("keyring = %v", ids)
+		}
+	})
+
+	mt.Run("rotates without signing with an unpropagated key", func(mt *mtest.T) {
+		service, jwtManager := newService(mt)
+		current, doc := storedKey(t, time.Now().Add(-25*time.Hour))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch, doc), ok)
+
+		if err := service.Sync(context.Background(), 24*time.Hour, keyringRefresh); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		if ids := keyIDs(jwtManager); len(ids) != 2 || !ids[current.ID] {
+			t.Errorf("keyring = %v, want the current and a new key", ids)
+		}
+		if kid := activeKeyID(t, jwtManager); kid != current.ID {
+			t.Errorf("signing with %s, want the current key until the new one propagates", kid)
+		}
+	})
+
+	mt.Run("signs with the newest propagated key", func(mt *mtest.T) {
+		service, jwtManager := newService(mt)
+		newest, newestDoc := storedKey(t, time.Now().Add(-2*keyringRefresh))
+		_, olderDoc := storedKey(t, time.Now().Add(-time.Hour))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch, newestDoc, olderDoc))
+
+		if err := service.Sync(context.Background(), 24*time.Hour, keyringRefresh); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		if kid := activeKeyID(t, jwtManager); kid != newest.ID {
+			t.Errorf("signing with %s, want %s", kid, newest.ID)
+		}
+	})
+
+	mt.Run("prunes keys no token can reference", func(mt *mtest.T) {
+		service, jwtManager := newService(mt)
+		current, currentDoc := storedKey(t, time.Now().Add(-utils.AccessTokenTTL-2*keyringRefresh))
+		_, expiredDoc := storedKey(t, time.Now().Add(-3*utils.AccessTokenTTL))
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch, currentDoc, expiredDoc),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		)
+
+		// Rotation is disabled, so the old key stays active
+		if err := service.Sync(context.Background(), 0, keyringRefresh); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		mt.GetStartedEvent()
+		if deleteEvent := mt.GetStartedEvent(); deleteEvent == nil || deleteEvent.CommandName != "delete" {
+			t.Fatal("expired key was not deleted")
+		}
+		if ids := keyIDs(jwtManager); len(ids) != 1 || !ids[current.ID] {
+			t.Errorf("keyring = %v, want only the current key", ids)
+		}
+	})
+
+	mt.Run("reads encrypted keys", func(mt *mtest.T) {
+		service, jwtManager := newService(mt)
+		key, doc := storedKey(t, time.Now().Add(-time.Hour))
+		encoded, err := utils.EncodeSigningKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := service.cipher.Seal(encoded, []byte(key.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc = bson.D{
+			{Key: "_id", Value: key.ID},
+			{Key: "generation", Value: 1},
+			{Key: "private_key", Value: encrypted},
+			{Key: "encrypted", Value: true},
+			{Key: "created_at", Value: time.Now().Add(-time.Hour)},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch, doc))
+
+		if err := service.Sync(context.Background(), 24*time.Hour, keyringRefresh); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		if kid := activeKeyID(t, jwtManager); kid != key.ID {
+			t.Errorf("signing with %s, want %s", kid, key.ID)
+		}
+	})
+
+	mt.Run("uses the key of a replica that rotated first", func(mt *mtest.T) {
+		service, jwtManager := newService(mt)
+		other, otherDoc := storedKey(t, time.Now())
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+			mtest.CreateCursorResponse(0, "db.signing_keys", mtest.FirstBatch, otherDoc),
+		)
+
+		if err := service.Sync(context.Background(), 24*time.Hour, keyringRefresh); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		if ids := keyIDs(jwtManager); len(ids) != 1 || !ids[other.ID] {
+			t.Errorf("keyring = %v, want only the key of the other replica", ids)
+		}
+	})
+}
+
+func TestNewKeyringServiceRequiresSecret(t *testing.T) {
+	if _, err := NewKeyringService(nil, nil, "", zap.NewNop()); err == nil {
+		t.Error("keyring was created without JWT_KEY_SECRET")
+	}
+}
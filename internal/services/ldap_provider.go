@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/ldap"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNoDirectoryRole is returned when a directory user is in none of the
+// groups allowed to log in. It wraps ErrInvalidCredentials, so the login
+// fails and is counted like one with a wrong password.
+var ErrNoDirectoryRole = fmt.Errorf("%w: account is not in an authorized directory group", ErrInvalidCredentials)
+
+// LDAPConn is the directory connection used by LDAPProvider. *ldap.Conn
+// implements it; tests can substitute a fake directory.
+type LDAPConn interface {
+	Bind(dn, password string) error
+	Search(req *ldap.SearchRequest) ([]*ldap.Entry, error)
+	Close() error
+}
+
+// LDAPDialer opens a new directory connection.
+type LDAPDialer func(ctx context.Context) (LDAPConn, error)
+
+// DialLDAP returns a dialer for the configured LDAP server.
+func DialLDAP(config *config.Config) LDAPDialer {
+	return func(ctx context.Context) (LDAPConn, error) {
+		conn, err := ldap.Dial(ctx, config.LDAPURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if config.LDAPStartTLS {
+			if err := conn.StartTLS(nil); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return conn, nil
+	}
+}
+
+// LDAPProvider authenticates users with a bind against an LDAP or Active
+// Directory server. The user entry is looked up with the service account,
+// then bound with the given password. Users are created on their first
+// login and their role follows their directory groups on every login.
+type LDAPProvider struct {
+	userRepo *repository.UserRepository
+	config   *config.Config
+	dial     LDAPDialer
+}
+
+func NewLDAPProvider(userRepo *repository.UserRepository, config *config.Config, dial LDAPDialer) (*LDAPProvider, error) {
+	if config.LDAPURL == "" || config.LDAPBaseDN == "" {
+		return nil, errors.New("LDAP_URL and LDAP_BASE_DN are required for LDAP authentication")
+	}
+	if !strings.Contains(config.LDAPUserFilter, "%s") {
+		return nil, errors.New("LDAP_USER_FILTER must contain %s for the username")
+	}
+
+	return &LDAPProvider{
+		userRepo: userRepo,
+		config:   config,
+		dial:     dial,
+	}, nil
+}
+
+func (p *LDAPProvider) Name() string {
+	return "ldap"
+}
+
+func (p *LDAPProvider) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	if p.config.LDAPBindDN != "" {
+		if err := conn.Bind(p.config.LDAPBindDN, p.config.LDAPBindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind LDAP service account: %w", err)
+		}
+	}
+
+	entries, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     p.config.LDAPBaseDN,
+		Filter:     fmt.Sprintf(p.config.LDAPUserFilter, ldap.EscapeFilter(username)),
+		Attributes: []string{p.config.LDAPGroupAttribute, p.config.LDAPEmailAttribute, p.config.LDAPNameAttribute},
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search LDAP user: %w", err)
+	}
+	// An ambiguous filter must not let one user log in as another
+	if len(entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsInvalidCredentials(err) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind LDAP user: %w", err)
+	}
+
+	role := p.role(entry.Values(p.config.LDAPGroupAttribute))
+	if role == "" {
+		return nil, ErrNoDirectoryRole
+	}
+
+	return p.syncUser(ctx, username, role, entry)
+}
+
+// role maps the groups of a directory user to a role, or returns an empty
+// role when the user may not log in.
+func (p *LDAPProvider) role(groups []string) models.UserRole {
+	if p.config.LDAPAdminGroup != "" && containsDN(groups, p.config.LDAPAdminGroup) {
+		return models.RoleAdmin
+	}
+	if p.config.LDAPMemberGroup == "" || containsDN(groups, p.config.LDAPMemberGroup) {
+		return models.RoleMember
+	}
+	return ""
+}
+
+// syncUser creates the user of a directory entry, or updates its role and
+// profile from the directory.
+func (p *LDAPProvider) syncUser(ctx context.Context, username string, role models.UserRole, entry *ldap.Entry) (*models.User, error) {
+	displayName := entry.Value(p.config.LDAPNameAttribute)
+	email := strings.ToLower(entry.Value(p.config.LDAPEmailAttribute))
+	if email != "" {
+		existing, err := p.userRepo.FindByEmail(ctx, email)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to check email: %w", err)
+		}
+		// Emails are unique; keep a conflicting one out rather than fail
+		if existing != nil && existing.Username != username {
+			email = ""
+		}
+	}
+
+	user, err := p.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		user = &models.User{
+			Username:    username,
+			DisplayName: displayName,
+			Email:       email,
+			Role:        role,
+			AuthSource:  models.AuthSourceLDAP,
+		}
+		if err := p.userRepo.Create(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		return user, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	// A local account with the same name is not taken over by the directory
+	if user.AuthSource != models.AuthSourceLDAP {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Role != role {
+		if err := p.userRepo.UpdateAccess(ctx, user.ID, &role, nil); err != nil {
+			return nil, fmt.Errorf("failed to update user role: %w", err)
+		}
+		user.Role = role
+	}
+	if user.DisplayName != displayName || user.Email != email {
+		if err := p.userRepo.UpdateProfile(ctx, user.ID, &displayName, &email); err != nil {
+			return nil, fmt.Errorf("failed to update user profile: %w", err)
+		}
+		user.DisplayName = displayName
+		user.Email = email
+	}
+	return user, nil
+}
+
+// containsDN reports whether dns holds dn, ignoring case and spaces around
+// RDN separators.
+func containsDN(dns []string, dn string) bool {
+	want := normalizeDN(dn)
+	for _, candidate := range dns {
+		if normalizeDN(candidate) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDN(dn string) string {
+	parts := strings.Split(strings.ToLower(dn), ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/ldap"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// fakeDirectory is an LDAPConn serving fixed entries. Binding succeeds for
+// the DNs in passwords with the matching password.
+type fakeDirectory struct {
+	entries   []*ldap.Entry
+	passwords map[string]string
+	searchErr error
+	filter    string
+	closed    bool
+}
+
+func (d *fakeDirectory) Bind(dn, password string) error {
+	if expected, ok := d.passwords[dn]; ok && expected == password {
+		return nil
+	}
+	return &ldap.Error{ResultCode: ldap.ResultInvalidCredentials}
+}
+
+func (d *fakeDirectory) Search(req *ldap.SearchRequest) ([]*ldap.Entry, error) {
+	d.filter = req.Filter
+	return d.entries, d.searchErr
+}
+
+func (d *fakeDirectory) Close() error {
+	d.closed = true
+	return nil
+}
+
+func newTestLDAPProvider(t *testing.T, directory *fakeDirectory) *LDAPProvider {
+	t.Helper()
+	cfg := &config.Config{
+		LDAPURL:            "ldap://directory.test",
+		LDAPBaseDN:         "dc=example",
+		LDAPBindDN:         "cn=service,dc=example",
+		LDAPBindPassword:   "service-secret",
+		LDAPUserFilter:     "(uid=%s)",
+		LDAPGroupAttribute: "memberOf",
+		LDAPAdminGroup:     "cn=Admins,dc=example",
+		LDAPMemberGroup:    "cn=Users,dc=example",
+	}
+	provider, err := NewLDAPProvider(nil, cfg, func(ctx context.Context) (LDAPConn, error) {
+		return directory, nil
+	})
+	if err != nil {
+		t.Fatalf("NewLDAPProvider: %v", err)
+	}
+	return provider
+}
+
+func aliceEntry(groups ...string) *ldap.Entry {
+	return &ldap.Entry{
+		DN:         "uid=alice,dc=example",
+		Attributes: map[string][]string{"memberOf": groups},
+	}
+}
+
+func TestLDAPProviderRejectsBeforeSync(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		entries  []*ldap.Entry
+	}{
+		{"unknown user", "secret", nil},
+		{"ambiguous filter", "secret", []*ldap.Entry{aliceEntry(), aliceEntry()}},
+		{"wrong password", "wrong", []*ldap.Entry{aliceEntry("cn=Users,dc=example")}},
+		{"no directory role", "secret", []*ldap.Entry{aliceEntry("cn=Others,dc=example")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := &fakeDirectory{
+				entries: tt.entries,
+				passwords: map[string]string{
+					"cn=service,dc=example": "service-secret",
+					"uid=alice,dc=example":  "secret",
+				},
+			}
+			provider := newTestLDAPProvider(t, directory)
+
+			_, err := provider.Authenticate(context.Background(), "Alice", tt.password)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Authenticate = %v, want invalid credentials", err)
+			}
+			if !directory.closed {
+				t.Error("connection was not closed")
+			}
+		})
+	}
+}
+
+func TestLDAPProviderEscapesUsername(t *testing.T) {
+	directory := &fakeDirectory{passwords: map[string]string{"cn=service,dc=example": "service-secret"}}
+	provider := newTestLDAPProvider(t, directory)
+
+	if _, err := provider.Authenticate(context.Background(), "*)(uid=*", "secret"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate = %v, want invalid credentials", err)
+	}
+	if directory.filter != `(uid=\2a\29\28uid=\2a)` {
+		t.Errorf("filter = %q", directory.filter)
+	}
+}
+
+func TestLDAPProviderDirectoryErrors(t *testing.T) {
+	directory := &fakeDirectory{
+		passwords: map[string]string{"cn=service,dc=example": "service-secret"},
+		searchErr: errors.New("connection reset"),
+	}
+	provider := newTestLDAPProvider(t, directory)
+
+	_, err := provider.Authenticate(context.Background(), "alice", "secret")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate = %v, want a directory error", err)
+	}
+
+	directory = &fakeDirectory{}
+	provider = newTestLDAPProvider(t, directory)
+	_, err = provider.Authenticate(context.Background(), "alice", "secret")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate with a bad service account = %v, want a directory error", err)
+	}
+}
+
+func TestLDAPProviderRole(t *testing.T) {
+	provider := newTestLDAPProvider(t, &fakeDirectory{})
+
+	tests := []struct {
+		groups []string
+		want   models.UserRole
+	}{
+		{[]string{"CN=Admins, DC=example"}, models.RoleAdmin},
+		{[]string{"cn=users,dc=example", "cn=admins,dc=example"}, models.RoleAdmin},
+		{[]string{"cn=users,dc=example"}, models.RoleMember},
+		{[]string{"cn=others,dc=example"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := provider.role(tt.groups); got != tt.want {
+			t.Errorf("role(%v) = %q, want %q", tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestLDAPProviderSyncUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newProvider := func(mt *mtest.T) *LDAPProvider {
+		provider := newTestLDAPProvider(t, &fakeDirectory{})
+		provider.userRepo = repository.NewUserRepository(mt.DB)
+		provider.config.LDAPEmailAttribute = "mail"
+		provider.config.LDAPNameAttribute = "cn"
+		return provider
+	}
+	entry := &ldap.Entry{
+		DN: "uid=alice,dc=example",
+		Attributes: map[string][]string{
+			"mail": {"Alice@Example.com"},
+			"cn":   {"Alice Liddell"},
+		},
+	}
+	noUser := func() bson.D {
+		return mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch)
+	}
+	user := func(fields ...bson.E) bson.D {
+		doc := bson.D{{Key: "_id", Value: primitive.NewObjectID()}}
+		return mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, append(doc, fields...))
+	}
+	written := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}
+	commands := func(mt *mtest.T) []string {
+		var names []string
+		for _, event := range mt.GetAllStartedEvents() {
+			names = append(names, event.CommandName)
+		}
+		return names
+	}
+
+	mt.Run("creates the user on first login", func(mt *mtest.T) {
+		provider := newProvider(mt)
+		mt.AddMockResponses(noUser(), noUser(), written)
+
+		got, err := provider.syncUser(context.Background(), "alice", models.RoleAdmin, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != models.RoleAdmin || got.AuthSource != models.AuthSourceLDAP || got.Email != "alice@example.com" || got.DisplayName != "Alice Liddell" {
+			t.Errorf("created user = %+v", got)
+		}
+		if names := commands(mt); len(names) != 3 || names[2] != "insert" {
+			t.Errorf("commands = %v, want the user inserted", names)
+		}
+	})
+
+	mt.Run("re-syncs the role on every login", func(mt *mtest.T) {
+		provider := newProvider(mt)
+		alice := []bson.E{
+			{Key: "username", Value: "alice"},
+			{Key: "email", Value: "alice@example.com"},
+			{Key: "display_name", Value: "Alice Liddell"},
+			{Key: "auth_source", Value: "ldap"},
+		}
+		mt.AddMockResponses(user(alice...), user(append(alice, bson.E{Key: "role", Value: "admin"})...), written)
+
+		got, err := provider.syncUser(context.Background(), "alice", models.RoleMember, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != models.RoleMember {
+			t.Errorf("role = %q, want member", got.Role)
+		}
+		events := mt.GetAllStartedEvents()
+		if len(events) != 3 || events[2].CommandName != "update" {
+			t.Fatalf("commands = %v, want only the role updated", commands(mt))
+		}
+		if role, err := events[2].Command.LookupErr("updates", "0", "u", "$set", "role"); err != nil || role.StringValue() != "member" {
+			t.Errorf("role update = %v, %v", role, err)
+		}
+	})
+
+	mt.Run("does not take over a local account", func(mt *mtest.T) {
+		provider := newProvider(mt)
+		mt.AddMockResponses(noUser(), user(bson.E{Key: "username", Value: "alice"}, bson.E{Key: "role", Value: "admin"}))
+
+		if _, err := provider.syncUser(context.Background(), "alice", models.RoleMember, entry); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("syncUser = %v, want invalid credentials", err)
+		}
+		if names := commands(mt); len(names) != 2 {
+			t.Errorf("commands = %v, want no writes", names)
+		}
+	})
+
+	mt.Run("leaves out an email used by another account", func(mt *mtest.T) {
+		provider := newProvider(mt)
+		mt.AddMockResponses(user(bson.E{Key: "username", Value: "bob"}, bson.E{Key: "email", Value: "alice@example.com"}), noUser(), written)
+
+		got, err := provider.syncUser(context.Background(), "alice", models.RoleMember, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != "" {
+			t.Errorf("email = %q, want it left out", got.Email)
+		}
+		events := mt.GetAllStartedEvents()
+		if len(events) != 3 {
+			t.Fatalf("commands = %v", commands(mt))
+		}
+		if _, err := events[2].Command.LookupErr("documents", "0", "email"); err == nil {
+			t.Error("the conflicting email was stored")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginLockedError is returned while an account or IP address is locked
+// out after too many failed logins.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginThrottle tracks failed logins per account and per IP address. Once
+// the failures of either reach its limit, logins are locked out for a period
+// that doubles with every further failure.
+type LoginThrottle struct {
+	loginAttemptRepo *repository.LoginAttemptRepository
+	auditRepo        *repository.AuditRepository
+	config           *config.Config
+}
+
+func NewLoginThrottle(loginAttemptRepo *repository.LoginAttemptRepository, auditRepo *repository.AuditRepository, config *config.Config) *LoginThrottle {
+	return &LoginThrottle{
+		loginAttemptRepo: loginAttemptRepo,
+		auditRepo:        auditRepo,
+		config:           config,
+	}
+}
+
+// Check returns a *LoginLockedError when the account or the IP address is
+// locked out.
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) error {
+	attempts, err := t.loginAttemptRepo.FindByKeys(ctx, []string{accountKey(username), ipKey(ip)})
+	if err != nil {
+		return fmt.Errorf("failed to check login attempts: %w", err)
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, attempt := range attempts {
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			retryAfter = max(retryAfter, attempt.LockedUntil.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Failure records a failed login and locks the account or IP address out
+// when it reached its limit. userID is zero for unknown usernames.
+func (t *LoginThrottle) Failure(ctx context.Context, username, ip string, userID primitive.ObjectID) error {
+	limits := []struct {
+		key   string
+		limit int
+	}{
+		{accountKey(username), t.config.LoginMaxAttempts},
+		{ipKey(ip), t.config.LoginMaxAttemptsPerIP},
+	}
+
+	for _, l := range limits {
+		if l.limit <= 0 {
+			continue
+		}
+
+		attempts, err := t.loginAttemptRepo.RecordFailure(ctx, l.key, t.config.LoginFailureReset)
+		if err != nil {
+			return fmt.Errorf("failed to record login attempt: %w", err)
+		}
+		if attempts.Failures < l.limit {
+			continue
+		}
+
+		lockout := t.lockoutDuration(attempts.Failures - l.limit)
+		until := time.Now().Add(lockout)
+		if err := t.loginAttemptRepo.Lock(ctx, l.key, until); err != nil {
+			return fmt.Errorf("failed to lock login: %w", err)
+		}
+
+		entry := &models.AuditEntry{
+			Action:   models.AuditLoginLockout,
+			UserID:   userID,
+			Username: username,
+			IP:       ip,
+			Message:  fmt.Sprintf("%s locked out for %s after %d failed logins", l.key, lockout, attempts.Failures),
+		}
+		if err := t.auditRepo.Create(ctx, entry); err != nil {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Success clears the failures of the account. Failures of the IP address
+// are kept, so one valid account cannot be used to reset them.
+func (t *LoginThrottle) Success(ctx context.Context, username string) error {
+	return t.loginAttemptRepo.Reset(ctx, accountKey(username))
+}
+
+// lockoutDuration doubles the base lockout for every failure past the limit,
+// up to the configured maximum.
+func (t *LoginThrottle) lockoutDuration(extraFailures int) time.Duration {
+	lockout := t.config.LoginLockoutBase
+	for i := 0; i < extraFailures && lockout < t.config.LoginLockoutMax; i++ {
+		lockout *= 2
+	}
+	return min(lockout, t.config.LoginLockoutMax)
+}
+
+func accountKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	throttle := &LoginThrottle{config: &config.Config{LoginLockoutBase: time.Minute, LoginLockoutMax: 5 * time.Minute}}
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for extra, w := range want {
+		if got := throttle.lockoutDuration(extra); got != w {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", extra, got, w)
+		}
+	}
+}
+
+func TestLoginThrottleKeys(t *testing.T) {
+	if got := accountKey("Alice"); got != "user:alice" {
+		t.Errorf("accountKey = %q", got)
+	}
+	if got := ipKey("10.0.0.1"); got != "ip:10.0.0.1" {
+		t.Errorf("ipKey = %q", got)
+	}
+}
+
+func TestLoginThrottleCheck(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("locked", func(mt *mtest.T) {
+		throttle := NewLoginThrottle(repository.NewLoginAttemptRepository(mt.DB), nil, &config.Config{})
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.login_attempts", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "user:alice"}, {Key: "failures", Value: 5}, {Key: "locked_until", Value: time.Now().Add(time.Minute)}},
+			bson.D{{Key: "_id", Value: "ip:10.0.0.1"}, {Key: "failures", Value: 20}, {Key: "locked_until", Value: time.Now().Add(time.Hour)}},
+		))
+
+		var locked *LoginLockedError
+		if err := throttle.Check(context.Background(), "Alice", "10.0.0.1"); !errors.As(err, &locked) {
+			t.Fatalf("Check = %v, want LoginLockedError", err)
+		}
+		if locked.RetryAfter < 59*time.Minute {
+			t.Errorf("retry after %v, want the longest lockout", locked.RetryAfter)
+		}
+	})
+
+	mt.Run("expired lock", func(mt *mtest.T) {
+		throttle := NewLoginThrottle(repository.NewLoginAttemptRepository(mt.DB), nil, &config.Config{})
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.login_attempts", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "user:alice"}, {Key: "failures", Value: 5}, {Key: "locked_until", Value: time.Now().Add(-time.Minute)}},
+		))
+
+		if err := throttle.Check(context.Background(), "alice", "10.0.0.1"); err != nil {
+			t.Errorf("Check = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationService struct {
+	notificationRepo *repository.NotificationRepository
+}
+
+func NewNotificationService(notificationRepo *repository.NotificationRepository) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+	}
+}
+
+func (s *NotificationService) Notify(ctx context.Context, notification *models.Notification) error {
+	if err := s.notificationRepo.Create(ctx, notification); err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+	return nil
+}
+
+func (s *NotificationService) GetNotifications(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]*models.Notification, error) {
+	notifications, err := s.notificationRepo.FindByUserID(ctx, userID, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userID, notificationID primitive.ObjectID) error {
+	if err := s.notificationRepo.MarkRead(ctx, notificationID, userID); err != nil {
+		return fmt.Errorf("failed to mark notification as read: %w", err)
+	}
+	return nil
+}
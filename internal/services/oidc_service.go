@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/oidc"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrOIDCDisabled is returned when no identity provider is configured.
+	ErrOIDCDisabled = errors.New("single sign-on is not configured")
+	// ErrInvalidOIDCState is returned when a callback state is unknown,
+	// expired or already used.
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrNoOIDCRole is returned when the identity provider does not grant
+	// any role allowed to log in.
+	ErrNoOIDCRole = errors.New("account is not authorized by the identity provider")
+)
+
+// oidcStateTTL is how long users have to log in at the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCService signs users in through an OpenID Connect provider with the
+// authorization code flow and PKCE.
+type OIDCService struct {
+	userRepo      *repository.UserRepository
+	oidcStateRepo *repository.OIDCStateRepository
+	client        *oidc.Client
+	config        *config.Config
+}
+
+// NewOIDCService creates the service. Single sign-on is disabled when
+// OIDC_ISSUER is empty.
+func NewOIDCService(userRepo *repository.UserRepository, oidcStateRepo *repository.OIDCStateRepository, config *config.Config) (*OIDCService, error) {
+	service := &OIDCService{
+		userRepo:      userRepo,
+		oidcStateRepo: oidcStateRepo,
+		config:        config,
+	}
+	if config.OIDCIssuer != "" {
+		if config.OIDCClientID == "" || config.OIDCRedirectURL == "" {
+			return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required for single sign-on")
+		}
+		service.client = oidc.NewClient(oidc.Config{
+			Issuer:       config.OIDCIssuer,
+			ClientID:     config.OIDCClientID,
+			ClientSecret: config.OIDCClientSecret,
+			RedirectURL:  config.OIDCRedirectURL,
+			Scopes:       strings.Fields(config.OIDCScopes),
+		}, nil)
+	}
+	return service, nil
+}
+
+// StartLogin returns the URL of the identity provider to send the user to,
+// and the state the callback has to present.
+func (s *OIDCService) StartLogin(ctx context.Context) (string, string, error) {
+	if s.client == nil {
+		return "", "", ErrOIDCDisabled
+	}
+
+	state, err := oidc.GenerateState()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.GenerateState()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authorizationURL, err := s.client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.oidcStateRepo.Create(ctx, &models.OIDCState{
+		StateHash: hashToken(state),
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	return authorizationURL, state, nil
+}
+
+// CompleteLogin redeems the code of a callback and returns the matching
+// user, creating or linking it on the first login.
+func (s *OIDCService) CompleteLogin(ctx context.Context, req *models.OIDCCallbackRequest) (*models.User, error) {
+	if s.client == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	state, err := s.oidcStateRepo.Consume(ctx, hashToken(req.State))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find login state: %w", err)
+	}
+
+	claims, err := s.client.Exchange(ctx, req.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.syncUser(ctx, claims)
+}
+
+// role maps the role claim to a role, or returns an empty role when the
+// user may not log in.
+func (s *OIDCService) role(claims oidc.Claims) models.UserRole {
+	values := claims.Strings(s.config.OIDCRoleClaim)
+	if s.config.OIDCAdminValue != "" && containsString(values, s.config.OIDCAdminValue) {
+		return models.RoleAdmin
+	}
+	if s.config.OIDCMemberValue == "" || containsString(values, s.config.OIDCMemberValue) {
+		return models.RoleMember
+	}
+	return ""
+}
+
+// syncUser finds the user linked to the identity provider account, links
+// an existing user with the same verified email, or creates one. Users
+// created here follow the role claim on every login; linked users keep the
+// role managed in this application.
+func (s *OIDCService) syncUser(ctx context.Context, claims oidc.Claims) (*models.User, error) {
+	issuer := claims.String("iss")
+	subject := claims.String("sub")
+	role := s.role(claims)
+	if role == "" {
+		return nil, ErrNoOIDCRole
+	}
+
+	displayName := claims.String("name")
+	email := ""
+	if claims.Bool("email_verified") {
+		email = strings.ToLower(claims.String("email"))
+	}
+
+	user, err := s.userRepo.FindByOIDCSubject(ctx, issuer, subject)
+	if err == nil {
+		if user.AuthSource == models.AuthSourceOIDC && user.Role != role {
+			if err := s.userRepo.UpdateAccess(ctx, user.ID, &role, nil); err != nil {
+				return nil, fmt.Errorf("failed to update user role: %w", err)
+			}
+			user.Role = role
+		}
+		return user, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if email != "" {
+		existing, err := s.userRepo.FindByEmail(ctx, email)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to check email: %w", err)
+		}
+		if existing != nil {
+			if !s.config.OIDCLinkByEmail || existing.ServiceAccount || existing.OIDCSubject != "" {
+				return nil, errors.New("email belongs to another account")
+			}
+			if err := s.userRepo.LinkOIDC(ctx, existing.ID, issuer, subject); err != nil {
+				return nil, fmt.Errorf("failed to link user: %w", err)
+			}
+			existing.OIDCIssuer = issuer
+			existing.OIDCSubject = subject
+			return existing, nil
+		}
+	}
+
+	username, err := s.username(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	user = &models.User{
+		Username:    username,
+		DisplayName: displayName,
+		Email:       email,
+		Role:        role,
+		AuthSource:  models.AuthSourceOIDC,
+		OIDCIssuer:  issuer,
+		OIDCSubject: subject,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// username picks the username of a new user from the preferred username,
+// the email or the subject. A taken name gets a suffix derived from the
+// subject.
+func (s *OIDCService) username(ctx context.Context, claims oidc.Claims) (string, error) {
+	username := claims.String("preferred_username")
+	if username == "" {
+		username = claims.String("email")
+	}
+	if username == "" {
+		username = claims.String("sub")
+	}
+	username = strings.ToLower(username)
+
+	_, err := s.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return username, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check username: %w", err)
+	}
+	return username + "-" + hashToken(claims.String("iss") + claims.String("sub"))[:6], nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/oidc"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestOIDCServiceRole(t *testing.T) {
+	service := &OIDCService{config: &config.Config{
+		OIDCRoleClaim:   "groups",
+		OIDCAdminValue:  "admins",
+		OIDCMemberValue: "users",
+	}}
+
+	tests := []struct {
+		claim any
+		want  models.UserRole
+	}{
+		{[]any{"users", "admins"}, models.RoleAdmin},
+		{"admins", models.RoleAdmin},
+		{[]any{"users"}, models.RoleMember},
+		{[]any{"others"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := service.role(oidc.Claims{"groups": tt.claim}); got != tt.want {
+			t.Errorf("role(%v) = %q, want %q", tt.claim, got, tt.want)
+		}
+	}
+
+	// Without a member value every account of the provider may log in
+	service.config.OIDCMemberValue = ""
+	if got := service.role(oidc.Claims{}); got != models.RoleMember {
+		t.Errorf("role without a member value = %q, want member", got)
+	}
+}
+
+func TestOIDCServiceSyncUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newService := func(mt *mtest.T, linkByEmail bool) *OIDCService {
+		return &OIDCService{
+			userRepo: repository.NewUserRepository(mt.DB),
+			config: &config.Config{
+				OIDCRoleClaim:   "groups",
+				OIDCAdminValue:  "admins",
+				OIDCLinkByEmail: linkByEmail,
+			},
+		}
+	}
+	claims := func(extra oidc.Claims) oidc.Claims {
+		c := oidc.Claims{
+			"iss":                "https://idp.test",
+			"sub":                "subject-1",
+			"preferred_username": "Alice",
+			"email":              "Alice@Example.com",
+			"email_verified":     true,
+		}
+		for name, value := range extra {
+			c[name] = value
+		}
+		return c
+	}
+	noUser := func() bson.D {
+		return mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch)
+	}
+	user := func(fields ...bson.E) bson.D {
+		doc := bson.D{{Key: "_id", Value: primitive.NewObjectID()}}
+		return mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, append(doc, fields...))
+	}
+	written := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}
+	commands := func(mt *mtest.T) []string {
+		var names []string
+		for _, event := range mt.GetAllStartedEvents() {
+			names = append(names, event.CommandName)
+		}
+		return names
+	}
+
+	mt.Run("links an account by email when enabled", func(mt *mtest.T) {
+		service := newService(mt, true)
+		mt.AddMockResponses(noUser(), user(bson.E{Key: "username", Value: "alice"}, bson.E{Key: "role", Value: "admin"}), written)
+
+		got, err := service.syncUser(context.Background(), claims(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Username != "alice" || got.OIDCSubject != "subject-1" || got.Role != models.RoleAdmin {
+			t.Errorf("linked user = %+v", got)
+		}
+		if names := commands(mt); len(names) != 3 || names[2] != "update" {
+			t.Errorf("commands = %v, want the account linked", names)
+		}
+	})
+
+	mt.Run("refuses to link by email when disabled", func(mt *mtest.T) {
+		service := newService(mt, false)
+		mt.AddMockResponses(noUser(), user(bson.E{Key: "username", Value: "alice"}))
+
+		if _, err := service.syncUser(context.Background(), claims(nil)); err == nil {
+			t.Fatal("syncUser linked an account by email")
+		}
+		if names := commands(mt); len(names) != 2 {
+			t.Errorf("commands = %v, want no writes", names)
+		}
+	})
+
+	mt.Run("refuses to link a service account", func(mt *mtest.T) {
+		service := newService(mt, true)
+		mt.AddMockResponses(noUser(), user(bson.E{Key: "username", Value: "deploy"}, bson.E{Key: "service_account", Value: true}))
+
+		if _, err := service.syncUser(context.Background(), claims(nil)); err == nil {
+			t.Fatal("syncUser linked a service account")
+		}
+		if names := commands(mt); len(names) != 2 {
+			t.Errorf("commands = %v, want no writes", names)
+		}
+	})
+
+	mt.Run("suffixes a taken username", func(mt *mtest.T) {
+		service := newService(mt, false)
+		// An unverified email is neither linked nor stored
+		mt.AddMockResponses(noUser(), user(bson.E{Key: "username", Value: "alice"}), written)
+
+		got, err := service.syncUser(context.Background(), claims(oidc.Claims{"email_verified": false}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "alice-" + hashToken("https://idp.testsubject-1")[:6]
+		if got.Username != want || got.Email != "" || got.AuthSource != models.AuthSourceOIDC {
+			t.Errorf("created user = %+v, want username %q and no email", got, want)
+		}
+		if names := commands(mt); len(names) != 3 || names[2] != "insert" {
+			t.Errorf("commands = %v, want the user inserted", names)
+		}
+	})
+
+	mt.Run("follows the role claim for users it created", func(mt *mtest.T) {
+		service := newService(mt, false)
+		mt.AddMockResponses(user(
+			bson.E{Key: "username", Value: "alice"},
+			bson.E{Key: "role", Value: "member"},
+			bson.E{Key: "auth_source", Value: "oidc"},
+		), written)
+
+		got, err := service.syncUser(context.Background(), claims(oidc.Claims{"groups": []any{"admins"}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != models.RoleAdmin {
+			t.Errorf("role = %q, want admin", got.Role)
+		}
+		if names := commands(mt); len(names) != 2 || names[1] != "update" {
+			t.Errorf("commands = %v, want the role updated", names)
+		}
+	})
+
+	mt.Run("keeps the role of linked users", func(mt *mtest.T) {
+		service := newService(mt, false)
+		mt.AddMockResponses(user(bson.E{Key: "username", Value: "alice"}, bson.E{Key: "role", Value: "member"}))
+
+		got, err := service.syncUser(context.Background(), claims(oidc.Claims{"groups": []any{"admins"}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != models.RoleMember {
+			t.Errorf("role = %q, want member", got.Role)
+		}
+		if names := commands(mt); len(names) != 1 {
+			t.Errorf("commands = %v, want no writes", names)
+		}
+	})
+
+	mt.Run("refuses accounts without a role", func(mt *mtest.T) {
+		service := newService(mt, false)
+		service.config.OIDCMemberValue = "users"
+
+		if _, err := service.syncUser(context.Background(), claims(nil)); !errors.Is(err, ErrNoOIDCRole) {
+			t.Fatalf("syncUser = %v, want ErrNoOIDCRole", err)
+		}
+	})
+}
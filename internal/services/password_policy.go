@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords is always denied, in addition to the configured denylist.
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1",
+	"password123", "qwerty", "qwerty123", "qwertyuiop", "abc123", "111111",
+	"123123", "000000", "iloveyou", "admin", "admin123", "welcome",
+	"welcome1", "letmein", "monkey", "dragon", "football", "baseball",
+	"sunshine", "princess", "passw0rd", "p@ssw0rd", "changeme", "root123",
+}
+
+// PasswordPolicy validates new passwords.
+type PasswordPolicy struct {
+	minLength  int
+	minClasses int
+	denylist   map[string]struct{}
+}
+
+// NewPasswordPolicy creates a policy requiring minLength characters from at
+// least minClasses of lowercase, uppercase, digits and symbols. denylistFile
+// optionally names a file of denied passwords, one per line.
+func NewPasswordPolicy(minLength, minClasses int, denylistFile string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		minLength:  minLength,
+		minClasses: minClasses,
+		denylist:   make(map[string]struct{}, len(commonPasswords)),
+	}
+	for _, password := range commonPasswords {
+		policy.denylist[password] = struct{}{}
+	}
+
+	if denylistFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(denylistFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password denylist: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			policy.denylist[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read password denylist: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Validate checks a new password of the given user against the policy.
+func (p *PasswordPolicy) Validate(password, username string) error {
+	if len([]rune(password)) < p.minLength {
+		return fmt.Errorf("password must be at least %d characters", p.minLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < p.minClasses {
+		return fmt.Errorf("password must mix at least %d of lowercase, uppercase, digits and symbols", p.minClasses)
+	}
+
+	normalized := strings.ToLower(password)
+	if _, denied := p.denylist[normalized]; denied {
+		return errors.New("password is too common")
+	}
+	// Very short usernames would match too many passwords
+	if len(username) >= 3 && strings.Contains(normalized, strings.ToLower(username)) {
+		return errors.New("password cannot contain the username")
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	denylist := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(denylist, []byte("Correct-Horse-42\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(10, 3, denylist)
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"Tr0ub4dor&3", true},
+		{"Sh0rt!", false},
+		{"alllowercase1", false},
+		{"ÜberSicher99", true},
+		{"P@ssw0rd", false},
+		{"correct-horse-42", false},
+		{"Alice-Rocks-1", false},
+	}
+	for _, tt := range tests {
+		if err := policy.Validate(tt.password, "alice"); (err == nil) != tt.valid {
+			t.Errorf("Validate(%q) = %v, want valid %t", tt.password, err, tt.valid)
+		}
+	}
+
+	// Common passwords are denied even when they satisfy the other rules
+	loose, err := NewPasswordPolicy(6, 1, "")
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+	if err := loose.Validate("Password1", "bob"); err == nil {
+		t.Error("common password was accepted")
+	}
+	if err := loose.Validate("bobcat-lover", "bo"); err != nil {
+		t.Errorf("short username rejected an unrelated password: %v", err)
+	}
+
+	if _, err := NewPasswordPolicy(10, 3, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing denylist file was ignored")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
@@ -47,16 +49,16 @@ func NewProcessService(
 	}
 }
 
-func (s *ProcessService) RunScript(ctx context.Context, userID primitive.ObjectID, scriptID primitive.ObjectID, Args []string) (*models.Process, error) {
+func (s *ProcessService) RunScript(ctx context.Context, userID primitive.ObjectID, scriptID primitive.ObjectID, Args []string, trigger models.ProcessTrigger) (*models.Process, error) {
 	// Kiểm tra quyền truy cập script
-	script, err := s.scriptService.GetScriptByID(ctx, userID, scriptID)
+	script, err := s.scriptService.AuthorizeScript(ctx, userID, scriptID, models.PermissionRun)
 	if err != nil {
 		return nil, fmt.Errorf("không thể truy cập script: %w", err)
 	}
 
 	// Kiểm tra xem script có đang chạy không
 	runningProcess, err := s.processRepo.FindRunningByScriptID(ctx, scriptID)
-	if err != nil {
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, fmt.Errorf("lỗi khi kiểm tra tiến trình đang chạy: %w", err)
 	}
 
@@ -64,46 +66,37 @@ func (s *ProcessService) RunScript(ctx context.Context, userID primitive.ObjectI
 		return nil, errors.New("script đang chạy, vui lòng dừng tiến trình hiện tại trước khi chạy lại")
 	}
 
-	// Tạo thư mục tạm thời để lưu script
+	// Tạo thư mục tạm thời làm workspace cho lần chạy
 	tempDir, err := os.MkdirTemp("", "script-*")
 	if err != nil {
 		return nil, fmt.Errorf("không thể tạo thư mục tạm thời: %w", err)
 	}
 
-	var cmd *exec.Cmd
-	var scriptPath string
-
-	// Tạo file script dựa vào loại
-	switch script.Type {
-	case "python":
-		scriptPath = filepath.Join(tempDir, "script.py")
-		if err := os.WriteFile(scriptPath, []byte(script.Content), 0644); err != nil {
-			os.RemoveAll(tempDir)
-			return nil, fmt.Errorf("không thể tạo file script: %w", err)
-		}
-		cmd = exec.Command("python3", scriptPath)
-
-	case "golang":
-		scriptPath = filepath.Join(tempDir, "script.go")
-		if err := os.WriteFile(scriptPath, []byte(script.Content), 0644); err != nil {
-			os.RemoveAll(tempDir)
-			return nil, fmt.Errorf("không thể tạo file script: %w", err)
-		}
-		cmd = exec.Command("go", "run", scriptPath)
+	// Ghi toàn bộ file của script vào workspace
+	files := script.FileTree()
+	if err := materializeWorkspace(tempDir, files); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("không thể tạo file script: %w", err)
+	}
 
-	default:
+	cmd, err := buildCommand(script, files, Args)
+	if err != nil {
 		os.RemoveAll(tempDir)
-		return nil, fmt.Errorf("loại script không được hỗ trợ: %s", script.Type)
+		return nil, err
 	}
+	cmd.Dir = tempDir
 
 	// Tạo process mới
 	process := &models.Process{
-		ID:        primitive.NewObjectID(),
-		ScriptID:  scriptID,
-		UserID:    userID,
-		Status:    models.ProcessStatusRunning,
-		StartTime: time.Now(),
-		Cmd:       cmd,
+		ID:             primitive.NewObjectID(),
+		ScriptID:       scriptID,
+		UserID:         userID,
+		ScriptRevision: script.Revision,
+		Status:         models.ProcessStatusRunning,
+		Trigger:        trigger,
+		StartTime:      time.Now(),
+		Workspace:      tempDir,
+		Cmd:            cmd,
 	}
 
 	// Lưu process vào DB
@@ -283,13 +276,13 @@ func (s *ProcessService) StreamProcessOutput(c *fiber.Ctx, processID primitive.O
 	// 	}
 	// })
 
-	c.Context().Response().SetBodyStreamWriter(func(w *bufio.Writer) {
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
 		for {
 			select {
 			case <-notifier:
 				// Client đã ngắt kết nối
 				s.logger.Info("Client ngắt kết nối, dừng tiến trình", zap.String("processID", processID.Hex()))
-				s.StopProcess(c.Context(), processID)
+				s.stopProcess(context.Background(), processID)
 				return
 
 			case output := <-outputChan:
@@ -302,6 +295,7 @@ func (s *ProcessService) StreamProcessOutput(c *fiber.Ctx, processID primitive.O
 				s.mu.Lock()
 				delete(s.processes, processID)
 				s.mu.Unlock()
+				os.RemoveAll(process.Workspace)
 
 				// Cập nhật trạng thái trong DB
 				exitCode := 0
@@ -319,7 +313,7 @@ func (s *ProcessService) StreamProcessOutput(c *fiber.Ctx, processID primitive.O
 					status = models.ProcessStatusError
 				}
 
-				s.processRepo.UpdateStatus(c.Context(), processID, status, &exitCode, errMsg)
+				s.processRepo.UpdateStatus(context.Background(), processID, status, &exitCode, errMsg)
 
 				// Gửi thông báo kết thúc
 				fmt.Fprintf(w, "event: end\ndata: {\"status\":\"%s\",\"exitCode\":%d,\"error\":\"%s\"}\n\n", status, exitCode, errMsg)
@@ -332,7 +326,75 @@ func (s *ProcessService) StreamProcessOutput(c *fiber.Ctx, processID primitive.O
 	return nil
 }
 
-func (s *ProcessService) StopProcess(ctx context.Context, processID primitive.ObjectID) error {
+func (s *ProcessService) StopProcess(ctx context.Context, userID, processID primitive.ObjectID) error {
+	// Kiểm tra quyền truy cập tiến trình
+	process, err := s.GetProcessByID(ctx, userID, processID)
+	if err != nil {
+		return err
+	}
+
+	// Người không chạy tiến trình cần quyền run trên script để dừng nó
+	if process.UserID != userID {
+		if _, err := s.scriptService.AuthorizeScript(ctx, userID, process.ScriptID, models.PermissionRun); err != nil {
+			return errors.New("không có quyền dừng tiến trình này")
+		}
+	}
+
+	return s.stopProcess(ctx, processID)
+}
+
+// PinProcess ghim hoặc bỏ ghim một lần chạy; lần chạy đã ghim không bị xóa bởi chính sách lưu trữ
+func (s *ProcessService) PinProcess(ctx context.Context, userID, processID primitive.ObjectID, pinned bool) (*models.Process, error) {
+	process, err := s.GetProcessByID(ctx, userID, processID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Người không chạy tiến trình cần quyền run trên script để ghim nó
+	if process.UserID != userID {
+		if _, err := s.scriptService.AuthorizeScript(ctx, userID, process.ScriptID, models.PermissionRun); err != nil {
+			return nil, errors.New("không có quyền ghim tiến trình này")
+		}
+	}
+
+	if err := s.processRepo.SetPinned(ctx, processID, pinned); err != nil {
+		return nil, fmt.Errorf("không thể cập nhật tiến trình: %w", err)
+	}
+
+	process.Pinned = pinned
+	return process, nil
+}
+
+// StopScriptProcesses dừng tiến trình đang chạy của một script
+func (s *ProcessService) StopScriptProcesses(ctx context.Context, scriptID primitive.ObjectID) error {
+	process, err := s.processRepo.FindRunningByScriptID(ctx, scriptID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lỗi khi kiểm tra tiến trình đang chạy: %w", err)
+	}
+
+	return s.stopProcess(ctx, process.ID)
+}
+
+// StopUserProcesses dừng mọi tiến trình đang chạy do một user khởi chạy
+func (s *ProcessService) StopUserProcesses(ctx context.Context, userID primitive.ObjectID) error {
+	processes, err := s.processRepo.FindRunningByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("lỗi khi lấy danh sách tiến trình: %w", err)
+	}
+
+	for _, process := range processes {
+		if err := s.stopProcess(ctx, process.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ProcessService) stopProcess(ctx context.Context, processID primitive.ObjectID) error {
 	// Kiểm tra process trong memory
 	s.mu.Lock()
 	process, exists := s.processes[processID]
@@ -391,6 +453,7 @@ func (s *ProcessService) StopProcess(ctx context.Context, processID primitive.Ob
 	s.mu.Lock()
 	delete(s.processes, processID)
 	s.mu.Unlock()
+	os.RemoveAll(process.Workspace)
 
 	// Cập nhật trạng thái trong DB
 	exitCode := -1
@@ -417,17 +480,73 @@ func (s *ProcessService) GetProcessByID(ctx context.Context, userID, processID p
 	return process, nil
 }
 
-func (s *ProcessService) GetProcessesByScriptID(ctx context.Context, userID, scriptID primitive.ObjectID) ([]*models.Process, error) {
+// GetProcesses trả về một trang tiến trình do user khởi chạy
+func (s *ProcessService) GetProcesses(ctx context.Context, userID primitive.ObjectID, filter *models.ProcessFilter, sort models.ProcessSort, page models.PageRequest) (*models.ProcessPage, error) {
+	filter.UserID = &userID
+	return s.listProcesses(ctx, filter, sort, page)
+}
+
+// GetProcessesByScriptID trả về một trang lịch sử chạy của script, cần quyền view trên script
+func (s *ProcessService) GetProcessesByScriptID(ctx context.Context, userID, scriptID primitive.ObjectID, filter *models.ProcessFilter, sort models.ProcessSort, page models.PageRequest) (*models.ProcessPage, error) {
 	// Kiểm tra quyền truy cập script
-	_, err := s.scriptService.GetScriptByID(ctx, userID, scriptID)
-	if err != nil {
+	if _, err := s.scriptService.AuthorizeScript(ctx, userID, scriptID, models.PermissionView); err != nil {
 		return nil, fmt.Errorf("không thể truy cập script: %w", err)
 	}
 
-	processes, err := s.processRepo.FindByScriptID(ctx, scriptID)
+	filter.ScriptID = &scriptID
+	return s.listProcesses(ctx, filter, sort, page)
+}
+
+func (s *ProcessService) listProcesses(ctx context.Context, filter *models.ProcessFilter, sort models.ProcessSort, page models.PageRequest) (*models.ProcessPage, error) {
+	processes, err := s.processRepo.List(ctx, filter, sort, page)
 	if err != nil {
 		return nil, fmt.Errorf("lỗi khi lấy danh sách tiến trình: %w", err)
 	}
 
 	return processes, nil
 }
+
+// materializeWorkspace ghi toàn bộ file của script vào thư mục workspace
+func materializeWorkspace(dir string, files []models.ScriptFile) error {
+	for _, file := range files {
+		target := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(file.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildCommand tạo lệnh chạy entrypoint của script, đường dẫn tương đối so với workspace
+func buildCommand(script *models.Script, files []models.ScriptFile, args []string) (*exec.Cmd, error) {
+	entrypoint := script.EntrypointPath()
+
+	switch script.Type {
+	case models.ScriptTypePython:
+		return exec.Command("python3", append([]string{filepath.FromSlash(entrypoint)}, args...)...), nil
+
+	case models.ScriptTypeGolang:
+		entryDir := path.Dir(entrypoint)
+
+		// Có go.mod thì chạy package chứa entrypoint, nếu không thì chạy các file .go cùng thư mục
+		for _, file := range files {
+			if file.Path == "go.mod" {
+				return exec.Command("go", append([]string{"run", "./" + entryDir}, args...)...), nil
+			}
+		}
+
+		runArgs := []string{"run"}
+		for _, file := range files {
+			if path.Dir(file.Path) == entryDir && strings.HasSuffix(file.Path, ".go") && !strings.HasSuffix(file.Path, "_test.go") {
+				runArgs = append(runArgs, filepath.FromSlash(file.Path))
+			}
+		}
+		return exec.Command("go", append(runArgs, args...)...), nil
+
+	default:
+		return nil, fmt.Errorf("loại script không được hỗ trợ: %s", script.Type)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"scripts-management/internal/models"
+)
+
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		script *models.Script
+		files  []models.ScriptFile
+		want   []string
+	}{
+		{
+			name:   "python",
+			script: &models.Script{Type: models.ScriptTypePython, Entrypoint: "src/app.py"},
+			want:   []string{"python3", filepath.FromSlash("src/app.py"), "--verbose"},
+		},
+		{
+			name:   "go module",
+			script: &models.Script{Type: models.ScriptTypeGolang, Entrypoint: "cmd/tool/main.go"},
+			files:  []models.ScriptFile{{Path: "cmd/tool/main.go"}, {Path: "go.mod"}},
+			want:   []string{"go", "run", "./cmd/tool", "--verbose"},
+		},
+		{
+			name:   "go files next to the entrypoint",
+			script: &models.Script{Type: models.ScriptTypeGolang},
+			files: []models.ScriptFile{
+				{Path: "main.go"},
+				{Path: "util.go"},
+				{Path: "util_test.go"},
+				{Path: "lib/other.go"},
+				{Path: "README.md"},
+			},
+			want: []string{"go", "run", "main.go", "util.go", "--verbose"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := buildCommand(tt.script, tt.files, []string{"--verbose"})
+			if err != nil {
+				t.Fatalf("buildCommand: %v", err)
+			}
+			if !reflect.DeepEqual(cmd.Args, tt.want) {
+				t.Errorf("args = %q, want %q", cmd.Args, tt.want)
+			}
+		})
+	}
+
+	if _, err := buildCommand(&models.Script{Type: "ruby"}, nil, nil); err == nil {
+		t.Error("buildCommand accepted an unsupported type")
+	}
+}
+
+func TestMaterializeWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	files := []models.ScriptFile{
+		{Path: "main.py", Content: "import lib.util"},
+		{Path: "lib/util.py", Content: "x = 1"},
+	}
+	if err := materializeWorkspace(dir, files); err != nil {
+		t.Fatalf("materializeWorkspace: %v", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			t.Fatalf("reading %s: %v", file.Path, err)
+		}
+		if string(data) != file.Content {
+			t.Errorf("%s = %q, want %q", file.Path, data, file.Content)
+		}
+	}
+}
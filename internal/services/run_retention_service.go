@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// RunRetentionService prunes run history in the background. Each script uses
+// its own RunRetention when set and the global policy otherwise. The output
+// file and leftover workspace of a pruned run are removed with it.
+type RunRetentionService struct {
+	processRepo *repository.ProcessRepository
+	scriptRepo  *repository.ScriptRepository
+	logger      *zap.Logger
+}
+
+func NewRunRetentionService(
+	processRepo *repository.ProcessRepository,
+	scriptRepo *repository.ScriptRepository,
+	logger *zap.Logger,
+) *RunRetentionService {
+	return &RunRetentionService{
+		processRepo: processRepo,
+		scriptRepo:  scriptRepo,
+		logger:      logger,
+	}
+}
+
+// Run prunes run history with the global policy every interval until ctx is
+// cancelled.
+func (s *RunRetentionService) Run(ctx context.Context, interval time.Duration, global models.RunRetention) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if pruned, err := s.Prune(ctx, global); err != nil {
+			s.logger.Error("Failed to prune run history", zap.Error(err))
+		} else if pruned > 0 {
+			s.logger.Info("Pruned run history", zap.Int("count", pruned))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune removes the runs that fall outside the retention of their script and
+// returns how many were removed.
+func (s *RunRetentionService) Prune(ctx context.Context, global models.RunRetention) (int, error) {
+	scriptIDs, err := s.processRepo.FindScriptIDs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find scripts with runs: %w", err)
+	}
+
+	retentions, err := s.scriptRepo.FindRunRetentions(ctx, scriptIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find run retentions: %w", err)
+	}
+
+	pruned := 0
+	for _, scriptID := range scriptIDs {
+		retention := &global
+		if override, ok := retentions[scriptID]; ok {
+			retention = override
+		}
+		if retention.IsUnlimited() {
+			continue
+		}
+
+		var before *time.Time
+		if retention.MaxAgeDays > 0 {
+			cutoff := time.Now().AddDate(0, 0, -retention.MaxAgeDays)
+			before = &cutoff
+		}
+
+		processes, err := s.processRepo.FindExpiredRuns(ctx, scriptID, retention.MaxRuns, before)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to find expired runs: %w", err)
+		}
+		if len(processes) == 0 {
+			continue
+		}
+
+		ids := make([]primitive.ObjectID, len(processes))
+		for i, process := range processes {
+			ids[i] = process.ID
+			s.removeRunArtifacts(process)
+		}
+		if err := s.processRepo.DeleteByIDs(ctx, ids); err != nil {
+			return pruned, fmt.Errorf("failed to delete expired runs: %w", err)
+		}
+		pruned += len(ids)
+	}
+
+	return pruned, nil
+}
+
+// removeRunArtifacts deletes the output file and workspace of a finished run.
+// Failures are logged so the run record is still pruned.
+func (s *RunRetentionService) removeRunArtifacts(process *models.Process) {
+	if process.OutputPath != "" {
+		if err := os.Remove(process.OutputPath); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("Failed to remove run output", zap.String("process_id", process.ID.Hex()), zap.Error(err))
+		}
+	}
+	if process.Workspace != "" {
+		if err := os.RemoveAll(process.Workspace); err != nil {
+			s.logger.Warn("Failed to remove run workspace", zap.String("process_id", process.ID.Hex()), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scripts-management/internal/models"
+
+	"go.uber.org/zap"
+)
+
+func TestRemoveRunArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "run.log")
+	workspace := filepath.Join(dir, "workspace")
+	if err := os.WriteFile(output, []byte("done"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(workspace, "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	service := &RunRetentionService{logger: zap.NewNop()}
+	service.removeRunArtifacts(&models.Process{OutputPath: output, Workspace: workspace})
+
+	for _, path := range []string{output, workspace} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", path, err)
+		}
+	}
+
+	// Runs without artifacts are left alone
+	service.removeRunArtifacts(&models.Process{})
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("run without artifacts removed files: %v", err)
+	}
+}
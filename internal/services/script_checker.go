@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"scripts-management/internal/models"
+)
+
+const checkTimeout = 30 * time.Second
+
+// pyCompileProgram compiles every file given as argument with py_compile and
+// prints the syntax errors as a JSON list of diagnostics.
+const pyCompileProgram = `import json, py_compile, sys
+out = []
+for f in sys.argv[1:]:
+    try:
+        py_compile.compile(f, doraise=True)
+    except py_compile.PyCompileError as e:
+        v = e.exc_value
+        out.append({"file": f, "line": getattr(v, "lineno", 0) or 0, "column": getattr(v, "offset", 0) or 0, "message": getattr(v, "msg", None) or e.msg})
+print(json.dumps(out))
+`
+
+// goDiagnosticPattern matches "file.go:line:col: message" lines printed by gofmt and go vet.
+// go vet prefixes type-checking errors with "vet: ".
+var goDiagnosticPattern = regexp.MustCompile(`^(vet: )?(?:\./)?(.+?\.go):(\d+):(\d+): (.*)$`)
+
+// goVetEnv keeps go vet on the installed toolchain and offline, so a go.mod
+// in the script cannot make the server download a toolchain or modules, and
+// no cgo code is compiled.
+var goVetEnv = []string{"GOTOOLCHAIN=local", "GOFLAGS=-mod=mod", "GOPROXY=off", "CGO_ENABLED=0"}
+
+// ScriptCheckError is returned by strict saves whose files do not pass the check.
+type ScriptCheckError struct {
+	Result *models.CheckResult
+}
+
+func (e *ScriptCheckError) Error() string {
+	return "script check failed"
+}
+
+// checkScript materializes the script files into a temporary directory and
+// runs the linters of its runtime over them.
+func checkScript(ctx context.Context, script *models.Script) (*models.CheckResult, error) {
+	dir, err := os.MkdirTemp("", "script-check-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create check directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := script.FileTree()
+	if err := materializeWorkspace(dir, files); err != nil {
+		return nil, fmt.Errorf("failed to write script files: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var diagnostics []models.Diagnostic
+	switch script.Type {
+	case models.ScriptTypePython:
+		diagnostics, err = checkPython(ctx, dir, files)
+	case models.ScriptTypeGolang:
+		diagnostics, err = checkGolang(ctx, dir, files)
+	default:
+		return nil, fmt.Errorf("unsupported script type: %s", script.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+
+	result := &models.CheckResult{Valid: true, Diagnostics: diagnostics}
+	for _, d := range diagnostics {
+		if d.Severity == models.SeverityError {
+			result.Valid = false
+			break
+		}
+	}
+	if result.Diagnostics == nil {
+		result.Diagnostics = []models.Diagnostic{}
+	}
+
+	return result, nil
+}
+
+func checkPython(ctx context.Context, dir string, files []models.ScriptFile) ([]models.Diagnostic, error) {
+	args := []string{"-c", pyCompileProgram}
+	for _, file := range files {
+		if strings.HasSuffix(file.Path, ".py") {
+			args = append(args, file.Path)
+		}
+	}
+	if len(args) == 2 {
+		return nil, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "python3", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PYTHONDONTWRITEBYTECODE=1", "PYTHONPYCACHEPREFIX="+filepath.Join(dir, ".pycache"))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run py_compile: %w", err)
+	}
+
+	var diagnostics []models.Diagnostic
+	if err := json.Unmarshal(output, &diagnostics); err != nil {
+		return nil, fmt.Errorf("failed to parse py_compile output: %w", err)
+	}
+	for i := range diagnostics {
+		diagnostics[i].Severity = models.SeverityError
+		diagnostics[i].Source = "py_compile"
+	}
+
+	return diagnostics, nil
+}
+
+func checkGolang(ctx context.Context, dir string, files []models.ScriptFile) ([]models.Diagnostic, error) {
+	var goFiles []string
+	hasGoMod := false
+	for _, file := range files {
+		if file.Path == "go.mod" {
+			hasGoMod = true
+		}
+		if strings.HasSuffix(file.Path, ".go") {
+			goFiles = append(goFiles, file.Path)
+		}
+	}
+	if len(goFiles) == 0 {
+		return nil, nil
+	}
+
+	// gofmt -e reports syntax errors, -l lists files that are not formatted
+	gofmt := exec.CommandContext(ctx, "gofmt", append([]string{"-l", "-e"}, goFiles...)...)
+	gofmt.Dir = dir
+	var stdout, stderr bytes.Buffer
+	gofmt.Stdout = &stdout
+	gofmt.Stderr = &stderr
+	if err := gofmt.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to run gofmt: %w", ctx.Err())
+		}
+		// A non-zero exit reports diagnostics; anything else means gofmt did not run
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run gofmt: %w", err)
+		}
+	}
+
+	diagnostics := parseGoDiagnostics(stderr.String(), "gofmt", models.SeverityError)
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if line != "" {
+			diagnostics = append(diagnostics, models.Diagnostic{
+				File:     line,
+				Line:     1,
+				Column:   1,
+				Message:  "file is not gofmt-formatted",
+				Severity: models.SeverityWarning,
+				Source:   "gofmt",
+			})
+		}
+	}
+
+	// go vet cannot type-check files that do not parse
+	for _, d := range diagnostics {
+		if d.Severity == models.SeverityError {
+			return diagnostics, nil
+		}
+	}
+
+	// Without go.mod, files of each directory are vetted as a standalone package
+	var vetRuns [][]string
+	if hasGoMod {
+		vetRuns = append(vetRuns, []string{"./..."})
+	} else {
+		byDir := make(map[string][]string)
+		var dirs []string
+		for _, file := range goFiles {
+			d := path.Dir(file)
+			if _, ok := byDir[d]; !ok {
+				dirs = append(dirs, d)
+			}
+			byDir[d] = append(byDir[d], file)
+		}
+		for _, d := range dirs {
+			vetRuns = append(vetRuns, byDir[d])
+		}
+	}
+
+	for _, vetArgs := range vetRuns {
+		vet := exec.CommandContext(ctx, "go", append([]string{"vet"}, vetArgs...)...)
+		vet.Dir = dir
+		vet.Env = append(os.Environ(), goVetEnv...)
+		output, err := vet.CombinedOutput()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("failed to run go vet: %w", ctx.Err())
+			}
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("failed to run go vet: %w", err)
+			}
+		}
+		diagnostics = append(diagnostics, parseGoDiagnostics(string(output), "go vet", models.SeverityWarning)...)
+	}
+
+	return diagnostics, nil
+}
+
+// parseGoDiagnostics extracts diagnostics from gofmt or go vet output. Lines
+// prefixed with "vet: " are type-checking errors and are always reported as errors.
+func parseGoDiagnostics(output, source string, severity models.DiagnosticSeverity) []models.Diagnostic {
+	var diagnostics []models.Diagnostic
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(output, "\n") {
+		match := goDiagnosticPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil || seen[match[0]] {
+			continue
+		}
+		seen[match[0]] = true
+
+		lineNo, _ := strconv.Atoi(match[3])
+		column, _ := strconv.Atoi(match[4])
+		d := models.Diagnostic{
+			File:     match[2],
+			Line:     lineNo,
+			Column:   column,
+			Message:  match[5],
+			Severity: severity,
+			Source:   source,
+		}
+		if match[1] != "" {
+			d.Severity = models.SeverityError
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"scripts-management/internal/models"
+)
+
+func TestParseGoDiagnostics(t *testing.T) {
+	output := `# example
+./main.go:3:2: fmt.Printf format %d has arg s of wrong type string
+vet: lib/util.go:7:9: undefined: missing
+./main.go:3:2: fmt.Printf format %d has arg s of wrong type string
+not a diagnostic
+`
+	want := []models.Diagnostic{
+		{
+			File:     "main.go",
+			Line:     3,
+			Column:   2,
+			Message:  "fmt.Printf format %d has arg s of wrong type string",
+			Severity: models.SeverityWarning,
+			Source:   "go vet",
+		},
+		{
+			File:     "lib/util.go",
+			Line:     7,
+			Column:   9,
+			Message:  "undefined: missing",
+			Severity: models.SeverityError,
+			Source:   "go vet",
+		},
+	}
+
+	got := parseGoDiagnostics(output, "go vet", models.SeverityWarning)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGoDiagnostics =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := parseGoDiagnostics("", "gofmt", models.SeverityError); got != nil {
+		t.Errorf("parseGoDiagnostics of empty output = %+v", got)
+	}
+}
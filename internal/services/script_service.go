@@ -4,49 +4,121 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"scripts-management/internal/models"
 	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ScriptConflictError is returned when a script changed after the version the
+// client edited. PreconditionFailed is set when the stale version came from If-Match.
+type ScriptConflictError struct {
+	Current            *models.Script
+	PreconditionFailed bool
+}
+
+func (e *ScriptConflictError) Error() string {
+	return fmt.Sprintf("script has been modified, current version is %d", e.Current.Version)
+}
+
+// ProcessStopper stops the runs of a script before it is deleted. It is a
+// function rather than a ProcessService, since ProcessService itself depends
+// on ScriptService.
+type ProcessStopper func(ctx context.Context, scriptID primitive.ObjectID) error
+
 type ScriptService struct {
-	scriptRepo      *repository.ScriptRepository
-	scriptShareRepo *repository.ScriptShareRepository
-	userRepo        *repository.UserRepository
+	scriptRepo          *repository.ScriptRepository
+	scriptShareRepo     *repository.ScriptShareRepository
+	scriptRevisionRepo  *repository.ScriptRevisionRepository
+	userRepo            *repository.UserRepository
+	groupRepo           *repository.GroupRepository
+	folderRepo          *repository.FolderRepository
+	processRepo         *repository.ProcessRepository
+	notificationRepo    *repository.NotificationRepository
+	transactor          *repository.Transactor
+	stopScriptProcesses ProcessStopper
 }
 
 func NewScriptService(
 	scriptRepo *repository.ScriptRepository,
 	scriptShareRepo *repository.ScriptShareRepository,
+	scriptRevisionRepo *repository.ScriptRevisionRepository,
 	userRepo *repository.UserRepository,
+	groupRepo *repository.GroupRepository,
+	folderRepo *repository.FolderRepository,
+	processRepo *repository.ProcessRepository,
+	notificationRepo *repository.NotificationRepository,
+	transactor *repository.Transactor,
+	stopScriptProcesses ProcessStopper,
 ) *ScriptService {
 	return &ScriptService{
-		scriptRepo:      scriptRepo,
-		scriptShareRepo: scriptShareRepo,
-		userRepo:        userRepo,
+		scriptRepo:          scriptRepo,
+		scriptShareRepo:     scriptShareRepo,
+		scriptRevisionRepo:  scriptRevisionRepo,
+		userRepo:            userRepo,
+		groupRepo:           groupRepo,
+		folderRepo:          folderRepo,
+		processRepo:         processRepo,
+		notificationRepo:    notificationRepo,
+		transactor:          transactor,
+		stopScriptProcesses: stopScriptProcesses,
 	}
 }
 
 func (s *ScriptService) CreateScript(ctx context.Context, userID primitive.ObjectID, req *models.CreateScriptRequest) (*models.Script, error) {
+	files, entrypoint, err := buildFileTree(req)
+	if err != nil {
+		return nil, err
+	}
+
+	folderID, err := s.resolveFolder(ctx, userID, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
 	script := &models.Script{
 		Name:        req.Name,
 		Description: req.Description,
-		Content:     req.Content,
+		Files:       files,
+		Entrypoint:  entrypoint,
 		Type:        req.Type,
+		Revision:    1,
 		OwnerID:     userID,
+		FolderID:    folderID,
+		Tags:        normalizeTags(req.Tags),
+	}
+
+	if req.Strict {
+		if err := ensureCompiles(ctx, script); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.scriptRepo.Create(ctx, script); err != nil {
 		return nil, fmt.Errorf("failed to create script: %w", err)
 	}
 
+	if err := s.scriptRevisionRepo.Create(ctx, newRevision(script, userID, 0)); err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+
 	return script, nil
 }
 
 func (s *ScriptService) GetScriptByID(ctx context.Context, userID, scriptID primitive.ObjectID) (*models.Script, error) {
+	return s.AuthorizeScript(ctx, userID, scriptID, models.PermissionView)
+}
+
+// AuthorizeScript loads a script and checks that the user holds at least the
+// required permission on it. The owner holds every permission.
+func (s *ScriptService) AuthorizeScript(ctx context.Context, userID, scriptID primitive.ObjectID, required models.SharePermission) (*models.Script, error) {
 	script, err := s.scriptRepo.FindByID(ctx, scriptID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find script: %w", err)
@@ -57,57 +129,97 @@ func (s *ScriptService) GetScriptByID(ctx context.Context, userID, scriptID prim
 		return script, nil
 	}
 
-	// Check if script is shared with user
-	_, err = s.scriptShareRepo.FindByScriptIDAndUserID(ctx, scriptID, userID)
+	// Check if script is shared with user directly or through a group
+	permission, err := s.sharedPermission(ctx, scriptID, userID)
 	if err != nil {
+		return nil, err
+	}
+	if permission == "" {
 		return nil, errors.New("access denied: script not shared with user")
 	}
 
+	if !permission.Includes(required) {
+		return nil, fmt.Errorf("access denied: %s permission required", required)
+	}
+
 	return script, nil
 }
 
-func (s *ScriptService) GetUserScripts(ctx context.Context, userID primitive.ObjectID) ([]*models.Script, error) {
-	// Get scripts owned by user
-	ownedScripts, err := s.scriptRepo.FindByOwnerID(ctx, userID)
+// sharedPermission returns the highest permission granted on a script to the
+// user or to any group the user is a member of, or "" when it is not shared.
+func (s *ScriptService) sharedPermission(ctx context.Context, scriptID, userID primitive.ObjectID) (models.SharePermission, error) {
+	var permission models.SharePermission
+
+	share, err := s.scriptShareRepo.FindByScriptIDAndUserID(ctx, scriptID, userID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("failed to find share: %w", err)
+	}
+	now := time.Now()
+	if share != nil && share.IsActive(now) {
+		permission = share.EffectivePermission()
+	}
+
+	groupIDs, err := s.groupRepo.FindIDsByUserID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find owned scripts: %w", err)
+		return "", fmt.Errorf("failed to find groups: %w", err)
+	}
+	groupShares, err := s.scriptShareRepo.FindByScriptIDAndGroupIDs(ctx, scriptID, groupIDs)
+	if err != nil {
+		return "", fmt.Errorf("failed to find group shares: %w", err)
+	}
+	for _, groupShare := range groupShares {
+		if groupShare.IsActive(now) && !permission.Includes(groupShare.EffectivePermission()) {
+			permission = groupShare.EffectivePermission()
+		}
 	}
 
-	// Get scripts shared with user
-	shares, err := s.scriptShareRepo.FindByUserID(ctx, userID)
+	return permission, nil
+}
+
+// GetUserScripts returns a page of the scripts owned by or shared with a user
+// that match filter.
+//
+// The IDs of the shared scripts are looked up first rather than joined with
+// $lookup in the listing. A $text match has to be the first stage of the
+// aggregation, and a $lookup ahead of the ownership filter would join the
+// shares of every script in the collection. With the IDs known, the listing
+// matches owner_id and _id through their indexes, and still counts and pages
+// in a single aggregation.
+func (s *ScriptService) GetUserScripts(ctx context.Context, userID primitive.ObjectID, filter *models.ScriptFilter, sort models.ScriptSort, page models.PageRequest) (*models.ScriptPage, error) {
+	// Get scripts shared with user directly or through groups
+	groupIDs, err := s.groupRepo.FindIDsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find groups: %w", err)
+	}
+	sharedIDs, err := s.scriptShareRepo.FindActiveScriptIDs(ctx, userID, groupIDs, time.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to find shared scripts: %w", err)
 	}
 
-	// Get shared script details
-	sharedScripts := make([]*models.Script, 0, len(shares))
-	for _, share := range shares {
-		script, err := s.scriptRepo.FindByID(ctx, share.ScriptID)
-		if err != nil {
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				continue
-			}
-			return nil, fmt.Errorf("failed to find shared script: %w", err)
-		}
-		sharedScripts = append(sharedScripts, script)
+	result, err := s.scriptRepo.List(ctx, userID, sharedIDs, filter, sort, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %w", err)
 	}
 
-	// Combine owned and shared scripts
-	allScripts := append(ownedScripts, sharedScripts...)
-	return allScripts, nil
+	return result, nil
 }
 
-func (s *ScriptService) UpdateScript(ctx context.Context, userID, scriptID primitive.ObjectID, req *models.UpdateScriptRequest) (*models.Script, error) {
-	script, err := s.scriptRepo.FindByID(ctx, scriptID)
+// UpdateScript applies req to the script. When expectedVersion is set the
+// update is rejected unless the script is still at that version.
+func (s *ScriptService) UpdateScript(ctx context.Context, userID, scriptID primitive.ObjectID, req *models.UpdateScriptRequest, expectedVersion *int) (*models.Script, error) {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionEdit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find script: %w", err)
+		return nil, err
 	}
 
-	// Only owner can update script
-	if script.OwnerID != userID {
-		return nil, errors.New("access denied: only owner can update script")
+	if expectedVersion != nil && *expectedVersion != script.Version {
+		return nil, &ScriptConflictError{Current: script, PreconditionFailed: true}
 	}
 
+	baseline := legacyBaseline(script)
+	migrateLegacyContent(script)
+	before := newRevision(script, userID, 0)
+
 	// Update fields if provided
 	if req.Name != "" {
 		script.Name = req.Name
@@ -115,20 +227,109 @@ func (s *ScriptService) UpdateScript(ctx context.Context, userID, scriptID primi
 	if req.Description != "" {
 		script.Description = req.Description
 	}
-	if req.Content != "" {
-		script.Content = req.Content
-	}
 	if req.Type != "" {
 		script.Type = req.Type
 	}
+	if req.Tags != nil {
+		script.Tags = normalizeTags(req.Tags)
+	}
+	if req.Entrypoint != "" {
+		entrypoint, err := normalizeFilePath(req.Entrypoint)
+		if err != nil {
+			return nil, err
+		}
+		if script.FindFile(entrypoint) == nil {
+			return nil, fmt.Errorf("entrypoint %s does not exist", entrypoint)
+		}
+		script.Entrypoint = entrypoint
+	}
+	if req.Content != "" {
+		// Content is a shortcut for replacing the entrypoint file
+		setFile(script, script.EntrypointPath(), req.Content)
+	}
 
-	if err := s.scriptRepo.Update(ctx, script); err != nil {
-		return nil, fmt.Errorf("failed to update script: %w", err)
+	if req.Strict {
+		if err := ensureCompiles(ctx, script); err != nil {
+			return nil, err
+		}
+	}
+
+	if !sameContent(before, script) {
+		if err := s.saveRevision(ctx, script, baseline, userID, 0); err != nil {
+			return nil, err
+		}
+	} else if err := s.updateScript(ctx, script); err != nil {
+		return nil, err
 	}
 
 	return script, nil
 }
 
+// MoveScript moves a script into a folder of its owner, or to the root when
+// no folder is given. Only the owner can move a script.
+func (s *ScriptService) MoveScript(ctx context.Context, userID, scriptID primitive.ObjectID, req *models.MoveScriptRequest) (*models.Script, error) {
+	script, err := s.scriptRepo.FindByID(ctx, scriptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find script: %w", err)
+	}
+
+	if script.OwnerID != userID {
+		return nil, errors.New("access denied: only owner can move script")
+	}
+
+	folderID, err := s.resolveFolder(ctx, userID, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.scriptRepo.UpdateFolder(ctx, scriptID, folderID); err != nil {
+		return nil, fmt.Errorf("failed to move script: %w", err)
+	}
+
+	return s.scriptRepo.FindByID(ctx, scriptID)
+}
+
+// SetRunRetention overrides the global run history retention of a script, or
+// restores the global one when retention is nil. It requires the manage
+// permission since pruning removes the history of every user.
+func (s *ScriptService) SetRunRetention(ctx context.Context, userID, scriptID primitive.ObjectID, retention *models.RunRetention) (*models.Script, error) {
+	if _, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionManage); err != nil {
+		return nil, err
+	}
+
+	if retention != nil && (retention.MaxRuns < 0 || retention.MaxAgeDays < 0) {
+		return nil, errors.New("retention limits cannot be negative")
+	}
+
+	if err := s.scriptRepo.UpdateRunRetention(ctx, scriptID, retention); err != nil {
+		return nil, fmt.Errorf("failed to update run retention: %w", err)
+	}
+
+	return s.scriptRepo.FindByID(ctx, scriptID)
+}
+
+// resolveFolder parses folderID and checks that the folder belongs to the
+// user. An empty folderID is the root.
+func (s *ScriptService) resolveFolder(ctx context.Context, userID primitive.ObjectID, folderID string) (primitive.ObjectID, error) {
+	if folderID == "" {
+		return primitive.NilObjectID, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(folderID)
+	if err != nil {
+		return primitive.NilObjectID, errors.New("invalid folder ID format")
+	}
+
+	folder, err := s.folderRepo.FindByID(ctx, id)
+	if err != nil || folder.OwnerID != userID {
+		return primitive.NilObjectID, errors.New("folder not found")
+	}
+
+	return folder.ID, nil
+}
+
+// DeleteScript moves a script to the trash. Trashed scripts are hidden from
+// listings and runs until they are restored or purged.
 func (s *ScriptService) DeleteScript(ctx context.Context, userID, scriptID primitive.ObjectID) error {
 	script, err := s.scriptRepo.FindByID(ctx, scriptID)
 	if err != nil {
@@ -140,46 +341,354 @@ func (s *ScriptService) DeleteScript(ctx context.Context, userID, scriptID primi
 		return errors.New("access denied: only owner can delete script")
 	}
 
+	if err := s.stopProcesses(ctx, scriptID); err != nil {
+		return err
+	}
+
+	if err := s.scriptRepo.SoftDelete(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to delete script: %w", err)
+	}
+
+	return nil
+}
+
+// ListTrash returns the trashed scripts of a user.
+func (s *ScriptService) ListTrash(ctx context.Context, userID primitive.ObjectID) ([]*models.Script, error) {
+	scripts, err := s.scriptRepo.FindDeletedByOwnerID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trashed scripts: %w", err)
+	}
+
+	return scripts, nil
+}
+
+// RestoreScript takes a script of the user out of the trash, with its shares
+// and history.
+func (s *ScriptService) RestoreScript(ctx context.Context, userID, scriptID primitive.ObjectID) (*models.Script, error) {
+	script, err := s.scriptRepo.FindDeletedByID(ctx, scriptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trashed script: %w", err)
+	}
+
+	if script.OwnerID != userID {
+		return nil, errors.New("access denied: only owner can restore script")
+	}
+
+	if err := s.scriptRepo.Restore(ctx, scriptID); err != nil {
+		return nil, fmt.Errorf("failed to restore script: %w", err)
+	}
+
+	return s.scriptRepo.FindByID(ctx, scriptID)
+}
+
+// PurgeScript permanently deletes a trashed script. Only admins can purge.
+func (s *ScriptService) PurgeScript(ctx context.Context, currentUser *utils.JWTClaims, scriptID primitive.ObjectID) error {
+	if !isAdmin(currentUser) {
+		return errors.New("access denied: only admin can purge script")
+	}
+
+	if _, err := s.scriptRepo.FindDeletedByID(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to find trashed script: %w", err)
+	}
+
+	return s.purgeScript(ctx, scriptID)
+}
+
+// PurgeTrash permanently deletes the scripts that were trashed before cutoff
+// and returns how many were purged.
+func (s *ScriptService) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	scripts, err := s.scriptRepo.FindDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find trashed scripts: %w", err)
+	}
+
+	purged := 0
+	for _, script := range scripts {
+		if err := s.purgeScript(ctx, script.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (s *ScriptService) purgeScript(ctx context.Context, scriptID primitive.ObjectID) error {
+	// Running processes are stopped before their records are removed
+	if err := s.stopProcesses(ctx, scriptID); err != nil {
+		return err
+	}
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.deleteScriptData(ctx, scriptID)
+	})
+}
+
+func (s *ScriptService) stopProcesses(ctx context.Context, scriptID primitive.ObjectID) error {
+	if err := s.stopScriptProcesses(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to stop running processes: %w", err)
+	}
+	return nil
+}
+
+// deleteScriptData removes a script together with its shares, revisions, run
+// history and notifications.
+func (s *ScriptService) deleteScriptData(ctx context.Context, scriptID primitive.ObjectID) error {
 	if err := s.scriptRepo.Delete(ctx, scriptID); err != nil {
 		return fmt.Errorf("failed to delete script: %w", err)
 	}
+	if err := s.scriptShareRepo.DeleteByScriptID(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to delete shares: %w", err)
+	}
+	if err := s.scriptRevisionRepo.DeleteByScriptID(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to delete revisions: %w", err)
+	}
+	if err := s.processRepo.DeleteByScriptID(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to delete processes: %w", err)
+	}
+	if err := s.notificationRepo.DeleteByScriptID(ctx, scriptID); err != nil {
+		return fmt.Errorf("failed to delete notifications: %w", err)
+	}
 
 	return nil
 }
 
-func (s *ScriptService) ShareScript(ctx context.Context, ownerID, scriptID primitive.ObjectID, targetUserID string) error {
+// TransferScript hands a script over to another user. Only the owner or an
+// admin can transfer a script; shares and revisions are kept as they are.
+func (s *ScriptService) TransferScript(ctx context.Context, currentUser *utils.JWTClaims, scriptID primitive.ObjectID, req *models.TransferScriptRequest) (*models.Script, error) {
 	script, err := s.scriptRepo.FindByID(ctx, scriptID)
 	if err != nil {
-		return fmt.Errorf("failed to find script: %w", err)
+		return nil, fmt.Errorf("failed to find script: %w", err)
 	}
 
-	// Only owner can share script
-	if script.OwnerID != ownerID {
-		return errors.New("access denied: only owner can share script")
+	if script.OwnerID != currentUser.UserID && !isAdmin(currentUser) {
+		return nil, errors.New("access denied: only owner or admin can transfer script")
 	}
 
-	// Convert target user ID from string to ObjectID
-	targetID, err := primitive.ObjectIDFromHex(targetUserID)
+	target, err := s.resolveShareUser(ctx, models.ShareTarget{
+		UserID:   req.UserID,
+		Username: req.Username,
+		Email:    req.Email,
+	})
 	if err != nil {
-		return errors.New("invalid user ID format")
+		return nil, err
+	}
+
+	if target.ID == script.OwnerID {
+		return nil, errors.New("user already owns the script")
+	}
+
+	if err := s.scriptRepo.UpdateOwner(ctx, scriptID, target.ID); err != nil {
+		return nil, fmt.Errorf("failed to transfer script: %w", err)
+	}
+
+	// The new owner no longer needs a share on the script
+	if err := s.scriptShareRepo.DeleteByScriptIDsAndUserID(ctx, []primitive.ObjectID{scriptID}, target.ID); err != nil {
+		return nil, fmt.Errorf("failed to clean up shares: %w", err)
 	}
 
-	// Check if target user exists
-	_, err = s.userRepo.FindByID(ctx, targetID)
+	return s.scriptRepo.FindByID(ctx, scriptID)
+}
+
+// ReassignScripts moves every script owned by one user to another user, e.g.
+// before the first user is deleted. Shares and revisions are kept as they are.
+func (s *ScriptService) ReassignScripts(ctx context.Context, fromUserID, toUserID primitive.ObjectID) (int64, error) {
+	var moved int64
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		scriptIDs, err := s.scriptRepo.FindIDsByOwnerID(ctx, fromUserID)
+		if err != nil {
+			return fmt.Errorf("failed to find owned scripts: %w", err)
+		}
+		if len(scriptIDs) == 0 {
+			moved = 0
+			return nil
+		}
+
+		moved, err = s.scriptRepo.ReassignOwner(ctx, fromUserID, toUserID)
+		if err != nil {
+			return fmt.Errorf("failed to reassign scripts: %w", err)
+		}
+
+		if err := s.scriptShareRepo.DeleteByScriptIDsAndUserID(ctx, scriptIDs, toUserID); err != nil {
+			return fmt.Errorf("failed to clean up shares: %w", err)
+		}
+		return nil
+	})
+
+	return moved, err
+}
+
+// RemoveUserData removes the shares, group memberships and notifications of
+// a user that is being deleted, hands the groups and folders they own to
+// newOwnerID and detaches their runs, which stay in the run history of the
+// scripts. Call it inside the transaction that deletes the user.
+func (s *ScriptService) RemoveUserData(ctx context.Context, userID, newOwnerID primitive.ObjectID) error {
+	if err := s.scriptShareRepo.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete shares: %w", err)
+	}
+	if err := s.groupRepo.RemoveMemberFromAll(ctx, userID); err != nil {
+		return fmt.Errorf("failed to remove group memberships: %w", err)
+	}
+	if err := s.groupRepo.ReassignOwner(ctx, userID, newOwnerID); err != nil {
+		return fmt.Errorf("failed to reassign groups: %w", err)
+	}
+	if err := s.folderRepo.ReassignOwner(ctx, userID, newOwnerID); err != nil {
+		return fmt.Errorf("failed to reassign folders: %w", err)
+	}
+	if err := s.processRepo.ClearUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to detach processes: %w", err)
+	}
+	if err := s.notificationRepo.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete notifications: %w", err)
+	}
+
+	return nil
+}
+
+// ShareScript grants a user or a group access to a script, or changes the
+// permission of an existing share. Sharing requires the manage permission.
+func (s *ScriptService) ShareScript(ctx context.Context, userID, scriptID primitive.ObjectID, req *models.ShareScriptRequest) error {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionManage)
 	if err != nil {
-		return errors.New("target user not found")
+		return err
 	}
 
-	// Check if script is already shared with user
-	_, err = s.scriptShareRepo.FindByScriptIDAndUserID(ctx, scriptID, targetID)
+	return s.share(ctx, script, req)
+}
+
+// BulkShare applies several share additions and removals on a script. Each
+// entry is applied independently and reported in the results.
+func (s *ScriptService) BulkShare(ctx context.Context, userID, scriptID primitive.ObjectID, req *models.BulkShareRequest) ([]models.BulkShareResult, error) {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionManage)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkShareResult, 0, len(req.Add)+len(req.Remove))
+	for i := range req.Add {
+		result := models.BulkShareResult{Action: "add", Target: req.Add[i].ShareTarget}
+		if err := s.share(ctx, script, &req.Add[i]); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	for _, target := range req.Remove {
+		result := models.BulkShareResult{Action: "remove", Target: target}
+		if err := s.revoke(ctx, script, target); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ListShares returns the users and groups a script is shared with.
+func (s *ScriptService) ListShares(ctx context.Context, userID, scriptID primitive.ObjectID) ([]models.ScriptShareInfo, error) {
+	if _, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionManage); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.scriptShareRepo.FindByScriptID(ctx, scriptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find shares: %w", err)
+	}
+
+	var userIDs, groupIDs []primitive.ObjectID
+	for _, share := range shares {
+		if !share.GroupID.IsZero() {
+			groupIDs = append(groupIDs, share.GroupID)
+		} else {
+			userIDs = append(userIDs, share.UserID)
+		}
+	}
+
+	users := make(map[primitive.ObjectID]*models.User, len(userIDs))
+	if len(userIDs) > 0 {
+		found, err := s.userRepo.FindByIDs(ctx, userIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find users: %w", err)
+		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	groups := make(map[primitive.ObjectID]*models.Group, len(groupIDs))
+	if len(groupIDs) > 0 {
+		found, err := s.groupRepo.FindByIDs(ctx, groupIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find groups: %w", err)
+		}
+		for _, group := range found {
+			groups[group.ID] = group
+		}
+	}
+
+	infos := make([]models.ScriptShareInfo, 0, len(shares))
+	for _, share := range shares {
+		info := models.ScriptShareInfo{
+			UserID:     share.UserID,
+			GroupID:    share.GroupID,
+			Permission: share.EffectivePermission(),
+			ExpiresAt:  share.ExpiresAt,
+			CreatedAt:  share.CreatedAt,
+		}
+		if user, ok := users[share.UserID]; ok {
+			info.Username = user.Username
+			info.Email = user.Email
+		}
+		if group, ok := groups[share.GroupID]; ok {
+			info.GroupName = group.Name
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (s *ScriptService) share(ctx context.Context, script *models.Script, req *models.ShareScriptRequest) error {
+	permission := req.Permission
+	if permission == "" {
+		permission = models.PermissionRun
+	}
+	if !permission.IsValid() {
+		return fmt.Errorf("invalid permission: %s", permission)
+	}
+
+	expiresAt, err := shareExpiry(req)
+	if err != nil {
+		return err
+	}
+
+	if req.GroupID != "" {
+		return s.shareWithGroup(ctx, script.ID, req.GroupID, permission, expiresAt)
+	}
+
+	target, err := s.resolveShareUser(ctx, req.ShareTarget)
+	if err != nil {
+		return err
+	}
+
+	if target.ID == script.OwnerID {
+		return errors.New("cannot share script with its owner")
+	}
+
+	// Change the permission if script is already shared with user
+	_, err = s.scriptShareRepo.FindByScriptIDAndUserID(ctx, script.ID, target.ID)
 	if err == nil {
-		return errors.New("script already shared with this user")
+		if err := s.scriptShareRepo.UpdatePermission(ctx, script.ID, target.ID, permission, expiresAt); err != nil {
+			return fmt.Errorf("failed to update share: %w", err)
+		}
+		return nil
 	}
 
 	// Create share record
 	share := &models.ScriptShare{
-		ScriptID: scriptID,
-		UserID:   targetID,
+		ScriptID:   script.ID,
+		UserID:     target.ID,
+		Permission: permission,
+		ExpiresAt:  expiresAt,
 	}
 
 	if err := s.scriptShareRepo.Create(ctx, share); err != nil {
@@ -189,15 +698,128 @@ func (s *ScriptService) ShareScript(ctx context.Context, ownerID, scriptID primi
 	return nil
 }
 
-func (s *ScriptService) RevokeShare(ctx context.Context, ownerID, scriptID primitive.ObjectID, targetUserID string) error {
-	script, err := s.scriptRepo.FindByID(ctx, scriptID)
+func (s *ScriptService) revoke(ctx context.Context, script *models.Script, target models.ShareTarget) error {
+	if target.GroupID != "" {
+		groupID, err := primitive.ObjectIDFromHex(target.GroupID)
+		if err != nil {
+			return errors.New("invalid group ID format")
+		}
+		if err := s.scriptShareRepo.DeleteGroupShare(ctx, script.ID, groupID); err != nil {
+			return fmt.Errorf("failed to revoke share: %w", err)
+		}
+		return nil
+	}
+
+	user, err := s.resolveShareUser(ctx, target)
 	if err != nil {
-		return fmt.Errorf("failed to find script: %w", err)
+		return err
 	}
 
-	// Only owner can revoke share
-	if script.OwnerID != ownerID {
-		return errors.New("access denied: only owner can revoke share")
+	if err := s.scriptShareRepo.Delete(ctx, script.ID, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	return nil
+}
+
+// shareExpiry returns the expiry requested for a share, or nil for a permanent share.
+func shareExpiry(req *models.ShareScriptRequest) (*time.Time, error) {
+	if req.Duration != "" {
+		duration, err := utils.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid share duration: %s", req.Duration)
+		}
+		expiresAt := time.Now().Add(duration)
+		return &expiresAt, nil
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("share expiry must be in the future")
+	}
+
+	return req.ExpiresAt, nil
+}
+
+// resolveShareUser finds the user designated by a share target by ID, username or email.
+func (s *ScriptService) resolveShareUser(ctx context.Context, target models.ShareTarget) (*models.User, error) {
+	var user *models.User
+	var err error
+
+	switch {
+	case target.UserID != "":
+		targetID, parseErr := primitive.ObjectIDFromHex(target.UserID)
+		if parseErr != nil {
+			return nil, errors.New("invalid user ID format")
+		}
+		user, err = s.userRepo.FindByID(ctx, targetID)
+	case target.Username != "":
+		user, err = s.userRepo.FindByUsername(ctx, target.Username)
+	case target.Email != "":
+		user, err = s.userRepo.FindByEmail(ctx, strings.ToLower(strings.TrimSpace(target.Email)))
+	default:
+		return nil, errors.New("user_id, username, email or group_id is required")
+	}
+
+	if err != nil {
+		return nil, errors.New("target user not found")
+	}
+
+	return user, nil
+}
+
+func (s *ScriptService) shareWithGroup(ctx context.Context, scriptID primitive.ObjectID, groupID string, permission models.SharePermission, expiresAt *time.Time) error {
+	targetID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return errors.New("invalid group ID format")
+	}
+
+	// Check if target group exists
+	if _, err := s.groupRepo.FindByID(ctx, targetID); err != nil {
+		return errors.New("target group not found")
+	}
+
+	// Change the permission if script is already shared with group
+	if _, err := s.scriptShareRepo.FindByScriptIDAndGroupID(ctx, scriptID, targetID); err == nil {
+		if err := s.scriptShareRepo.UpdateGroupPermission(ctx, scriptID, targetID, permission, expiresAt); err != nil {
+			return fmt.Errorf("failed to update share: %w", err)
+		}
+		return nil
+	}
+
+	share := &models.ScriptShare{
+		ScriptID:   scriptID,
+		GroupID:    targetID,
+		Permission: permission,
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := s.scriptShareRepo.Create(ctx, share); err != nil {
+		return fmt.Errorf("failed to share script: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ScriptService) RevokeGroupShare(ctx context.Context, userID, scriptID primitive.ObjectID, groupID string) error {
+	if _, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionManage); err != nil {
+		return err
+	}
+
+	targetID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return errors.New("invalid group ID format")
+	}
+
+	if err := s.scriptShareRepo.DeleteGroupShare(ctx, scriptID, targetID); err != nil {
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ScriptService) RevokeShare(ctx context.Context, userID, scriptID primitive.ObjectID, targetUserID string) error {
+	if _, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionManage); err != nil {
+		return err
 	}
 
 	// Convert target user ID from string to ObjectID
@@ -213,3 +835,449 @@ func (s *ScriptService) RevokeShare(ctx context.Context, ownerID, scriptID primi
 
 	return nil
 }
+
+func (s *ScriptService) ListFiles(ctx context.Context, userID, scriptID primitive.ObjectID) ([]models.ScriptFile, error) {
+	script, err := s.GetScriptByID(ctx, userID, scriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	return script.FileTree(), nil
+}
+
+func (s *ScriptService) GetFile(ctx context.Context, userID, scriptID primitive.ObjectID, filePath string) (*models.ScriptFile, error) {
+	script, err := s.GetScriptByID(ctx, userID, scriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath, err = normalizeFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateLegacyContent(script)
+	file := script.FindFile(filePath)
+	if file == nil {
+		return nil, fmt.Errorf("file %s not found", filePath)
+	}
+
+	return file, nil
+}
+
+func (s *ScriptService) WriteFile(ctx context.Context, userID, scriptID primitive.ObjectID, filePath string, req *models.WriteScriptFileRequest) (*models.ScriptFile, error) {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath, err = normalizeFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := legacyBaseline(script)
+	migrateLegacyContent(script)
+	setFile(script, filePath, req.Content)
+
+	if req.Strict {
+		if err := ensureCompiles(ctx, script); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveRevision(ctx, script, baseline, userID, 0); err != nil {
+		return nil, err
+	}
+
+	return script.FindFile(filePath), nil
+}
+
+func (s *ScriptService) DeleteFile(ctx context.Context, userID, scriptID primitive.ObjectID, filePath string) error {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionEdit)
+	if err != nil {
+		return err
+	}
+
+	filePath, err = normalizeFilePath(filePath)
+	if err != nil {
+		return err
+	}
+
+	baseline := legacyBaseline(script)
+	migrateLegacyContent(script)
+	if filePath == script.EntrypointPath() {
+		return errors.New("cannot delete the entrypoint file")
+	}
+
+	files := make([]models.ScriptFile, 0, len(script.Files))
+	for _, file := range script.Files {
+		if file.Path != filePath {
+			files = append(files, file)
+		}
+	}
+	if len(files) == len(script.Files) {
+		return fmt.Errorf("file %s not found", filePath)
+	}
+	script.Files = files
+
+	return s.saveRevision(ctx, script, baseline, userID, 0)
+}
+
+func (s *ScriptService) ListRevisions(ctx context.Context, userID, scriptID primitive.ObjectID) ([]*models.ScriptRevision, error) {
+	script, err := s.GetScriptByID(ctx, userID, scriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.scriptRevisionRepo.FindByScriptID(ctx, scriptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find revisions: %w", err)
+	}
+
+	// Scripts created before version history only have their current content
+	if len(revisions) == 0 && script.Revision == 0 {
+		baseline := baselineRevision(script)
+		baseline.Files = nil
+		revisions = append(revisions, baseline)
+	}
+
+	return revisions, nil
+}
+
+func (s *ScriptService) GetRevision(ctx context.Context, userID, scriptID primitive.ObjectID, revision int) (*models.ScriptRevision, error) {
+	script, err := s.GetScriptByID(ctx, userID, scriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.findRevision(ctx, script, revision)
+}
+
+// DiffRevisions compares two revisions of a script file by file.
+func (s *ScriptService) DiffRevisions(ctx context.Context, userID, scriptID primitive.ObjectID, from, to int) (*models.RevisionDiff, error) {
+	script, err := s.GetScriptByID(ctx, userID, scriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	fromRev, err := s.findRevision(ctx, script, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.findRevision(ctx, script, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromFiles := make(map[string]string, len(fromRev.Files))
+	for _, file := range fromRev.Files {
+		fromFiles[file.Path] = file.Content
+	}
+	toFiles := make(map[string]string, len(toRev.Files))
+	for _, file := range toRev.Files {
+		toFiles[file.Path] = file.Content
+	}
+
+	paths := make([]string, 0, len(fromFiles)+len(toFiles))
+	for p := range fromFiles {
+		paths = append(paths, p)
+	}
+	for p := range toFiles {
+		if _, ok := fromFiles[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	diff := &models.RevisionDiff{
+		From:           from,
+		To:             to,
+		EntrypointFrom: fromRev.Entrypoint,
+		EntrypointTo:   toRev.Entrypoint,
+		Files:          []models.FileDiff{},
+	}
+	for _, p := range paths {
+		oldContent, inFrom := fromFiles[p]
+		newContent, inTo := toFiles[p]
+
+		status := models.FileDiffModified
+		switch {
+		case !inFrom:
+			status = models.FileDiffAdded
+		case !inTo:
+			status = models.FileDiffRemoved
+		case oldContent == newContent:
+			continue
+		}
+
+		diff.Files = append(diff.Files, models.FileDiff{
+			Path:   p,
+			Status: status,
+			Diff:   utils.UnifiedDiff(fmt.Sprintf("a/%s@%d", p, from), fmt.Sprintf("b/%s@%d", p, to), oldContent, newContent),
+		})
+	}
+
+	return diff, nil
+}
+
+// RestoreRevision makes the content of an old revision current again by
+// recording it as a new revision, so the history stays append-only.
+func (s *ScriptService) RestoreRevision(ctx context.Context, userID, scriptID primitive.ObjectID, revision int) (*models.Script, error) {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := s.findRevision(ctx, script, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := legacyBaseline(script)
+	migrateLegacyContent(script)
+	script.Files = rev.Files
+	script.Entrypoint = rev.Entrypoint
+	script.Type = rev.Type
+
+	if err := s.saveRevision(ctx, script, baseline, userID, revision); err != nil {
+		return nil, err
+	}
+
+	return script, nil
+}
+
+func (s *ScriptService) findRevision(ctx context.Context, script *models.Script, revision int) (*models.ScriptRevision, error) {
+	rev, err := s.scriptRevisionRepo.FindByScriptIDAndRevision(ctx, script.ID, revision)
+	if err == nil {
+		return rev, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to find revision: %w", err)
+	}
+
+	// The current content of a script without recorded history acts as its first revision
+	if script.Revision == 0 && revision == 1 {
+		return baselineRevision(script), nil
+	}
+
+	return nil, fmt.Errorf("revision %d not found", revision)
+}
+
+// saveRevision bumps the revision of script, records its content and persists
+// it. The update and the revisions are written in one transaction, so a script
+// never points at a revision that was not recorded.
+func (s *ScriptService) saveRevision(ctx context.Context, script *models.Script, baseline *models.ScriptRevision, authorID primitive.ObjectID, restoredFrom int) error {
+	revision, version, updatedAt := script.Revision, script.Version, script.UpdatedAt
+	if baseline != nil {
+		revision = baseline.Revision
+	}
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		// A retried transaction starts over from the loaded script
+		script.Revision, script.Version, script.UpdatedAt = revision+1, version, updatedAt
+		if err := s.updateScript(ctx, script); err != nil {
+			return err
+		}
+
+		if baseline != nil {
+			// Revision 1 may be left over from an edit that failed after
+			// recording it on a server without transactions. A failed insert
+			// would abort the transaction, so look it up first.
+			_, err := s.scriptRevisionRepo.FindByScriptIDAndRevision(ctx, script.ID, baseline.Revision)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				err = s.scriptRevisionRepo.Create(ctx, baseline)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to record revision: %w", err)
+			}
+		}
+
+		if err := s.scriptRevisionRepo.Create(ctx, newRevision(script, authorID, restoredFrom)); err != nil {
+			return fmt.Errorf("failed to record revision: %w", err)
+		}
+		return nil
+	})
+}
+
+// updateScript persists script and reports a lost version check as a ScriptConflictError.
+func (s *ScriptService) updateScript(ctx context.Context, script *models.Script) error {
+	err := s.scriptRepo.Update(ctx, script)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, repository.ErrVersionConflict) {
+		return fmt.Errorf("failed to update script: %w", err)
+	}
+
+	current, findErr := s.scriptRepo.FindByID(ctx, script.ID)
+	if findErr != nil {
+		return fmt.Errorf("failed to find script: %w", findErr)
+	}
+	return &ScriptConflictError{Current: current}
+}
+
+// legacyBaseline returns the content of a script created before version
+// history as its revision 1, or nil when the script already has history.
+func legacyBaseline(script *models.Script) *models.ScriptRevision {
+	if script.Revision > 0 {
+		return nil
+	}
+	return baselineRevision(script)
+}
+
+// baselineRevision describes the content of a script without recorded history as revision 1.
+func baselineRevision(script *models.Script) *models.ScriptRevision {
+	baseline := newRevision(script, script.OwnerID, 0)
+	baseline.Revision = 1
+	baseline.CreatedAt = script.UpdatedAt
+	return baseline
+}
+
+func newRevision(script *models.Script, authorID primitive.ObjectID, restoredFrom int) *models.ScriptRevision {
+	return &models.ScriptRevision{
+		ScriptID:     script.ID,
+		Revision:     script.Revision,
+		Files:        append([]models.ScriptFile(nil), script.FileTree()...),
+		Entrypoint:   script.EntrypointPath(),
+		Type:         script.Type,
+		AuthorID:     authorID,
+		RestoredFrom: restoredFrom,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// sameContent reports whether script still has the content captured in revision.
+func sameContent(revision *models.ScriptRevision, script *models.Script) bool {
+	if revision.Entrypoint != script.EntrypointPath() || revision.Type != script.Type {
+		return false
+	}
+
+	files := script.FileTree()
+	if len(revision.Files) != len(files) {
+		return false
+	}
+	for i := range files {
+		if revision.Files[i].Path != files[i].Path || revision.Files[i].Content != files[i].Content {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckScript validates the stored files of a script with the linters of its
+// runtime. go vet builds the script, so edit permission is required.
+func (s *ScriptService) CheckScript(ctx context.Context, userID, scriptID primitive.ObjectID) (*models.CheckResult, error) {
+	script, err := s.AuthorizeScript(ctx, userID, scriptID, models.PermissionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkScript(ctx, script)
+}
+
+// ensureCompiles rejects a strict save whose files have compile errors.
+func ensureCompiles(ctx context.Context, script *models.Script) error {
+	result, err := checkScript(ctx, script)
+	if err != nil {
+		return fmt.Errorf("failed to check script: %w", err)
+	}
+	if !result.Valid {
+		return &ScriptCheckError{Result: result}
+	}
+	return nil
+}
+
+// buildFileTree turns a create request into the stored file tree. A request
+// with only content produces a single file at the default entrypoint.
+func buildFileTree(req *models.CreateScriptRequest) ([]models.ScriptFile, string, error) {
+	now := time.Now()
+
+	entrypoint := models.DefaultEntrypoint(req.Type)
+	if req.Entrypoint != "" {
+		var err error
+		if entrypoint, err = normalizeFilePath(req.Entrypoint); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(req.Files) == 0 {
+		if req.Content == "" {
+			return nil, "", errors.New("content or files is required")
+		}
+		return []models.ScriptFile{{Path: entrypoint, Content: req.Content, UpdatedAt: now}}, entrypoint, nil
+	}
+
+	files := make([]models.ScriptFile, 0, len(req.Files))
+	seen := make(map[string]bool, len(req.Files))
+	for _, input := range req.Files {
+		filePath, err := normalizeFilePath(input.Path)
+		if err != nil {
+			return nil, "", err
+		}
+		if seen[filePath] {
+			return nil, "", fmt.Errorf("duplicate file path: %s", filePath)
+		}
+		seen[filePath] = true
+		files = append(files, models.ScriptFile{Path: filePath, Content: input.Content, UpdatedAt: now})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	if !seen[entrypoint] {
+		return nil, "", fmt.Errorf("entrypoint %s does not exist", entrypoint)
+	}
+
+	return files, entrypoint, nil
+}
+
+// migrateLegacyContent moves the single content string of an old script into its file tree.
+func migrateLegacyContent(script *models.Script) {
+	if len(script.Files) == 0 && script.Content != "" {
+		script.Files = script.FileTree()
+		script.Entrypoint = script.EntrypointPath()
+	}
+	script.Content = ""
+}
+
+// setFile creates or replaces the file at filePath, keeping files ordered by path.
+func setFile(script *models.Script, filePath, content string) {
+	if file := script.FindFile(filePath); file != nil {
+		file.Content = content
+		file.UpdatedAt = time.Now()
+		return
+	}
+
+	script.Files = append(script.Files, models.ScriptFile{Path: filePath, Content: content, UpdatedAt: time.Now()})
+	sort.Slice(script.Files, func(i, j int) bool { return script.Files[i].Path < script.Files[j].Path })
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// normalizeFilePath cleans a slash-separated path and rejects paths escaping the script root.
+func normalizeFilePath(filePath string) (string, error) {
+	filePath = strings.TrimSpace(strings.ReplaceAll(filePath, "\\", "/"))
+	if filePath == "" {
+		return "", errors.New("file path is required")
+	}
+
+	cleaned := path.Clean(filePath)
+	if path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid file path: %s", filePath)
+	}
+
+	return cleaned, nil
+}
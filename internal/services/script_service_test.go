@@ -0,0 +1,448 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestNormalizeFilePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "main.py", want: "main.py"},
+		{path: " lib/util.py ", want: "lib/util.py"},
+		{path: `lib\util.py`, want: "lib/util.py"},
+		{path: "lib/../main.py", want: "main.py"},
+		{path: "./lib//util.py", want: "lib/util.py"},
+		{path: "", wantErr: true},
+		{path: ".", wantErr: true},
+		{path: "..", wantErr: true},
+		{path: "../etc/passwd", wantErr: true},
+		{path: "lib/../../etc/passwd", wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+		{path: `\etc\passwd`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeFilePath(tt.path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("normalizeFilePath(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeFilePath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildFileTree(t *testing.T) {
+	files, entrypoint, err := buildFileTree(&models.CreateScriptRequest{Type: models.ScriptTypeGolang, Content: "package main"})
+	if err != nil {
+		t.Fatalf("buildFileTree with content: %v", err)
+	}
+	if entrypoint != "main.go" || len(files) != 1 || files[0].Path != "main.go" {
+		t.Errorf("buildFileTree with content = %+v, %q", files, entrypoint)
+	}
+
+	files, entrypoint, err = buildFileTree(&models.CreateScriptRequest{
+		Type:       models.ScriptTypePython,
+		Entrypoint: "./src/app.py",
+		Files: []models.ScriptFileInput{
+			{Path: "src/app.py", Content: "import util"},
+			{Path: "src/util.py"},
+			{Path: "README.md"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildFileTree with files: %v", err)
+	}
+	if entrypoint != "src/app.py" {
+		t.Errorf("entrypoint = %q", entrypoint)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	if len(paths) != 3 || paths[0] != "README.md" || paths[1] != "src/app.py" || paths[2] != "src/util.py" {
+		t.Errorf("files are not sorted by path: %v", paths)
+	}
+
+	invalid := []*models.CreateScriptRequest{
+		{Type: models.ScriptTypePython},
+		{Type: models.ScriptTypePython, Files: []models.ScriptFileInput{{Path: "main.py"}, {Path: "./main.py"}}},
+		{Type: models.ScriptTypePython, Files: []models.ScriptFileInput{{Path: "util.py"}}},
+		{Type: models.ScriptTypePython, Files: []models.ScriptFileInput{{Path: "../main.py"}}},
+		{Type: models.ScriptTypePython, Entrypoint: "/main.py", Content: "print()"},
+	}
+	for _, req := range invalid {
+		if _, _, err := buildFileTree(req); err == nil {
+			t.Errorf("buildFileTree(%+v) succeeded", req)
+		}
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	script := &models.Script{Files: []models.ScriptFile{{Path: "main.py", Content: "old"}}}
+
+	setFile(script, "lib/util.py", "x = 1")
+	setFile(script, "main.py", "new")
+	setFile(script, "a.py", "")
+
+	if len(script.Files) != 3 {
+		t.Fatalf("files = %+v", script.Files)
+	}
+	if script.Files[0].Path != "a.py" || script.Files[1].Path != "lib/util.py" || script.Files[2].Path != "main.py" {
+		t.Errorf("files are not sorted by path: %+v", script.Files)
+	}
+	if script.Files[2].Content != "new" {
+		t.Errorf("main.py was not replaced: %q", script.Files[2].Content)
+	}
+}
+
+func TestMigrateLegacyContent(t *testing.T) {
+	script := &models.Script{Type: models.ScriptTypePython, Content: "print()"}
+	migrateLegacyContent(script)
+
+	if script.Content != "" || script.Entrypoint != "main.py" {
+		t.Errorf("migrated script = %+v", script)
+	}
+	if len(script.Files) != 1 || script.Files[0].Path != "main.py" || script.Files[0].Content != "print()" {
+		t.Errorf("migrated files = %+v", script.Files)
+	}
+}
+
+func TestNewRevision(t *testing.T) {
+	script := &models.Script{
+		ID:       primitive.NewObjectID(),
+		OwnerID:  primitive.NewObjectID(),
+		Type:     models.ScriptTypePython,
+		Files:    []models.ScriptFile{{Path: "main.py", Content: "print()"}},
+		Revision: 4,
+	}
+	author := primitive.NewObjectID()
+
+	revision := newRevision(script, author, 2)
+	if revision.ScriptID != script.ID || revision.Revision != 4 || revision.AuthorID != author || revision.RestoredFrom != 2 {
+		t.Errorf("newRevision = %+v", revision)
+	}
+	if revision.Entrypoint != "main.py" {
+		t.Errorf("entrypoint = %q, want the default entrypoint", revision.Entrypoint)
+	}
+
+	// The revision is a snapshot, later edits to the script do not change it
+	script.Files[0].Content = "changed"
+	setFile(script, "lib.py", "")
+	if len(revision.Files) != 1 || revision.Files[0].Content != "print()" {
+		t.Errorf("revision files changed with the script: %+v", revision.Files)
+	}
+}
+
+func TestBaselineRevision(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	script := &models.Script{
+		ID:        primitive.NewObjectID(),
+		OwnerID:   primitive.NewObjectID(),
+		Type:      models.ScriptTypePython,
+		Content:   "print()",
+		UpdatedAt: updatedAt,
+	}
+
+	baseline := baselineRevision(script)
+	if baseline.Revision != 1 || baseline.AuthorID != script.OwnerID || !baseline.CreatedAt.Equal(updatedAt) {
+		t.Errorf("baselineRevision = %+v", baseline)
+	}
+	if len(baseline.Files) != 1 || baseline.Files[0].Content != "print()" {
+		t.Errorf("baseline files = %+v, want the legacy content", baseline.Files)
+	}
+}
+
+func TestSameContent(t *testing.T) {
+	script := &models.Script{
+		Type:  models.ScriptTypePython,
+		Files: []models.ScriptFile{{Path: "lib.py", Content: "x = 1"}, {Path: "main.py", Content: "print()"}},
+	}
+	revision := newRevision(script, primitive.NilObjectID, 0)
+	if !sameContent(revision, script) {
+		t.Error("fresh revision differs from its script")
+	}
+
+	changes := []func(*models.Script){
+		func(s *models.Script) { s.Files[0].Content = "x = 2" },
+		func(s *models.Script) { s.Files[0].Path = "util.py" },
+		func(s *models.Script) { s.Files = s.Files[:1] },
+		func(s *models.Script) { s.Entrypoint = "lib.py" },
+		func(s *models.Script) { s.Type = models.ScriptTypeGolang },
+	}
+	for i, change := range changes {
+		changed := *script
+		changed.Files = append([]models.ScriptFile(nil), script.Files...)
+		change(&changed)
+		if sameContent(revision, &changed) {
+			t.Errorf("change %d was not detected", i)
+		}
+	}
+}
+
+func TestResolveShareUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	targets := []struct {
+		name   string
+		target models.ShareTarget
+		field  string
+		value  string
+	}{
+		{"username", models.ShareTarget{Username: "alice"}, "username", "alice"},
+		{"email", models.ShareTarget{Email: " Alice@Example.com "}, "email", "alice@example.com"},
+	}
+	for _, tt := range targets {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			service := &ScriptService{userRepo: repository.NewUserRepository(mt.DB)}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "username", Value: "alice"},
+			}))
+
+			user, err := service.resolveShareUser(context.Background(), tt.target)
+			if err != nil || user.Username != "alice" {
+				t.Fatalf("resolveShareUser = %v, %v", user, err)
+			}
+			filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+			if got := filter.Lookup(tt.field).StringValue(); got != tt.value {
+				t.Errorf("%s filter = %q, want %q", tt.field, got, tt.value)
+			}
+		})
+	}
+
+	mt.Run("unknown user", func(mt *mtest.T) {
+		service := &ScriptService{userRepo: repository.NewUserRepository(mt.DB)}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		if _, err := service.resolveShareUser(context.Background(), models.ShareTarget{Username: "bob"}); err == nil {
+			t.Error("resolveShareUser found an unknown user")
+		}
+	})
+
+	mt.Run("no target", func(mt *mtest.T) {
+		service := &ScriptService{userRepo: repository.NewUserRepository(mt.DB)}
+		if _, err := service.resolveShareUser(context.Background(), models.ShareTarget{}); err == nil {
+			t.Error("resolveShareUser accepted an empty target")
+		}
+		if _, err := service.resolveShareUser(context.Background(), models.ShareTarget{UserID: "nope"}); err == nil {
+			t.Error("resolveShareUser accepted an invalid ID")
+		}
+	})
+}
+
+func TestShareExpiry(t *testing.T) {
+	expiresAt, err := shareExpiry(&models.ShareScriptRequest{Duration: "7d"})
+	if err != nil {
+		t.Fatalf("shareExpiry with a duration: %v", err)
+	}
+	if until := time.Until(*expiresAt); until < 7*24*time.Hour-time.Minute || until > 7*24*time.Hour {
+		t.Errorf("share expires in %v, want 7 days", until)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if expiresAt, err := shareExpiry(&models.ShareScriptRequest{ExpiresAt: &future}); err != nil || !expiresAt.Equal(future) {
+		t.Errorf("shareExpiry with a date = %v, %v", expiresAt, err)
+	}
+
+	if expiresAt, err := shareExpiry(&models.ShareScriptRequest{}); err != nil || expiresAt != nil {
+		t.Errorf("shareExpiry without expiry = %v, %v", expiresAt, err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	invalid := []*models.ShareScriptRequest{
+		{Duration: "soon"},
+		{Duration: "-1h"},
+		{Duration: "0d"},
+		{ExpiresAt: &past},
+	}
+	for _, req := range invalid {
+		if _, err := shareExpiry(req); err == nil {
+			t.Errorf("shareExpiry(%+v) succeeded", req)
+		}
+	}
+}
+
+func TestTransferScriptRequiresOwnerOrAdmin(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("other member", func(mt *mtest.T) {
+		service := &ScriptService{scriptRepo: repository.NewScriptRepository(mt.DB)}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "owner_id", Value: primitive.NewObjectID()},
+		}))
+
+		claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleMember)}
+		_, err := service.TransferScript(context.Background(), claims, primitive.NewObjectID(), &models.TransferScriptRequest{Username: "bob"})
+		if err == nil {
+			t.Fatal("a member transferred a script they do not own")
+		}
+		if len(mt.GetAllStartedEvents()) != 1 {
+			t.Errorf("TransferScript kept querying after denying access")
+		}
+	})
+}
+
+func TestReassignScriptsWithoutScripts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("nothing to move", func(mt *mtest.T) {
+		service := &ScriptService{
+			scriptRepo: repository.NewScriptRepository(mt.DB),
+			transactor: repository.NewTransactor(mt.DB),
+		}
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}},
+			mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch),
+		)
+
+		moved, err := service.ReassignScripts(context.Background(), primitive.NewObjectID(), primitive.NewObjectID())
+		if err != nil || moved != 0 {
+			t.Fatalf("ReassignScripts = %d, %v", moved, err)
+		}
+	})
+}
+
+func TestSaveRevision(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	updated := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}
+	inserted := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}}
+
+	newService := func(mt *mtest.T) *ScriptService {
+		return &ScriptService{
+			scriptRepo:         repository.NewScriptRepository(mt.DB),
+			scriptRevisionRepo: repository.NewScriptRevisionRepository(mt.DB),
+			transactor:         repository.NewTransactor(mt.DB),
+		}
+	}
+	legacyScript := func() *models.Script {
+		return &models.Script{ID: primitive.NewObjectID(), Version: 4, Files: []models.ScriptFile{{Path: "main.py", Content: "print(1)"}}}
+	}
+
+	mt.Run("in a transaction", func(mt *mtest.T) {
+		service := newService(mt)
+		script := legacyScript()
+		baseline := baselineRevision(script)
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "setName", Value: "rs0"}},
+			updated,
+			mtest.CreateCursorResponse(0, "db.script_revisions", mtest.FirstBatch),
+			inserted,
+			inserted,
+			bson.D{{Key: "ok", Value: 1}},
+		)
+
+		if err := service.saveRevision(context.Background(), script, baseline, primitive.NewObjectID(), 0); err != nil {
+			t.Fatalf("saveRevision: %v", err)
+		}
+		if script.Revision != 2 || script.Version != 5 {
+			t.Errorf("revision %d version %d, want 2 and 5", script.Revision, script.Version)
+		}
+
+		var commands []string
+		for _, event := range mt.GetAllStartedEvents()[1:] {
+			commands = append(commands, event.CommandName)
+			if _, err := event.Command.LookupErr("txnNumber"); err != nil {
+				t.Errorf("%s ran outside the transaction", event.CommandName)
+			}
+		}
+		want := []string{"update", "find", "insert", "insert", "commitTransaction"}
+		if !reflect.DeepEqual(commands, want) {
+			t.Errorf("commands = %v, want %v", commands, want)
+		}
+	})
+
+	mt.Run("baseline already recorded", func(mt *mtest.T) {
+		service := newService(mt)
+		script := legacyScript()
+		baseline := baselineRevision(script)
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}},
+			updated,
+			mtest.CreateCursorResponse(0, "db.script_revisions", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "script_id", Value: script.ID},
+				{Key: "revision", Value: 1},
+			}),
+			inserted,
+		)
+
+		if err := service.saveRevision(context.Background(), script, baseline, primitive.NewObjectID(), 0); err != nil {
+			t.Fatalf("saveRevision: %v", err)
+		}
+		if inserts := len(mt.GetAllStartedEvents()) - 3; inserts != 1 {
+			t.Errorf("%d revisions inserted, want only the new one", inserts)
+		}
+	})
+}
+
+func TestCheckScriptRequiresEdit(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("viewer", func(mt *mtest.T) {
+		service := &ScriptService{
+			scriptRepo:      repository.NewScriptRepository(mt.DB),
+			scriptShareRepo: repository.NewScriptShareRepository(mt.DB),
+			groupRepo:       repository.NewGroupRepository(mt.DB),
+		}
+		scriptID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.scripts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: scriptID},
+				{Key: "owner_id", Value: primitive.NewObjectID()},
+				{Key: "type", Value: "golang"},
+			}),
+			mtest.CreateCursorResponse(0, "db.script_shares", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "script_id", Value: scriptID},
+				{Key: "user_id", Value: userID},
+				{Key: "permission", Value: string(models.PermissionView)},
+			}),
+			mtest.CreateCursorResponse(0, "db.groups", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "db.script_shares", mtest.FirstBatch),
+		)
+
+		if _, err := service.CheckScript(context.Background(), userID, scriptID); err == nil {
+			t.Error("a viewer ran the checks of a script")
+		}
+	})
+}
+
+func TestPurgeScriptRequiresAdmin(t *testing.T) {
+	service := &ScriptService{}
+	claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleMember)}
+
+	if err := service.PurgeScript(context.Background(), claims, primitive.NewObjectID()); err == nil {
+		t.Error("a member purged a script")
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" ops ", "", "ops", "Ops", "backup", "  "})
+	want := []string{"ops", "Ops", "backup"}
+	if len(got) != len(want) {
+		t.Fatalf("normalizeTags = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("normalizeTags = %q, want %q", got, want)
+		}
+	}
+}
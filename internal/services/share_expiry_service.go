@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// ShareExpiryService removes expired script shares in the background and
+// notifies the script owner about each of them.
+type ShareExpiryService struct {
+	scriptShareRepo     *repository.ScriptShareRepository
+	scriptRepo          *repository.ScriptRepository
+	userRepo            *repository.UserRepository
+	groupRepo           *repository.GroupRepository
+	notificationService *NotificationService
+	logger              *zap.Logger
+}
+
+func NewShareExpiryService(
+	scriptShareRepo *repository.ScriptShareRepository,
+	scriptRepo *repository.ScriptRepository,
+	userRepo *repository.UserRepository,
+	groupRepo *repository.GroupRepository,
+	notificationService *NotificationService,
+	logger *zap.Logger,
+) *ShareExpiryService {
+	return &ShareExpiryService{
+		scriptShareRepo:     scriptShareRepo,
+		scriptRepo:          scriptRepo,
+		userRepo:            userRepo,
+		groupRepo:           groupRepo,
+		notificationService: notificationService,
+		logger:              logger,
+	}
+}
+
+// Run sweeps expired shares every interval until ctx is cancelled.
+func (s *ShareExpiryService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := s.SweepExpired(ctx); err != nil {
+			s.logger.Error("Failed to sweep expired shares", zap.Error(err))
+		} else if removed > 0 {
+			s.logger.Info("Removed expired shares", zap.Int("count", removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepExpired deletes the shares that have expired and returns how many were removed.
+func (s *ShareExpiryService) SweepExpired(ctx context.Context) (int, error) {
+	shares, err := s.scriptShareRepo.FindExpired(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired shares: %w", err)
+	}
+
+	removed := 0
+	for _, share := range shares {
+		// Another replica may have removed the share already
+		if err := s.scriptShareRepo.DeleteByID(ctx, share.ID); err != nil {
+			continue
+		}
+		removed++
+
+		if err := s.notifyOwner(ctx, share); err != nil {
+			s.logger.Warn("Failed to notify owner about expired share",
+				zap.String("shareID", share.ID.Hex()), zap.Error(err))
+		}
+	}
+
+	return removed, nil
+}
+
+func (s *ShareExpiryService) notifyOwner(ctx context.Context, share *models.ScriptShare) error {
+	script, err := s.scriptRepo.FindByID(ctx, share.ScriptID)
+	if err != nil {
+		return err
+	}
+
+	grantee := "a deleted user"
+	if !share.GroupID.IsZero() {
+		grantee = "a deleted group"
+		if group, err := s.groupRepo.FindByID(ctx, share.GroupID); err == nil {
+			grantee = fmt.Sprintf("group %s", group.Name)
+		}
+	} else if user, err := s.userRepo.FindByID(ctx, share.UserID); err == nil {
+		grantee = user.Username
+	}
+
+	return s.notificationService.Notify(ctx, &models.Notification{
+		UserID:   script.OwnerID,
+		Type:     models.NotificationShareExpired,
+		Message:  fmt.Sprintf("The %s access of %s to script %q has expired", share.EffectivePermission(), grantee, script.Name),
+		ScriptID: script.ID,
+	})
+}
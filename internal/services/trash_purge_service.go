@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TrashPurgeService permanently deletes scripts that stayed in the trash
+// longer than the retention period.
+type TrashPurgeService struct {
+	scriptService *ScriptService
+	logger        *zap.Logger
+}
+
+func NewTrashPurgeService(scriptService *ScriptService, logger *zap.Logger) *TrashPurgeService {
+	return &TrashPurgeService{
+		scriptService: scriptService,
+		logger:        logger,
+	}
+}
+
+// Run purges expired trash every interval until ctx is cancelled.
+func (s *TrashPurgeService) Run(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.scriptService.PurgeTrash(ctx, time.Now().Add(-retention)); err != nil {
+			s.logger.Error("Failed to purge trashed scripts", zap.Error(err))
+		} else if purged > 0 {
+			s.logger.Info("Purged trashed scripts", zap.Int("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidMFAChallenge is returned when a login challenge is unknown,
+	// expired or out of attempts.
+	ErrInvalidMFAChallenge = errors.New("invalid or expired 2FA challenge")
+	// ErrInvalidTOTPCode is returned when a TOTP or recovery code is wrong
+	// or was already used.
+	ErrInvalidTOTPCode = errors.New("invalid authentication code")
+)
+
+const (
+	recoveryCodeCount  = 10
+	mfaChallengeTTL    = 5 * time.Minute
+	mfaChallengeMaxTry = 5
+)
+
+// TwoFactorService manages TOTP enrollment, recovery codes and the second
+// step of logins.
+type TwoFactorService struct {
+	userRepo         *repository.UserRepository
+	mfaChallengeRepo *repository.MFAChallengeRepository
+	settingsRepo     *repository.SettingsRepository
+	auditRepo        *repository.AuditRepository
+	providers        []AuthProvider
+	loginThrottle    *LoginThrottle
+	config           *config.Config
+	logger           *zap.Logger
+}
+
+func NewTwoFactorService(
+	userRepo *repository.UserRepository,
+	mfaChallengeRepo *repository.MFAChallengeRepository,
+	settingsRepo *repository.SettingsRepository,
+	auditRepo *repository.AuditRepository,
+	providers []AuthProvider,
+	loginThrottle *LoginThrottle,
+	config *config.Config,
+	logger *zap.Logger,
+) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:         userRepo,
+		mfaChallengeRepo: mfaChallengeRepo,
+		settingsRepo:     settingsRepo,
+		auditRepo:        auditRepo,
+		providers:        providers,
+		loginThrottle:    loginThrottle,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// Setup starts enrollment by generating a secret for the user. It only
+// becomes active once Enable confirms a code from it.
+func (s *TwoFactorService) Setup(ctx context.Context, userID primitive.ObjectID) (*models.TOTPSetup, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.ServiceAccount {
+		return nil, errors.New("service accounts cannot use 2FA")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("2FA is already enabled")
+	}
+
+	return s.setup(ctx, user)
+}
+
+// Enable confirms the pending secret with a code from it and returns the
+// recovery codes, which are only shown once.
+func (s *TwoFactorService) Enable(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("2FA is already enabled")
+	}
+	if user.TOTPPending == "" {
+		return nil, errors.New("2FA setup has not been started")
+	}
+
+	return s.enable(ctx, user, code)
+}
+
+// Disable turns 2FA off after checking the password and a current code. It
+// is refused while 2FA is required for the role of the user.
+func (s *TwoFactorService) Disable(ctx context.Context, userID primitive.ObjectID, req *models.DisableTOTPRequest) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !user.TOTPEnabled {
+		return errors.New("2FA is not enabled")
+	}
+
+	required, err := s.Required(ctx, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("2FA is required for your role")
+	}
+
+	if !checkPassword(ctx, s.providers, s.logger, user, req.Password) {
+		return errors.New("password is incorrect")
+	}
+	if err := s.Verify(ctx, user, req.Code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DisableTOTP(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to disable 2FA: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("2FA is not enabled")
+	}
+	if err := s.Verify(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// Reset removes the 2FA of a user who lost their device, following the
+// same hierarchy as UserService.UpdateUser. If 2FA is required the user
+// enrolls again at their next login.
+func (s *TwoFactorService) Reset(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID) error {
+	targetUser, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	currentRole := models.UserRole(currentUser.Role)
+	if currentRole == models.RoleAdmin {
+		if targetUser.Role == models.RoleAdmin || targetUser.Role == models.RoleRoot {
+			return errors.New("insufficient permissions")
+		}
+	}
+	if !targetUser.TOTPEnabled {
+		return errors.New("2FA is not enabled")
+	}
+
+	if err := s.userRepo.DisableTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to reset 2FA: %w", err)
+	}
+
+	entry := &models.AuditEntry{
+		Action:   models.AuditTOTPReset,
+		UserID:   targetUser.ID,
+		Username: targetUser.Username,
+		Message:  fmt.Sprintf("2FA of %s reset by %s", targetUser.Username, currentUser.Username),
+	}
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Required reports whether the user must use 2FA to log in.
+func (s *TwoFactorService) Required(ctx context.Context, user *models.User) (bool, error) {
+	if user.Role != models.RoleRoot && user.Role != models.RoleAdmin {
+		return false, nil
+	}
+
+	settings, err := s.settingsRepo.GetSecurity(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to load security settings: %w", err)
+	}
+	return settings.RequireAdmin2FA, nil
+}
+
+// Verify checks a TOTP code or a recovery code of a user with 2FA enabled.
+// Each code is accepted only once.
+func (s *TwoFactorService) Verify(ctx context.Context, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		err := s.userRepo.UseTOTPStep(ctx, user.ID, step)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrInvalidTOTPCode
+		}
+		if err != nil {
+			return fmt.Errorf("failed to use code: %w", err)
+		}
+		return nil
+	}
+
+	err := s.userRepo.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(code)))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrInvalidTOTPCode
+	}
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return nil
+}
+
+// StartChallenge creates the second login step of a user whose password was
+// checked. Users who must use 2FA but have not enrolled yet also get a new
+// secret to enroll with.
+func (s *TwoFactorService) StartChallenge(ctx context.Context, user *models.User) (string, *models.TOTPSetup, error) {
+	var setup *models.TOTPSetup
+	if !user.TOTPEnabled {
+		var err error
+		if setup, err = s.setup(ctx, user); err != nil {
+			return "", nil, err
+		}
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	challenge := &models.MFAChallenge{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.mfaChallengeRepo.Create(ctx, challenge); err != nil {
+		return "", nil, fmt.Errorf("failed to create 2FA challenge: %w", err)
+	}
+
+	return token, setup, nil
+}
+
+// CompleteChallenge checks the code of a login challenge sent from ip and
+// returns its user. Wrong codes count as failed logins of the user, like
+// wrong passwords. When the login also enrolled the user, their new recovery
+// codes are returned too.
+func (s *TwoFactorService) CompleteChallenge(ctx context.Context, token, code, ip string) (*models.User, []string, error) {
+	challenge, err := s.mfaChallengeRepo.CountAttempt(ctx, hashToken(token))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find 2FA challenge: %w", err)
+	}
+	if challenge.Attempts > mfaChallengeMaxTry {
+		if err := s.mfaChallengeRepo.Delete(ctx, challenge.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to delete 2FA challenge: %w", err)
+		}
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.userRepo.FindByID(ctx, challenge.UserID)
+	if err != nil || user.Disabled {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+	if err := s.loginThrottle.Check(ctx, user.Username, ip); err != nil {
+		return nil, nil, err
+	}
+
+	var recoveryCodes []string
+	if user.TOTPEnabled {
+		err = s.Verify(ctx, user, code)
+	} else {
+		recoveryCodes, err = s.enable(ctx, user, code)
+	}
+	if errors.Is(err, ErrInvalidTOTPCode) {
+		if err := s.loginThrottle.Failure(ctx, user.Username, ip, user.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidTOTPCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.loginThrottle.Success(ctx, user.Username); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.mfaChallengeRepo.Delete(ctx, challenge.ID); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete 2FA challenge: %w", err)
+	}
+	return user, recoveryCodes, nil
+}
+
+func (s *TwoFactorService) GetSecuritySettings(ctx context.Context) (*models.SecuritySettings, error) {
+	settings, err := s.settingsRepo.GetSecurity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load security settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *TwoFactorService) UpdateSecuritySettings(ctx context.Context, settings *models.SecuritySettings) (*models.SecuritySettings, error) {
+	if err := s.settingsRepo.UpdateSecurity(ctx, settings); err != nil {
+		return nil, fmt.Errorf("failed to update security settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *TwoFactorService) setup(ctx context.Context, user *models.User) (*models.TOTPSetup, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate 2FA secret: %w", err)
+	}
+	if err := s.userRepo.SetPendingTOTP(ctx, user.ID, secret); err != nil {
+		return nil, fmt.Errorf("failed to save 2FA secret: %w", err)
+	}
+
+	return &models.TOTPSetup{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.config.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// enable activates the pending secret of user once code matches it.
+func (s *TwoFactorService) enable(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.TOTPPending == "" {
+		return nil, ErrInvalidTOTPCode
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPPending, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.EnableTOTP(ctx, user.ID, user.TOTPPending, step, hashes); err != nil {
+		return nil, fmt.Errorf("failed to enable 2FA: %w", err)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCodes returns new recovery codes in the form xxxxx-xxxxx
+// along with the hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, 5)
+	for range recoveryCodeCount {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(buf)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.uber.org/zap"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("generateRecoveryCodes: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), recoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[0-9a-f]{5}-[0-9a-f]{5}$`)
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not in the form xxxxx-xxxxx", code)
+		}
+		if hashes[i] != hashToken(normalizeRecoveryCode(code)) {
+			t.Errorf("hash of %q does not match the normalized code", code)
+		}
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	if got := normalizeRecoveryCode(" AB12C-3d4E5 "); got != "ab12c3d4e5" {
+		t.Errorf("normalizeRecoveryCode = %q", got)
+	}
+}
+
+func TestCompleteChallenge(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newService := func(mt *mtest.T) *TwoFactorService {
+		cfg := &config.Config{LoginMaxAttempts: 5, LoginFailureReset: time.Hour}
+		throttle := NewLoginThrottle(repository.NewLoginAttemptRepository(mt.DB), repository.NewAuditRepository(mt.DB), cfg)
+		return NewTwoFactorService(
+			repository.NewUserRepository(mt.DB),
+			repository.NewMFAChallengeRepository(mt.DB),
+			repository.NewSettingsRepository(mt.DB),
+			repository.NewAuditRepository(mt.DB),
+			nil,
+			throttle,
+			cfg,
+			zap.NewNop(),
+		)
+	}
+	challengeAndUser := func(mt *mtest.T) primitive.ObjectID {
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "user_id", Value: userID},
+				{Key: "attempts", Value: 1},
+			}}},
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "username", Value: "Alice"},
+				{Key: "totp_enabled", Value: true},
+				{Key: "totp_secret", Value: secret},
+			}),
+			mtest.CreateCursorResponse(0, "db.login_attempts", mtest.FirstBatch),
+		)
+		return userID
+	}
+
+	mt.Run("valid code", func(mt *mtest.T) {
+		service := newService(mt)
+		userID := challengeAndUser(mt)
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		)
+
+		code, _ := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+		user, _, err := service.CompleteChallenge(context.Background(), "token", code, "10.0.0.1")
+		if err != nil {
+			t.Fatalf("CompleteChallenge: %v", err)
+		}
+		if user.ID != userID {
+			t.Errorf("user = %s, want %s", user.ID.Hex(), userID.Hex())
+		}
+
+		// Passing the challenge resets the failures of the account
+		reset := mt.GetAllStartedEvents()[4]
+		if reset.CommandName != "delete" || reset.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q", "_id").StringValue() != "user:alice" {
+			t.Errorf("account failures were not reset: %v", reset.Command)
+		}
+	})
+
+	mt.Run("wrong code counts as a failed login", func(mt *mtest.T) {
+		service := newService(mt)
+		challengeAndUser(mt)
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{
+				{Key: "_id", Value: "user:alice"},
+				{Key: "failures", Value: 1},
+			}}},
+		)
+
+		if _, _, err := service.CompleteChallenge(context.Background(), "token", "wrong-code", "10.0.0.1"); !errors.Is(err, ErrInvalidTOTPCode) {
+			t.Fatalf("CompleteChallenge = %v, want ErrInvalidTOTPCode", err)
+		}
+
+		events := mt.GetAllStartedEvents()
+		failure := events[len(events)-1]
+		if failure.CommandName != "findAndModify" || failure.Command.Lookup("query", "_id").StringValue() != "user:alice" {
+			t.Errorf("failure was not recorded for the account: %v", failure.Command)
+		}
+	})
+
+	mt.Run("locked account", func(mt *mtest.T) {
+		service := newService(mt)
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "user_id", Value: userID},
+				{Key: "attempts", Value: 1},
+			}}},
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "username", Value: "alice"},
+				{Key: "totp_enabled", Value: true},
+			}),
+			mtest.CreateCursorResponse(0, "db.login_attempts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "user:alice"},
+				{Key: "locked_until", Value: time.Now().Add(time.Minute)},
+			}),
+		)
+
+		var locked *LoginLockedError
+		if _, _, err := service.CompleteChallenge(context.Background(), "token", "123456", "10.0.0.1"); !errors.As(err, &locked) {
+			t.Fatalf("CompleteChallenge = %v, want LoginLockedError", err)
+		}
+	})
+}
+
+func TestDisableSkipsFailingProvider(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("unreachable directory", func(mt *mtest.T) {
+		ldap := &fakeProvider{name: "ldap", err: errors.New("connection refused")}
+		service := NewTwoFactorService(
+			repository.NewUserRepository(mt.DB),
+			repository.NewMFAChallengeRepository(mt.DB),
+			repository.NewSettingsRepository(mt.DB),
+			repository.NewAuditRepository(mt.DB),
+			[]AuthProvider{ldap},
+			nil,
+			&config.Config{},
+			zap.NewNop(),
+		)
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: userID},
+			{Key: "username", Value: "alice"},
+			{Key: "role", Value: "member"},
+			{Key: "totp_enabled", Value: true},
+		}))
+
+		err := service.Disable(context.Background(), userID, &models.DisableTOTPRequest{Password: "secret", Code: "123456"})
+		if err == nil || err.Error() != "password is incorrect" {
+			t.Errorf("Disable = %v, want password is incorrect", err)
+		}
+		if ldap.calls != 1 {
+			t.Errorf("provider was called %d times, want 1", ldap.calls)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/mail"
+	"strings"
 
 	"scripts-management/internal/config"
 	"scripts-management/internal/models"
@@ -15,13 +17,30 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// maxDisplayNameLength bounds the display name of a profile.
+const maxDisplayNameLength = 100
+
 type UserService struct {
-	userRepo    *repository.UserRepository
-	authService *AuthService
-	config      *config.Config
+	userRepo        *repository.UserRepository
+	authService     *AuthService
+	apiTokenService *APITokenService
+	scriptService   *ScriptService
+	processService  *ProcessService
+	loginThrottle   *LoginThrottle
+	transactor      *repository.Transactor
+	config          *config.Config
 }
 
-func NewUserService(userRepo *repository.UserRepository, config *config.Config, auth *AuthService) (*UserService, error) {
+func NewUserService(
+	userRepo *repository.UserRepository,
+	config *config.Config,
+	auth *AuthService,
+	apiTokenService *APITokenService,
+	scriptService *ScriptService,
+	processService *ProcessService,
+	loginThrottle *LoginThrottle,
+	transactor *repository.Transactor,
+) (*UserService, error) {
 	if userRepo == nil {
 		return nil, errors.New("userRepo cannot be nil")
 	}
@@ -31,11 +50,31 @@ func NewUserService(userRepo *repository.UserRepository, config *config.Config,
 	if auth == nil {
 		return nil, errors.New("auth service cannot be nil")
 	}
+	if apiTokenService == nil {
+		return nil, errors.New("api token service cannot be nil")
+	}
+	if scriptService == nil {
+		return nil, errors.New("script service cannot be nil")
+	}
+	if processService == nil {
+		return nil, errors.New("process service cannot be nil")
+	}
+	if loginThrottle == nil {
+		return nil, errors.New("login throttle cannot be nil")
+	}
+	if transactor == nil {
+		return nil, errors.New("transactor cannot be nil")
+	}
 
 	return &UserService{
-		userRepo:    userRepo,
-		config:      config,
-		authService: auth,
+		userRepo:        userRepo,
+		config:          config,
+		authService:     auth,
+		apiTokenService: apiTokenService,
+		scriptService:   scriptService,
+		processService:  processService,
+		loginThrottle:   loginThrottle,
+		transactor:      transactor,
 	}, nil
 }
 
@@ -53,6 +92,13 @@ func (s *UserService) InitRootAccount(ctx context.Context) error {
 		return nil
 	}
 
+	if s.config.RootPassword == "" {
+		return errors.New("ROOT_PASSWORD is required to create the root account")
+	}
+	if err := s.authService.ValidatePassword(s.config.RootPassword, s.config.RootUsername); err != nil {
+		return fmt.Errorf("ROOT_PASSWORD does not meet the password policy: %w", err)
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(s.config.RootPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash root password: %w", err)
@@ -78,14 +124,112 @@ func (s *UserService) CreateUser(ctx context.Context, currentUser *utils.JWTClai
 		return errors.New("insufficient permissions")
 	}
 
-	if currentRole == models.RoleAdmin && newUser.Role == models.RoleAdmin {
+	if currentRole == models.RoleAdmin && newUser.Role != "" && newUser.Role != models.RoleMember {
 		return errors.New("admin cannot create other admin accounts")
 	}
 
 	return s.authService.CreateUser(ctx, newUser)
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID) error {
+// CreateServiceAccount creates a non-interactive user. It has no password
+// and can only authenticate with API tokens created for it by an admin.
+func (s *UserService) CreateServiceAccount(ctx context.Context, currentUser *utils.JWTClaims, req *models.CreateServiceAccountRequest) (*models.User, error) {
+	currentRole := models.UserRole(currentUser.Role)
+
+	if currentRole != models.RoleRoot && currentRole != models.RoleAdmin {
+		return nil, errors.New("insufficient permissions")
+	}
+
+	if req.Username == "" {
+		return nil, errors.New("username is required")
+	}
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+	if req.Role != models.RoleMember && req.Role != models.RoleAdmin {
+		return nil, errors.New("invalid role")
+	}
+	if currentRole == models.RoleAdmin && req.Role == models.RoleAdmin {
+		return nil, errors.New("admin cannot create other admin accounts")
+	}
+
+	_, err := s.userRepo.FindByUsername(ctx, req.Username)
+	if err == nil {
+		return nil, errors.New("username already taken")
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+
+	user := &models.User{
+		Username:       req.Username,
+		Role:           req.Role,
+		ServiceAccount: true,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("username already taken")
+		}
+		return nil, fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser removes a user after reassigning their scripts and groups to
+// reassignTo. When reassignTo is zero they go to the user performing the
+// deletion. Running processes of the user are stopped and the rest of their
+// data is removed in the same transaction as the user.
+func (s *UserService) DeleteUser(ctx context.Context, currentUser *utils.JWTClaims, userID, reassignTo primitive.ObjectID) error {
+	if reassignTo.IsZero() {
+		reassignTo = currentUser.UserID
+	}
+	if err := s.authorizeReassign(ctx, currentUser, userID, reassignTo); err != nil {
+		return err
+	}
+
+	if err := s.processService.StopUserProcesses(ctx, userID); err != nil {
+		return err
+	}
+
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.scriptService.ReassignScripts(ctx, userID, reassignTo); err != nil {
+			return err
+		}
+		if err := s.scriptService.RemoveUserData(ctx, userID, reassignTo); err != nil {
+			return err
+		}
+		if err := s.userRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.apiTokenService.DeleteUserTokens(ctx, userID); err != nil {
+			return err
+		}
+		return s.authService.RevokeUserSessions(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.authService.forgetUserStatus(userID)
+	return nil
+}
+
+// ReassignScripts moves every script of a user to reassignTo without
+// deleting the user, e.g. when they leave a team. It returns the number of
+// scripts moved.
+func (s *UserService) ReassignScripts(ctx context.Context, currentUser *utils.JWTClaims, userID, reassignTo primitive.ObjectID) (int64, error) {
+	if err := s.authorizeReassign(ctx, currentUser, userID, reassignTo); err != nil {
+		return 0, err
+	}
+
+	return s.scriptService.ReassignScripts(ctx, userID, reassignTo)
+}
+
+// authorizeReassign checks that currentUser may manage userID, following
+// the same hierarchy as password changes, and that reassignTo is another
+// existing user.
+func (s *UserService) authorizeReassign(ctx context.Context, currentUser *utils.JWTClaims, userID, reassignTo primitive.ObjectID) error {
 	targetUser, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		return err
@@ -99,7 +243,183 @@ func (s *UserService) DeleteUser(ctx context.Context, currentUser *utils.JWTClai
 		}
 	}
 
-	return s.userRepo.Delete(ctx, userID)
+	if reassignTo == userID {
+		return errors.New("cannot reassign scripts to the same user")
+	}
+	if _, err := s.userRepo.FindByID(ctx, reassignTo); err != nil {
+		return errors.New("reassign target user not found")
+	}
+	return nil
+}
+
+// ListUsers returns a page of users matching filter.
+func (s *UserService) ListUsers(ctx context.Context, filter *models.UserFilter, sort models.UserSort, page models.PageRequest) (*models.UserPage, error) {
+	users, err := s.userRepo.List(ctx, filter, sort, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUser changes the role or disabled status of a user, following the
+// same hierarchy as DeleteUser. The sessions of the user are revoked so the
+// change applies immediately.
+func (s *UserService) UpdateUser(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID, req *models.UpdateUserRequest) (*models.User, error) {
+	targetUser, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	currentRole := models.UserRole(currentUser.Role)
+
+	if currentRole == models.RoleAdmin {
+		if targetUser.Role == models.RoleAdmin || targetUser.Role == models.RoleRoot {
+			return nil, errors.New("insufficient permissions")
+		}
+	}
+
+	if userID == currentUser.UserID {
+		return nil, errors.New("cannot change your own role or status")
+	}
+	if targetUser.Role == models.RoleRoot {
+		return nil, errors.New("root account cannot be modified")
+	}
+
+	if req.Role != nil {
+		if *req.Role != models.RoleMember && *req.Role != models.RoleAdmin {
+			return nil, errors.New("invalid role")
+		}
+		if currentRole == models.RoleAdmin && *req.Role == models.RoleAdmin {
+			return nil, errors.New("admin cannot grant the admin role")
+		}
+	}
+
+	if req.Role == nil && req.Disabled == nil {
+		return targetUser, nil
+	}
+
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateAccess(ctx, userID, req.Role, req.Disabled); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		// Access tokens carry the role, so open sessions must not keep the old one
+		return s.authService.RevokeUserSessions(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.authService.forgetUserStatus(userID)
+
+	return s.GetUser(ctx, userID)
+}
+
+// UpdateProfile changes the display name and email of the current user.
+// Emails are stored lowercase and must be unique.
+func (s *UserService) UpdateProfile(ctx context.Context, userID primitive.ObjectID, req *models.UpdateProfileRequest) (*models.User, error) {
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if len(displayName) > maxDisplayNameLength {
+			return nil, fmt.Errorf("display name cannot exceed %d characters", maxDisplayNameLength)
+		}
+		req.DisplayName = &displayName
+	}
+
+	if req.Email != nil {
+		email, err := normalizeEmail(ctx, s.userRepo, userID, *req.Email)
+		if err != nil {
+			return nil, err
+		}
+		req.Email = &email
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, userID, req.DisplayName, req.Email); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("email already in use")
+		}
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// normalizeEmail lowercases an email and checks that it is valid and not
+// used by another user than userID. An empty email is returned as is.
+func normalizeEmail(ctx context.Context, userRepo *repository.UserRepository, userID primitive.ObjectID, email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", nil
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", errors.New("invalid email address")
+	}
+
+	existing, err := userRepo.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("failed to check email: %w", err)
+	}
+	if existing != nil && existing.ID != userID {
+		return "", errors.New("email already in use")
+	}
+	return email, nil
+}
+
+// ChangeOwnPassword changes the password of the current user after checking
+// the current one. Every other session of the user is revoked.
+func (s *UserService) ChangeOwnPassword(ctx context.Context, currentUser *utils.JWTClaims, req *models.ChangeOwnPasswordRequest, ip string) error {
+	user, err := s.GetUser(ctx, currentUser.UserID)
+	if err != nil {
+		return err
+	}
+
+	if user.ServiceAccount {
+		return errors.New("service accounts have no password")
+	}
+	if user.AuthSource != "" {
+		return fmt.Errorf("password is managed by %s", user.AuthSource)
+	}
+
+	// Wrong current passwords count as failed logins, so a stolen session
+	// cannot be used to guess the password
+	if err := s.loginThrottle.Check(ctx, user.Username, ip); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		if err := s.loginThrottle.Failure(ctx, user.Username, ip, user.ID); err != nil {
+			return err
+		}
+		return errors.New("current password is incorrect")
+	}
+	if err := s.loginThrottle.Success(ctx, user.Username); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	if err := s.authService.ValidatePassword(req.NewPassword, user.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	return s.authService.RevokeOtherSessions(ctx, user.ID, currentUser.ID)
 }
 
 func (s *UserService) ChangePassword(ctx context.Context, currentUser *utils.JWTClaims, userID primitive.ObjectID, newPassword string) error {
@@ -116,10 +436,23 @@ func (s *UserService) ChangePassword(ctx context.Context, currentUser *utils.JWT
 		}
 	}
 
+	if targetUser.AuthSource != "" {
+		return fmt.Errorf("password is managed by %s", targetUser.AuthSource)
+	}
+
+	if err := s.authService.ValidatePassword(newPassword, targetUser.Username); err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
-	return s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword))
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// Sessions opened with the old password must not outlive it
+	return s.authService.RevokeUserSessions(ctx, userID)
 }
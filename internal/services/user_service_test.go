@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"scripts-management/internal/config"
+	"scripts-management/internal/models"
+	"scripts-management/internal/repository"
+	"scripts-management/pkg/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUpdateUserHierarchy(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	admin := models.RoleAdmin
+	member := models.RoleMember
+	root := models.RoleRoot
+
+	tests := []struct {
+		name   string
+		actor  models.UserRole
+		target models.UserRole
+		self   bool
+		req    models.UpdateUserRequest
+	}{
+		{"admin changing an admin", models.RoleAdmin, models.RoleAdmin, false, models.UpdateUserRequest{Role: &member}},
+		{"admin granting admin", models.RoleAdmin, models.RoleMember, false, models.UpdateUserRequest{Role: &admin}},
+		{"changing yourself", models.RoleAdmin, models.RoleMember, true, models.UpdateUserRequest{Role: &member}},
+		{"modifying root", models.RoleRoot, models.RoleRoot, false, models.UpdateUserRequest{Role: &member}},
+		{"granting root", models.RoleRoot, models.RoleMember, false, models.UpdateUserRequest{Role: &root}},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+			targetID := primitive.NewObjectID()
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: targetID},
+				{Key: "username", Value: "target"},
+				{Key: "role", Value: string(tt.target)},
+			}))
+
+			actorID := primitive.NewObjectID()
+			if tt.self {
+				actorID = targetID
+			}
+			claims := &utils.JWTClaims{UserID: actorID, Role: string(tt.actor)}
+			if _, err := service.UpdateUser(context.Background(), claims, targetID, &tt.req); err == nil {
+				t.Error("update was allowed")
+			}
+			if events := mt.GetAllStartedEvents(); len(events) != 1 {
+				t.Errorf("%d commands ran, want only the lookup", len(events))
+			}
+		})
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rejects invalid input", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		long := strings.Repeat("x", maxDisplayNameLength+1)
+		for _, email := range []string{"not-an-email", "Alice <alice@example.com>"} {
+			if _, err := service.UpdateProfile(context.Background(), primitive.NewObjectID(), &models.UpdateProfileRequest{Email: &email}); err == nil {
+				t.Errorf("email %q was accepted", email)
+			}
+		}
+		if _, err := service.UpdateProfile(context.Background(), primitive.NewObjectID(), &models.UpdateProfileRequest{DisplayName: &long}); err == nil {
+			t.Error("overlong display name was accepted")
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 0 {
+			t.Errorf("%d commands ran for invalid input", len(events))
+		}
+	})
+
+	mt.Run("email in use", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "email", Value: "alice@example.com"},
+		}))
+
+		email := " Alice@Example.com "
+		if _, err := service.UpdateProfile(context.Background(), primitive.NewObjectID(), &models.UpdateProfileRequest{Email: &email}); err == nil {
+			t.Fatal("email of another user was accepted")
+		}
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if got := filter.Lookup("email").StringValue(); got != "alice@example.com" {
+			t.Errorf("email lookup = %q, want it normalized", got)
+		}
+	})
+}
+
+func TestChangeOwnPasswordChecksCurrent(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("wrong current password counts as a failed login", func(mt *mtest.T) {
+		cfg := &config.Config{LoginMaxAttempts: 5, LoginFailureReset: time.Hour}
+		service := &UserService{
+			userRepo:      repository.NewUserRepository(mt.DB),
+			loginThrottle: NewLoginThrottle(repository.NewLoginAttemptRepository(mt.DB), repository.NewAuditRepository(mt.DB), cfg),
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "username", Value: "alice"},
+				{Key: "password", Value: string(hash)},
+			}),
+			mtest.CreateCursorResponse(0, "db.login_attempts", mtest.FirstBatch),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{
+				{Key: "_id", Value: "user:alice"},
+				{Key: "failures", Value: 1},
+			}}},
+		)
+
+		req := &models.ChangeOwnPasswordRequest{CurrentPassword: "guess", NewPassword: "new-secret"}
+		if err := service.ChangeOwnPassword(context.Background(), &utils.JWTClaims{UserID: userID}, req, "10.0.0.1"); err == nil {
+			t.Fatal("password changed without the current one")
+		}
+
+		events := mt.GetAllStartedEvents()
+		failure := events[len(events)-1]
+		if failure.CommandName != "findAndModify" || failure.Command.Lookup("query", "_id").StringValue() != "user:alice" {
+			t.Errorf("failure was not recorded for the account: %v", failure.Command)
+		}
+	})
+}
+
+func TestInitRootAccountPasswordPolicy(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	policy, err := NewPasswordPolicy(10, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"", "root123"} {
+		mt.Run("password "+password, func(mt *mtest.T) {
+			service := &UserService{
+				userRepo:    repository.NewUserRepository(mt.DB),
+				authService: &AuthService{passwordPolicy: policy},
+				config:      &config.Config{RootUsername: "root", RootPassword: password},
+			}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+			if err := service.InitRootAccount(context.Background()); err == nil {
+				t.Errorf("root account was created with password %q", password)
+			}
+			if events := mt.GetAllStartedEvents(); len(events) != 1 {
+				t.Errorf("%d commands ran, want only the lookup", len(events))
+			}
+		})
+	}
+}
+
+func TestAuthorizeReassign(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	userDoc := func(id primitive.ObjectID, role models.UserRole) bson.D {
+		return bson.D{{Key: "_id", Value: id}, {Key: "username", Value: "user"}, {Key: "role", Value: string(role)}}
+	}
+
+	mt.Run("admin reassigning an admin", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(userID, models.RoleAdmin)))
+
+		claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleAdmin)}
+		if err := service.authorizeReassign(context.Background(), claims, userID, primitive.NewObjectID()); err == nil {
+			t.Error("admin reassigned the scripts of an admin")
+		}
+	})
+
+	mt.Run("same user", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(userID, models.RoleMember)))
+
+		claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleRoot)}
+		if err := service.authorizeReassign(context.Background(), claims, userID, userID); err == nil {
+			t.Error("scripts were reassigned to their owner")
+		}
+	})
+
+	mt.Run("missing target", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(userID, models.RoleMember)),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch),
+		)
+
+		claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleAdmin)}
+		if err := service.authorizeReassign(context.Background(), claims, userID, primitive.NewObjectID()); err == nil {
+			t.Error("scripts were reassigned to a missing user")
+		}
+	})
+
+	mt.Run("allowed", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		userID, targetID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(userID, models.RoleMember)),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(targetID, models.RoleMember)),
+		)
+
+		claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleAdmin)}
+		if err := service.authorizeReassign(context.Background(), claims, userID, targetID); err != nil {
+			t.Errorf("authorizeReassign = %v", err)
+		}
+	})
+}
+
+func TestCreateServiceAccountUsernameTaken(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	claims := &utils.JWTClaims{UserID: primitive.NewObjectID(), Role: string(models.RoleRoot)}
+	req := func() *models.CreateServiceAccountRequest {
+		return &models.CreateServiceAccountRequest{Username: "deploy-bot"}
+	}
+
+	mt.Run("existing user", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "username", Value: "deploy-bot"},
+		}))
+
+		if _, err := service.CreateServiceAccount(context.Background(), claims, req()); err == nil {
+			t.Fatal("service account reused a taken username")
+		}
+		if events := mt.GetAllStartedEvents(); len(events) != 1 {
+			t.Errorf("%d commands ran, want only the lookup", len(events))
+		}
+	})
+
+	mt.Run("concurrent creation", func(mt *mtest.T) {
+		service := &UserService{userRepo: repository.NewUserRepository(mt.DB)}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+		)
+
+		_, err := service.CreateServiceAccount(context.Background(), claims, req())
+		if err == nil || err.Error() != "username already taken" {
+			t.Errorf("CreateServiceAccount = %v, want username already taken", err)
+		}
+	})
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("empty", func(mt *mtest.T) {
+		email, err := normalizeEmail(context.Background(), repository.NewUserRepository(mt.DB), primitive.NewObjectID(), "  ")
+		if err != nil || email != "" {
+			t.Errorf("normalizeEmail = %q, %v, want an empty email", email, err)
+		}
+	})
+
+	mt.Run("own email", func(mt *mtest.T) {
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: userID},
+			{Key: "email", Value: "alice@example.com"},
+		}))
+
+		email, err := normalizeEmail(context.Background(), repository.NewUserRepository(mt.DB), userID, "ALICE@example.com")
+		if err != nil || email != "alice@example.com" {
+			t.Errorf("normalizeEmail = %q, %v, want alice@example.com", email, err)
+		}
+	})
+}
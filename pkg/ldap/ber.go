@@ -0,0 +1,201 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tag classes and the constructed bit (X.690 section 8.1.2)
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags used by LDAP
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = constructed | 0x10
+	tagSet         = constructed | 0x11
+)
+
+// maxPacketSize bounds the length of a single message read from the server.
+const maxPacketSize = 16 << 20
+
+// packet is a decoded BER element. Constructed elements have children,
+// primitive ones a value.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func newPrimitive(tag byte, value []byte) *packet {
+	return &packet{tag: tag, value: value}
+}
+
+func newConstructed(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag | constructed, children: children}
+}
+
+func newString(tag byte, s string) *packet {
+	return newPrimitive(tag, []byte(s))
+}
+
+func newInteger(tag byte, n int64) *packet {
+	// Minimal two's complement, big endian
+	var buf []byte
+	for {
+		buf = append([]byte{byte(n)}, buf...)
+		n >>= 8
+		if (n == 0 && buf[0]&0x80 == 0) || (n == -1 && buf[0]&0x80 != 0) {
+			break
+		}
+	}
+	return newPrimitive(tag, buf)
+}
+
+func newBoolean(b bool) *packet {
+	if b {
+		return newPrimitive(tagBoolean, []byte{0xff})
+	}
+	return newPrimitive(tagBoolean, []byte{0x00})
+}
+
+func (p *packet) constructed() bool {
+	return p.tag&constructed != 0
+}
+
+func (p *packet) encode() []byte {
+	content := p.value
+	if p.constructed() {
+		content = nil
+		for _, child := range p.children {
+			content = append(content, child.encode()...)
+		}
+	}
+
+	out := []byte{p.tag}
+	out = append(out, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var buf []byte
+	for ; n > 0; n >>= 8 {
+		buf = append([]byte{byte(n)}, buf...)
+	}
+	return append([]byte{0x80 | byte(len(buf))}, buf...)
+}
+
+func (p *packet) int() (int64, error) {
+	if p.constructed() || len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errors.New("ldap: malformed integer")
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+func (p *packet) string() string {
+	return string(p.value)
+}
+
+// readPacket reads one BER element.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, errors.New("ldap: multi-byte tags are not supported")
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		count := int(first & 0x7f)
+		if count == 0 || count > 4 {
+			return nil, errors.New("ldap: unsupported length encoding")
+		}
+		length = 0
+		for range count {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("ldap: packet of %d bytes is too large", length)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, content)
+}
+
+func parsePacket(tag byte, content []byte) (*packet, error) {
+	p := &packet{tag: tag}
+	if tag&constructed == 0 {
+		p.value = content
+		return p, nil
+	}
+
+	for len(content) > 0 {
+		child, rest, err := splitPacket(content)
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		content = rest
+	}
+	return p, nil
+}
+
+// splitPacket decodes the element at the start of buf and returns the rest.
+func splitPacket(buf []byte) (*packet, []byte, error) {
+	if len(buf) < 2 {
+		return nil, nil, errors.New("ldap: truncated packet")
+	}
+	tag := buf[0]
+	length := int(buf[1])
+	offset := 2
+	if buf[1]&0x80 != 0 {
+		count := int(buf[1] & 0x7f)
+		if count == 0 || count > 4 || len(buf) < 2+count {
+			return nil, nil, errors.New("ldap: unsupported length encoding")
+		}
+		length = 0
+		for _, b := range buf[2 : 2+count] {
+			length = length<<8 | int(b)
+		}
+		offset += count
+	}
+	if length < 0 || len(buf)-offset < length {
+		return nil, nil, errors.New("ldap: truncated packet")
+	}
+
+	child, err := parsePacket(tag, buf[offset:offset+length])
+	if err != nil {
+		return nil, nil, err
+	}
+	return child, buf[offset+length:], nil
+}
@@ -0,0 +1,104 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIntegerRoundTrip(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x00, 0x80}},
+		{256, []byte{0x01, 0x00}},
+		{-1, []byte{0xff}},
+		{-128, []byte{0x80}},
+		{-129, []byte{0xff, 0x7f}},
+	}
+	for _, tt := range tests {
+		p := newInteger(tagInteger, tt.n)
+		if !bytes.Equal(p.value, tt.want) {
+			t.Errorf("newInteger(%d) = % x, want % x", tt.n, p.value, tt.want)
+		}
+		got, err := p.int()
+		if err != nil || got != tt.n {
+			t.Errorf("int() of %d = %d, %v", tt.n, got, err)
+		}
+	}
+}
+
+func TestEncodeLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x80}},
+		{300, []byte{0x82, 0x01, 0x2c}},
+	}
+	for _, tt := range tests {
+		if got := encodeLength(tt.n); !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeLength(%d) = % x, want % x", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	p := newConstructed(tagSequence,
+		newInteger(tagInteger, 7),
+		newConstructed(opBindRequest,
+			newString(tagOctetString, "cn=admin"),
+			newString(authSimple, long),
+		),
+		newBoolean(true),
+	)
+
+	got, err := readPacket(bufio.NewReader(bytes.NewReader(p.encode())))
+	if err != nil {
+		t.Fatalf("readPacket: %v", err)
+	}
+	if got.tag != tagSequence || len(got.children) != 3 {
+		t.Fatalf("got tag 0x%02x with %d children", got.tag, len(got.children))
+	}
+	if n, _ := got.children[0].int(); n != 7 {
+		t.Errorf("message id = %d, want 7", n)
+	}
+	bind := got.children[1]
+	if bind.tag != opBindRequest || len(bind.children) != 2 {
+		t.Fatalf("bind tag 0x%02x with %d children", bind.tag, len(bind.children))
+	}
+	if bind.children[0].string() != "cn=admin" || bind.children[1].string() != long {
+		t.Errorf("bind values = %q, %d bytes", bind.children[0].string(), len(bind.children[1].string()))
+	}
+	if !bytes.Equal(got.children[2].value, []byte{0xff}) {
+		t.Errorf("boolean = % x", got.children[2].value)
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated content", []byte{0x04, 0x05, 'a', 'b'}},
+		{"truncated child", []byte{0x30, 0x03, 0x04, 0x05, 'a'}},
+		{"multi-byte tag", []byte{0x1f, 0x01, 0x00}},
+		{"indefinite length", []byte{0x30, 0x80}},
+		{"too large", []byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readPacket(bufio.NewReader(bytes.NewReader(tt.data))); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+// Package ldap is a minimal LDAPv3 client (RFC 4511) supporting what user
+// authentication needs: simple bind, search and StartTLS.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Protocol operations (RFC 4511 section 4.2 to 4.14)
+const (
+	opBindRequest         = classApplication | constructed | 0
+	opBindResponse        = classApplication | constructed | 1
+	opUnbindRequest       = classApplication | 2
+	opSearchRequest       = classApplication | constructed | 3
+	opSearchResultEntry   = classApplication | constructed | 4
+	opSearchResultDone    = classApplication | constructed | 5
+	opSearchResultRef     = classApplication | constructed | 19
+	opExtendedRequest     = classApplication | constructed | 23
+	opExtendedResponse    = classApplication | constructed | 24
+	authSimple            = classContext | 0
+	extendedRequestName   = classContext | 0
+	startTLSOID           = "1.3.6.1.4.1.1466.20037"
+	scopeWholeSubtree     = 2
+	derefAliasesNever     = 0
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// Result codes (RFC 4511 appendix A)
+const (
+	ResultSuccess            = 0
+	ResultInvalidCredentials = 49
+)
+
+// Error is a non-success result returned by the server.
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsInvalidCredentials reports whether err is a failed bind.
+func IsInvalidCredentials(err error) bool {
+	var ldapErr *Error
+	return errors.As(err, &ldapErr) && ldapErr.ResultCode == ResultInvalidCredentials
+}
+
+// SearchRequest finds the entries below BaseDN matching Filter.
+type SearchRequest struct {
+	BaseDN     string
+	Filter     string
+	Attributes []string
+	SizeLimit  int
+}
+
+// Entry is a search result.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Values returns the values of an attribute, matching its name case
+// insensitively.
+func (e *Entry) Values(name string) []string {
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Value returns the first value of an attribute.
+func (e *Entry) Value(name string) string {
+	if values := e.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Conn is a connection to an LDAP server. It runs one operation at a time
+// and is not safe for concurrent use.
+type Conn struct {
+	conn      net.Conn
+	host      string
+	reader    *bufio.Reader
+	messageID int64
+	deadline  time.Time
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. tlsConfig is used for
+// ldaps:// and may be nil.
+func Dial(ctx context.Context, rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+
+	host := u.Host
+	var dialer net.Dialer
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: withServerName(tlsConfig, u.Hostname())}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewConn(conn)
+	c.host = u.Hostname()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.deadline = deadline
+	}
+	return c, nil
+}
+
+// NewConn wraps an established connection, e.g. to an in-process server.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// StartTLS upgrades the connection to TLS (RFC 4511 section 4.14).
+func (c *Conn) StartTLS(tlsConfig *tls.Config) error {
+	response, err := c.request(newConstructed(opExtendedRequest,
+		newString(extendedRequestName, startTLSOID),
+	), opExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := resultError(response); err != nil {
+		return err
+	}
+
+	host := c.host
+	if host == "" {
+		host, _, _ = net.SplitHostPort(c.conn.RemoteAddr().String())
+	}
+	tlsConn := tls.Client(c.conn, withServerName(tlsConfig, host))
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("ldap: TLS handshake failed: %w", err)
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Bind authenticates the connection with a DN and password. Empty
+// passwords are refused, since servers accept them as anonymous binds.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return &Error{ResultCode: ResultInvalidCredentials, Message: "empty password"}
+	}
+
+	response, err := c.request(newConstructed(opBindRequest,
+		newInteger(tagInteger, 3),
+		newString(tagOctetString, dn),
+		newString(authSimple, password),
+	), opBindResponse)
+	if err != nil {
+		return err
+	}
+	return resultError(response)
+}
+
+// Search returns every entry matching the request.
+func (c *Conn) Search(req *SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attributes := newConstructed(tagSequence)
+	for _, attr := range req.Attributes {
+		attributes.children = append(attributes.children, newString(tagOctetString, attr))
+	}
+
+	id, err := c.send(newConstructed(opSearchRequest,
+		newString(tagOctetString, req.BaseDN),
+		newInteger(tagEnumerated, scopeWholeSubtree),
+		newInteger(tagEnumerated, derefAliasesNever),
+		newInteger(tagInteger, int64(req.SizeLimit)),
+		newInteger(tagInteger, 0),
+		newBoolean(false),
+		filter,
+		attributes,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.tag {
+		case opSearchResultEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchResultRef:
+			// Referrals to other servers are not followed
+		case opSearchResultDone:
+			if err := resultError(op); err != nil {
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response 0x%02x", op.tag)
+		}
+	}
+}
+
+// Close sends an unbind request and closes the connection.
+func (c *Conn) Close() error {
+	_, _ = c.send(newPrimitive(opUnbindRequest, nil))
+	return c.conn.Close()
+}
+
+func (c *Conn) request(op *packet, responseTag byte) (*packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	if response.tag != responseTag {
+		return nil, fmt.Errorf("ldap: unexpected response 0x%02x", response.tag)
+	}
+	return response, nil
+}
+
+func (c *Conn) send(op *packet) (int64, error) {
+	c.messageID++
+	message := newConstructed(tagSequence, newInteger(tagInteger, c.messageID), op)
+
+	if err := c.conn.SetWriteDeadline(c.timeout()); err != nil {
+		return 0, err
+	}
+	if _, err := c.conn.Write(message.encode()); err != nil {
+		return 0, err
+	}
+	return c.messageID, nil
+}
+
+// receive reads the next message, which must answer the request id.
+func (c *Conn) receive(id int64) (*packet, error) {
+	if err := c.conn.SetReadDeadline(c.timeout()); err != nil {
+		return nil, err
+	}
+	message, err := readPacket(c.reader)
+	if err != nil {
+		return nil, err
+	}
+	if message.tag != tagSequence || len(message.children) < 2 {
+		return nil, errors.New("ldap: malformed message")
+	}
+
+	messageID, err := message.children[0].int()
+	if err != nil {
+		return nil, err
+	}
+	op := message.children[1]
+	if messageID == 0 && op.tag == opExtendedResponse {
+		// Notice of disconnection (RFC 4511 section 4.4.1)
+		if err := resultError(op); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("ldap: server closed the connection")
+	}
+	if messageID != id {
+		return nil, fmt.Errorf("ldap: response to message %d, expected %d", messageID, id)
+	}
+	return op, nil
+}
+
+func (c *Conn) timeout() time.Time {
+	if !c.deadline.IsZero() {
+		return c.deadline
+	}
+	return time.Now().Add(defaultRequestTimeout)
+}
+
+// resultError returns the LDAPResult of a response as an error, or nil on
+// success.
+func resultError(response *packet) error {
+	if len(response.children) < 3 {
+		return errors.New("ldap: malformed result")
+	}
+	code, err := response.children[0].int()
+	if err != nil {
+		return err
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &Error{ResultCode: int(code), Message: response.children[2].string()}
+}
+
+func parseEntry(op *packet) (*Entry, error) {
+	if len(op.children) < 2 {
+		return nil, errors.New("ldap: malformed search entry")
+	}
+
+	entry := &Entry{
+		DN:         op.children[0].string(),
+		Attributes: make(map[string][]string),
+	}
+	for _, attr := range op.children[1].children {
+		if len(attr.children) < 2 {
+			return nil, errors.New("ldap: malformed attribute")
+		}
+		name := attr.children[0].string()
+		for _, value := range attr.children[1].children {
+			entry.Attributes[name] = append(entry.Attributes[name], value.string())
+		}
+	}
+	return entry, nil
+}
+
+func withServerName(tlsConfig *tls.Config, host string) *tls.Config {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	return tlsConfig
+}
@@ -0,0 +1,180 @@
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeServer answers the requests of a Conn over an in-process pipe. The
+// handler returns the operations to send back for each request.
+func fakeServer(t *testing.T, handler func(op *packet) []*packet) *Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	go func() {
+		defer server.Close()
+		reader := bufio.NewReader(server)
+		for {
+			message, err := readPacket(reader)
+			if err != nil {
+				return
+			}
+			if len(message.children) < 2 {
+				t.Errorf("malformed message from client")
+				return
+			}
+			id, op := message.children[0], message.children[1]
+			if op.tag == opUnbindRequest {
+				return
+			}
+			for _, response := range handler(op) {
+				reply := newConstructed(tagSequence, id, response)
+				if _, err := server.Write(reply.encode()); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return NewConn(client)
+}
+
+func result(tag byte, code int64, message string) *packet {
+	return newConstructed(tag,
+		newInteger(tagEnumerated, code),
+		newString(tagOctetString, ""),
+		newString(tagOctetString, message),
+	)
+}
+
+func TestBind(t *testing.T) {
+	conn := fakeServer(t, func(op *packet) []*packet {
+		if op.tag != opBindRequest || len(op.children) != 3 {
+			t.Errorf("unexpected request 0x%02x", op.tag)
+			return nil
+		}
+		if version, _ := op.children[0].int(); version != 3 {
+			t.Errorf("version = %d, want 3", version)
+		}
+		if op.children[1].string() == "uid=alice,dc=example" && op.children[2].string() == "secret" {
+			return []*packet{result(opBindResponse, ResultSuccess, "")}
+		}
+		return []*packet{result(opBindResponse, ResultInvalidCredentials, "bad password")}
+	})
+	defer conn.Close()
+
+	if err := conn.Bind("uid=alice,dc=example", "secret"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	err := conn.Bind("uid=alice,dc=example", "wrong")
+	if !IsInvalidCredentials(err) {
+		t.Fatalf("Bind with wrong password = %v, want invalid credentials", err)
+	}
+	if err.Error() != "ldap: result code 49: bad password" {
+		t.Errorf("error = %q", err)
+	}
+
+	if err := conn.Bind("uid=alice,dc=example", ""); !IsInvalidCredentials(err) {
+		t.Errorf("Bind with empty password = %v, want invalid credentials", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	conn := fakeServer(t, func(op *packet) []*packet {
+		if op.tag != opSearchRequest || len(op.children) != 8 {
+			t.Errorf("unexpected request 0x%02x", op.tag)
+			return nil
+		}
+		if base := op.children[0].string(); base != "dc=example" {
+			t.Errorf("base DN = %q", base)
+		}
+		filter := op.children[6]
+		if filter.tag != filterEquality || filter.children[1].string() != "a*)" {
+			t.Errorf("filter was not compiled from the escaped value")
+		}
+		var attributes []string
+		for _, attr := range op.children[7].children {
+			attributes = append(attributes, attr.string())
+		}
+		if !reflect.DeepEqual(attributes, []string{"mail", "memberOf"}) {
+			t.Errorf("attributes = %v", attributes)
+		}
+
+		entry := newConstructed(opSearchResultEntry,
+			newString(tagOctetString, "uid=alice,dc=example"),
+			newConstructed(tagSequence,
+				newConstructed(tagSequence,
+					newString(tagOctetString, "mail"),
+					newConstructed(tagSet, newString(tagOctetString, "alice@example.com")),
+				),
+				newConstructed(tagSequence,
+					newString(tagOctetString, "memberOf"),
+					newConstructed(tagSet,
+						newString(tagOctetString, "cn=admins,dc=example"),
+						newString(tagOctetString, "cn=users,dc=example"),
+					),
+				),
+			),
+		)
+		referral := newConstructed(opSearchResultRef, newString(tagOctetString, "ldap://other/"))
+		return []*packet{entry, referral, result(opSearchResultDone, ResultSuccess, "")}
+	})
+	defer conn.Close()
+
+	entries, err := conn.Search(&SearchRequest{
+		BaseDN:     "dc=example",
+		Filter:     "(uid=" + EscapeFilter("a*)") + ")",
+		Attributes: []string{"mail", "memberOf"},
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.DN != "uid=alice,dc=example" {
+		t.Errorf("DN = %q", entry.DN)
+	}
+	if mail := entry.Value("MAIL"); mail != "alice@example.com" {
+		t.Errorf("mail = %q", mail)
+	}
+	if groups := entry.Values("memberof"); len(groups) != 2 {
+		t.Errorf("groups = %v", groups)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	conn := fakeServer(t, func(op *packet) []*packet {
+		return []*packet{result(opSearchResultDone, 32, "no such object")}
+	})
+	defer conn.Close()
+
+	_, err := conn.Search(&SearchRequest{BaseDN: "dc=missing", Filter: "(uid=alice)"})
+	ldapErr, ok := err.(*Error)
+	if !ok || ldapErr.ResultCode != 32 {
+		t.Fatalf("Search = %v, want result code 32", err)
+	}
+}
+
+func TestResponseToOtherMessage(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		if _, err := readPacket(bufio.NewReader(server)); err != nil {
+			return
+		}
+		reply := newConstructed(tagSequence, newInteger(tagInteger, 42), result(opBindResponse, ResultSuccess, ""))
+		server.Write(reply.encode())
+	}()
+
+	if err := NewConn(client).Bind("cn=admin", "secret"); err == nil {
+		t.Fatal("expected an error for a response to another message")
+	}
+}
@@ -0,0 +1,83 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscapeFilter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"alice", "alice"},
+		{"*", `\2a`},
+		{"a)(uid=*", `a\29\28uid=\2a`},
+		{`back\slash`, `back\5cslash`},
+		{"nul\x00", `nul\00`},
+	}
+	for _, tt := range tests {
+		if got := EscapeFilter(tt.value); got != tt.want {
+			t.Errorf("EscapeFilter(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	equality := func(attr, value string) *packet {
+		return &packet{tag: filterEquality, children: []*packet{
+			newString(tagOctetString, attr),
+			newString(tagOctetString, value),
+		}}
+	}
+
+	tests := []struct {
+		filter string
+		want   *packet
+	}{
+		{"(uid=alice)", equality("uid", "alice")},
+		{"uid=alice", equality("uid", "alice")},
+		{"(cn=*)", newString(filterPresent, "cn")},
+		{`(uid=a\2a\29)`, equality("uid", "a*)")},
+		{"(&(objectClass=person)(uid=alice))", &packet{tag: filterAnd, children: []*packet{
+			equality("objectClass", "person"),
+			equality("uid", "alice"),
+		}}},
+		{"(|(uid=alice)(mail=*))", &packet{tag: filterOr, children: []*packet{
+			equality("uid", "alice"),
+			newString(filterPresent, "mail"),
+		}}},
+		{"(!(disabled=TRUE))", &packet{tag: filterNot, children: []*packet{
+			equality("disabled", "TRUE"),
+		}}},
+	}
+	for _, tt := range tests {
+		got, err := compileFilter(tt.filter)
+		if err != nil {
+			t.Errorf("compileFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		if !bytes.Equal(got.encode(), tt.want.encode()) {
+			t.Errorf("compileFilter(%q) = % x, want % x", tt.filter, got.encode(), tt.want.encode())
+		}
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	filters := []string{
+		"(uid=alice",
+		"(uid=alice))",
+		"(&(uid=alice)",
+		"(uid=al*)",
+		"(uid~=alice)",
+		"(=alice)",
+		"(uid=\\zz)",
+		"(uid=\\2)",
+		"(!(uid=alice)",
+	}
+	for _, filter := range filters {
+		if _, err := compileFilter(filter); err == nil {
+			t.Errorf("compileFilter(%q) succeeded", filter)
+		}
+	}
+}
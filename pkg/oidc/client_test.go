@@ -0,0 +1,327 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "scripts"
+	testVerifier = "verifier"
+	testNonce    = "nonce"
+)
+
+// testIssuer is an identity provider serving discovery, keys and a token
+// endpoint. The token endpoint answers with the authorization code as the
+// ID token, so each test decides which token the client receives.
+type testIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			JWKSURI:               issuer.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": encode(rsaKey.N.Bytes()), "e": "AQAB"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != testClientID || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.FormValue("grant_type") != "authorization_code" || r.FormValue("code_verifier") != testVerifier {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": r.FormValue("code")})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (i *testIssuer) client() *Client {
+	return NewClient(Config{
+		Issuer:       i.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.test/callback",
+		Scopes:       []string{"openid", "email"},
+	}, i.Client())
+}
+
+// claims returns valid claims for the test client.
+func (i *testIssuer) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   i.URL,
+		"sub":   "user-1",
+		"aud":   testClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": testNonce,
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	rawURL, err := issuer.client().AuthCodeURL(context.Background(), "state", testNonce, testVerifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/authorize" {
+		t.Errorf("path = %q", u.Path)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "https://app.test/callback",
+		"scope":                 "openid email",
+		"state":                 "state",
+		"nonce":                 testNonce,
+		"code_challenge":        challenge(testVerifier),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newTestIssuer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := issuer.claims()
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{
+			name:  "RS256",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, issuer.claims()),
+		},
+		{
+			name:  "ES256",
+			token: sign(t, jwt.SigningMethodES256, "ec", issuer.ecKey, issuer.claims()),
+		},
+		{
+			name: "several audiences including the client",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) {
+				c["aud"] = []string{"other", testClientID}
+				c["azp"] = testClientID
+			})),
+		},
+		{
+			name:    "signed with another key",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", otherKey, issuer.claims()),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "unknown key",
+			token:   sign(t, jwt.SigningMethodRS256, "missing", issuer.rsaKey, issuer.claims()),
+			wantErr: "unknown signing key",
+		},
+		{
+			name:    "encryption key",
+			token:   sign(t, jwt.SigningMethodRS256, "enc", issuer.rsaKey, issuer.claims()),
+			wantErr: "unknown signing key",
+		},
+		{
+			name:    "HMAC",
+			token:   sign(t, jwt.SigningMethodHS256, "rsa", []byte("secret"), issuer.claims()),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "unsigned",
+			token:   sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, issuer.claims()),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "other issuer",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.test" })),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "other audience",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { c["aud"] = "other" })),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "expired",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "no expiry",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { delete(c, "exp") })),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "issued in the future",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() })),
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "other nonce",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { c["nonce"] = "replayed" })),
+			wantErr: "nonce",
+		},
+		{
+			name:    "no nonce",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { delete(c, "nonce") })),
+			wantErr: "nonce",
+		},
+		{
+			name: "authorized party is another client",
+			token: sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) {
+				c["aud"] = []string{testClientID, "other"}
+				c["azp"] = "other"
+			})),
+			wantErr: "another client",
+		},
+		{
+			name:    "no subject",
+			token:   sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, with(func(c jwt.MapClaims) { delete(c, "sub") })),
+			wantErr: "no subject",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := issuer.client().Exchange(context.Background(), tt.token, testVerifier, testNonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Exchange: %v", err)
+				}
+				if claims.String("sub") != "user-1" {
+					t.Errorf("sub = %q", claims.String("sub"))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Exchange = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeTokenError(t *testing.T) {
+	issuer := newTestIssuer(t)
+	token := sign(t, jwt.SigningMethodRS256, "rsa", issuer.rsaKey, issuer.claims())
+
+	_, err := issuer.client().Exchange(context.Background(), token, "wrong verifier", testNonce)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Exchange = %v, want invalid_grant", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                "https://evil.test",
+			AuthorizationEndpoint: "https://evil.test/authorize",
+			TokenEndpoint:         "https://evil.test/token",
+			JWKSURI:               "https://evil.test/keys",
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{Issuer: server.URL, ClientID: testClientID}, server.Client())
+	_, err := client.AuthCodeURL(context.Background(), "state", testNonce, testVerifier)
+	if err == nil || !strings.Contains(err.Error(), "discovery returned issuer") {
+		t.Fatalf("AuthCodeURL = %v, want an issuer mismatch", err)
+	}
+}
+
+func TestClaims(t *testing.T) {
+	claims := Claims{
+		"name":           "Alice",
+		"email_verified": "true",
+		"groups":         []any{"admins", 1, "users"},
+		"role":           "member",
+	}
+	if claims.String("name") != "Alice" || claims.String("missing") != "" {
+		t.Error("String")
+	}
+	if !claims.Bool("email_verified") || claims.Bool("name") {
+		t.Error("Bool")
+	}
+	if got := claims.Strings("groups"); len(got) != 2 || got[0] != "admins" || got[1] != "users" {
+		t.Errorf("Strings(groups) = %v", got)
+	}
+	if got := claims.Strings("role"); len(got) != 1 || got[0] != "member" {
+		t.Errorf("Strings(role) = %v", got)
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher("passphrase")
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+
+	sealed, err := c.Seal([]byte("private key"), []byte("kid"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	opened, err := c.Open(sealed, []byte("kid"))
+	if err != nil || string(opened) != "private key" {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+
+	if again, _ := c.Seal([]byte("private key"), []byte("kid")); again == sealed {
+		t.Error("Seal reused a nonce")
+	}
+	if _, err := c.Open(sealed, []byte("other kid")); err == nil {
+		t.Error("Open accepted other additional data")
+	}
+
+	other, err := NewCipher("other passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed, []byte("kid")); err == nil {
+		t.Error("Open accepted another key")
+	}
+
+	for _, invalid := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := c.Open(invalid, nil); err == nil {
+			t.Errorf("Open(%q) succeeded", invalid)
+		}
+	}
+
+	if _, err := NewCipher(""); err == nil {
+		t.Error("NewCipher accepted an empty passphrase")
+	}
+}
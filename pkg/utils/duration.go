@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration extends time.ParseDuration with day ("d") and week ("w")
+// units, so that values such as "7d" or "2w" are accepted.
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit > 0 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err == nil {
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	return time.ParseDuration(value)
+}
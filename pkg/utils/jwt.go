@@ -4,56 +4,236 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// APITokenPrefix marks personal access tokens so they can be told apart from
+// JWTs in the Authorization header.
+const APITokenPrefix = "pat_"
+
+// AccessTokenTTL is how long an issued access token stays valid. Sessions
+// outlive it through refresh tokens.
+const AccessTokenTTL = 15 * time.Minute
+
 type JWTClaims struct {
 	UserID   primitive.ObjectID `json:"user_id"`
 	Username string             `json:"username"`
 	Role     string             `json:"role"`
+	// Scopes and APITokenID are only set when the request authenticated with
+	// a personal access token; sessions are unscoped.
+	Scopes     []string           `json:"-"`
+	APITokenID primitive.ObjectID `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// IsAPIToken reports whether the claims come from a personal access token.
+func (c *JWTClaims) IsAPIToken() bool {
+	return !c.APITokenID.IsZero()
+}
+
+// HasScope reports whether the caller may use an operation guarded by scope.
+func (c *JWTClaims) HasScope(scope string) bool {
+	if !c.IsAPIToken() {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SigningKey is an ECDSA P-256 key identified by the kid header of the
+// tokens it signs.
+type SigningKey struct {
+	ID         string
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// JWK is the public part of a signing key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWTManager signs tokens with the active key and validates them against
+// every key of the keyring, so tokens signed before a rotation stay valid.
 type JWTManager struct {
-	privateKey *ecdsa.PrivateKey
-	publicKey  *ecdsa.PublicKey
+	mu       sync.RWMutex
+	keys     map[string]*SigningKey
+	activeID string
 }
 
+// NewJWTManager creates a manager with a single ephemeral key. Tokens are
+// invalidated on restart; use SetKeys to install a persistent keyring.
 func NewJWTManager() (*JWTManager, error) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &JWTManager{}
+	if err := m.SetKeys([]*SigningKey{key}, key.ID); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewJWTManagerFromPEM creates a manager signing with the EC private key
+// stored in a PEM file.
+func NewJWTManagerFromPEM(path string) (*JWTManager, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	key, err := ParseSigningKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &JWTManager{}
+	if err := m.SetKeys([]*SigningKey{key}, key.ID); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GenerateSigningKey creates a new P-256 key whose ID is its thumbprint.
+func GenerateSigningKey() (*SigningKey, error) {
 	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, err
 	}
+	return &SigningKey{ID: keyThumbprint(&privateKey.PublicKey), PrivateKey: privateKey}, nil
+}
+
+// ParseSigningKey decodes a PEM encoded EC private key, in SEC 1 or PKCS #8
+// form.
+func ParseSigningKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	var privateKey *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		privateKey = key
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("signing key is not an EC key")
+		}
+		privateKey = ecKey
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %q", block.Type)
+	}
+
+	if privateKey.Curve != elliptic.P256() {
+		return nil, errors.New("signing key must use the P-256 curve")
+	}
 
-	return &JWTManager{
-		privateKey: privateKey,
-		publicKey:  &privateKey.PublicKey,
-	}, nil
+	return &SigningKey{ID: keyThumbprint(&privateKey.PublicKey), PrivateKey: privateKey}, nil
 }
 
-func (m *JWTManager) GenerateToken(userID primitive.ObjectID, username, role string) (string, error) {
-	claims := JWTClaims{
+// EncodeSigningKey returns the PEM encoding of the private key.
+func EncodeSigningKey(key *SigningKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// SetKeys replaces the keyring. activeID selects the key used for signing
+// and must be one of keys.
+func (m *JWTManager) SetKeys(keys []*SigningKey, activeID string) error {
+	ring := make(map[string]*SigningKey, len(keys))
+	for _, key := range keys {
+		ring[key.ID] = key
+	}
+	if _, ok := ring[activeID]; !ok {
+		return errors.New("active signing key is not in the keyring")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = ring
+	m.activeID = activeID
+	return nil
+}
+
+// GenerateToken signs an access token with a unique jti. The returned claims
+// carry the jti and expiry so the token can be revoked later.
+func (m *JWTManager) GenerateToken(userID primitive.ObjectID, username, role string) (string, *JWTClaims, error) {
+	m.mu.RLock()
+	key := m.keys[m.activeID]
+	m.mu.RUnlock()
+
+	now := time.Now()
+	claims := &JWTClaims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        primitive.NewObjectID().Hex(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
-	return token.SignedString(m.privateKey)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
 func (m *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return m.publicKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+
+		m.mu.RLock()
+		key, ok := m.keys[kid]
+		m.mu.RUnlock()
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		return &key.PrivateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -65,3 +245,47 @@ func (m *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// JWKS returns the public keys of the keyring.
+func (m *JWTManager) JWKS() JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(m.keys))}
+	for _, key := range m.keys {
+		x, y := publicCoordinates(&key.PrivateKey.PublicKey)
+		set.Keys = append(set.Keys, JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   x,
+			Y:   y,
+			Kid: key.ID,
+			Use: "sig",
+			Alg: jwt.SigningMethodES256.Alg(),
+		})
+	}
+	return set
+}
+
+// keyThumbprint computes the RFC 7638 thumbprint of a public key.
+func keyThumbprint(publicKey *ecdsa.PublicKey) string {
+	x, y := publicCoordinates(publicKey)
+	// Members must be in lexicographic order with no whitespace
+	data, _ := json.Marshal(struct {
+		Crv string `json:"crv"`
+		Kty string `json:"kty"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}{Crv: "P-256", Kty: "EC", X: x, Y: y})
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func publicCoordinates(publicKey *ecdsa.PublicKey) (string, string) {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	publicKey.X.FillBytes(x)
+	publicKey.Y.FillBytes(y)
+	return base64.RawURLEncoding.EncodeToString(x), base64.RawURLEncoding.EncodeToString(y)
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSigningKeyEncoding(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.ID) != 43 {
+		t.Errorf("key ID %q is not a base64url SHA-256 thumbprint", key.ID)
+	}
+
+	encoded, err := EncodeSigningKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseSigningKey(encoded)
+	if err != nil {
+		t.Fatalf("ParseSigningKey: %v", err)
+	}
+	if parsed.ID != key.ID || !parsed.PrivateKey.Equal(key.PrivateKey) {
+		t.Error("key changed through encoding")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || pkcs8.ID != key.ID {
+		t.Errorf("ParseSigningKey of PKCS #8 = %v, %v", pkcs8, err)
+	}
+}
+
+func TestParseSigningKeyRejects(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384DER, _ := x509.MarshalECPrivateKey(p384)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaDER, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+
+	inputs := map[string][]byte{
+		"not PEM":      []byte("secret"),
+		"P-384":        pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p384DER}),
+		"RSA":          pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rsaDER}),
+		"certificate":  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("x")}),
+		"corrupted EC": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("x")}),
+	}
+	for name, data := range inputs {
+		if _, err := ParseSigningKey(data); err == nil {
+			t.Errorf("ParseSigningKey accepted a %s key", name)
+		}
+	}
+}
+
+func TestJWTManagerRotation(t *testing.T) {
+	oldKey, _ := GenerateSigningKey()
+	newKey, _ := GenerateSigningKey()
+	userID := primitive.NewObjectID()
+
+	m := &JWTManager{}
+	if err := m.SetKeys([]*SigningKey{oldKey}, oldKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _, err := m.GenerateToken(userID, "alice", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens signed before a rotation stay valid while their key is in the keyring
+	if err := m.SetKeys([]*SigningKey{newKey, oldKey}, newKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := m.ValidateToken(oldToken); err != nil || claims.UserID != userID {
+		t.Fatalf("ValidateToken of the old token = %v, %v", claims, err)
+	}
+	newToken, _, err := m.GenerateToken(userID, "alice", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &JWTClaims{})
+	if err != nil || parsed.Header["kid"] != newKey.ID {
+		t.Errorf("new token kid = %v, want %s", parsed.Header["kid"], newKey.ID)
+	}
+
+	jwks := m.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Errorf("JWKS has %d keys, want 2", len(jwks.Keys))
+	}
+	for _, key := range jwks.Keys {
+		if key.Kty != "EC" || key.Crv != "P-256" || key.Alg != "ES256" || key.Use != "sig" {
+			t.Errorf("JWK = %+v", key)
+		}
+	}
+
+	// Dropping a key invalidates its tokens
+	if err := m.SetKeys([]*SigningKey{newKey}, newKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(oldToken); err == nil {
+		t.Error("token of a dropped key is still valid")
+	}
+
+	if err := m.SetKeys([]*SigningKey{newKey}, oldKey.ID); err == nil {
+		t.Error("SetKeys accepted an active key outside the keyring")
+	}
+}
+
+func TestValidateTokenRejectsOtherAlgorithms(t *testing.T) {
+	m, err := NewJWTManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := m.JWKS().Keys[0].Kid
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{Username: "alice"})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString([]byte(kid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(signed); err == nil {
+		t.Error("HS256 token was accepted")
+	}
+}
+
+func TestGenerateTokenClaims(t *testing.T) {
+	m, err := NewJWTManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := primitive.NewObjectID()
+
+	token, claims, err := m.GenerateToken(userID, "alice", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID == "" || claims.UserID != userID {
+		t.Errorf("claims = %+v", claims)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != AccessTokenTTL {
+		t.Errorf("token lifetime = %v, want %v", ttl, AccessTokenTTL)
+	}
+
+	validated, err := m.ValidateToken(token)
+	if err != nil || validated.ID != claims.ID {
+		t.Errorf("ValidateToken = %+v, %v, want jti %s", validated, err, claims.ID)
+	}
+
+	_, other, err := m.GenerateToken(userID, "alice", "member")
+	if err != nil || other.ID == claims.ID {
+		t.Errorf("two tokens share the jti %s", claims.ID)
+	}
+}
+
+func TestJWTClaimsScopes(t *testing.T) {
+	session := &JWTClaims{}
+	if session.IsAPIToken() || !session.HasScope("scripts:write") {
+		t.Error("sessions must be unscoped")
+	}
+
+	token := &JWTClaims{APITokenID: primitive.NewObjectID(), Scopes: []string{"scripts:read"}}
+	if !token.IsAPIToken() {
+		t.Error("claims of an API token are not an API token")
+	}
+	if !token.HasScope("scripts:read") || token.HasScope("scripts:write") {
+		t.Errorf("HasScope does not follow the token scopes %v", token.Scopes)
+	}
+}
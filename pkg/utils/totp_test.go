@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := TOTPCode(rfc6238Secret, step+offset)
+		got, ok := ValidateTOTP(rfc6238Secret, " "+code+" ", now)
+		if !ok || got != step+offset {
+			t.Errorf("code of step %+d = (%d, %t), want (%d, true)", offset, got, ok, step+offset)
+		}
+	}
+
+	old, _ := TOTPCode(rfc6238Secret, step-2)
+	if _, ok := ValidateTOTP(rfc6238Secret, old, now); ok {
+		t.Error("code outside the skew was accepted")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "12345", now); ok {
+		t.Error("short code was accepted")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Scripts", "alice@example.com", "SECRET"))
+	if err != nil {
+		t.Fatalf("TOTPURI is not a URL: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Scripts:alice@example.com" {
+		t.Errorf("TOTPURI = %s", uri)
+	}
+	query := uri.Query()
+	if query.Get("secret") != "SECRET" || query.Get("issuer") != "Scripts" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("TOTPURI parameters = %v", query)
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret %q has %d characters, want 32", secret, len(secret))
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("generated secret is unusable: %v", err)
+	}
+}